@@ -1,6 +1,8 @@
 package api
 
 import (
+	"time"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/jobs"
 	"github.com/weaveworks/flux/platform"
@@ -12,10 +14,20 @@ type ClientService interface {
 	ListImages(flux.InstanceID, flux.ServiceSpec) ([]flux.ImageStatus, error)
 	PostRelease(flux.InstanceID, jobs.ReleaseJobParams) (jobs.JobID, error)
 	GetRelease(flux.InstanceID, jobs.JobID) (jobs.Job, error)
-	Automate(flux.InstanceID, flux.ServiceID) error
-	Deautomate(flux.InstanceID, flux.ServiceID) error
-	Lock(flux.InstanceID, flux.ServiceID) error
-	Unlock(flux.InstanceID, flux.ServiceID) error
+	// ListDeliveries gives the recent deliveries of events to
+	// notifiers, most recent first.
+	ListDeliveries(flux.InstanceID) ([]jobs.Job, error)
+	PostRollback(flux.InstanceID, jobs.RollbackJobParams) (jobs.JobID, error)
+	Automate(flux.InstanceID, flux.ServiceID, flux.Cause) error
+	Deautomate(flux.InstanceID, flux.ServiceID, flux.Cause) error
+	// Lock locks a service, for the duration given if it is not zero.
+	Lock(flux.InstanceID, flux.ServiceID, flux.Cause, time.Duration) error
+	Unlock(flux.InstanceID, flux.ServiceID, flux.Cause) error
+	SetImageOrder(flux.InstanceID, flux.ServiceID, flux.ImageOrder) error
+	SetTagFilter(flux.InstanceID, flux.ServiceID, flux.TagFilter) error
+	SetAutoRollback(flux.InstanceID, flux.ServiceID, bool) error
+	SetSyncPaused(flux.InstanceID, bool) error
+	Drift(flux.InstanceID) ([]flux.ServiceDrift, error)
 	History(flux.InstanceID, flux.ServiceSpec) ([]flux.HistoryEntry, error)
 	GetConfig(_ flux.InstanceID) (flux.InstanceConfig, error)
 	SetConfig(flux.InstanceID, flux.UnsafeInstanceConfig) error
@@ -118,7 +118,7 @@ func (a *Automator) handleAutomatedInstanceJob(logger log.Logger, job *jobs.Job,
 	}
 
 	// Get the list of services that are automated, in the repo, and in the running service.
-	updates, err := rc.SelectServices(automatedServiceIDs, flux.ServiceIDSet{}, flux.ServiceIDSet{}, results, logInJob)
+	updates, err := rc.SelectServices(automatedServiceIDs, nil, flux.ServiceIDSet{}, results, logInJob)
 	if err != nil {
 		logInJob("error finding services: %s", err)
 		return followUps, err
@@ -130,6 +130,9 @@ func (a *Automator) handleAutomatedInstanceJob(logger log.Logger, job *jobs.Job,
 		return nil, fmt.Errorf("no automated service(s) %s exist in config or running system", automatedServiceIDs)
 	}
 
+	// Services in a freeze window are left alone until it's over.
+	updates = release.SkipFrozen(config.Settings.Freezes, false, time.Now(), updates, results, logInJob)
+
 	// Get the images available for each automated service.
 	images, err := release.CollectAvailableImages(rc.Instance, updates)
 	if err != nil {
@@ -149,13 +152,14 @@ func (a *Automator) handleAutomatedInstanceJob(logger log.Logger, job *jobs.Job,
 	// already have a map of the available images.
 	imageServices := map[flux.ImageID][]flux.ServiceSpec{}
 	for _, update := range updates {
+		serviceConf := config.Services[update.ServiceID]
 		for _, container := range update.Service.ContainersOrNil() {
 			currentImageID, err := flux.ParseImageID(container.Image)
 			if err != nil {
 				logInJob("error parsing image in service %s container %s (%q): %s", update.Service.ID, container.Name, container.Image, err)
 				return followUps, errors.Wrapf(err, "calculating image updates for %s", container.Name)
 			}
-			if latest := images.LatestImage(currentImageID.Repository()); latest != nil && latest.ID != currentImageID {
+			if latest := images.LatestImage(currentImageID.Repository(), serviceConf.ImageOrder, serviceConf.TagFilter); latest != nil && !currentImageID.UpToDateWith(latest.ID) {
 				imageServices[latest.ID] = append(imageServices[latest.ID], flux.ServiceSpec(update.ServiceID))
 			}
 		}
@@ -180,6 +184,7 @@ func (a *Automator) handleAutomatedInstanceJob(logger log.Logger, job *jobs.Job,
 				ServiceSpecs: services,
 				ImageSpec:    flux.ImageSpecFromID(imageID),
 				Kind:         flux.ReleaseKindExecute,
+				Cause:        flux.Cause{User: flux.UserAutomated},
 			},
 		})
 	}
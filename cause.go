@@ -0,0 +1,16 @@
+package flux
+
+// UserAutomated is the user recorded for changes flux makes by
+// itself, e.g., releasing a new image for an automated service.
+const UserAutomated = "<automated>"
+
+// UserIDHeaderKey is the header in which the proxy in front of the
+// service gives the authenticated user, if there is one.
+const UserIDHeaderKey = "X-Scope-UserID"
+
+// Cause says who made a change, such as a release or locking a
+// service, and why, in their words.
+type Cause struct {
+	User    string `json:"user,omitempty"`
+	Message string `json:"message,omitempty"`
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"os"
+
+	"github.com/spf13/cobra"
+
 	"github.com/weaveworks/flux"
 )
 
@@ -10,3 +14,23 @@ func parseServiceOption(s string) (flux.ServiceSpec, error) {
 	}
 	return flux.ParseServiceSpec(s)
 }
+
+// causeOpts are for saying who is making a change, and why, to be
+// recorded with it.
+type causeOpts struct {
+	user    string
+	message string
+}
+
+func (opts *causeOpts) addFlags(cmd *cobra.Command) {
+	opts.addUserFlag(cmd)
+	cmd.Flags().StringVarP(&opts.message, "message", "m", "", "why the change is being made, recorded in the history")
+}
+
+func (opts *causeOpts) addUserFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&opts.user, "user", os.Getenv("USER"), "who is making the change, recorded in the history")
+}
+
+func (opts causeOpts) cause() flux.Cause {
+	return flux.Cause{User: opts.user, Message: opts.message}
+}
@@ -9,6 +9,7 @@ import (
 type serviceAutomateOpts struct {
 	*serviceOpts
 	service string
+	causeOpts
 }
 
 func newServiceAutomate(parent *serviceOpts) *serviceAutomateOpts {
@@ -25,6 +26,7 @@ func (opts *serviceAutomateOpts) Command() *cobra.Command {
 		RunE: opts.RunE,
 	}
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to automate")
+	opts.causeOpts.addFlags(cmd)
 	return cmd
 }
 
@@ -41,5 +43,5 @@ func (opts *serviceAutomateOpts) RunE(_ *cobra.Command, args []string) error {
 		return err
 	}
 
-	return opts.API.Automate(noInstanceID, serviceID)
+	return opts.API.Automate(noInstanceID, serviceID, opts.cause())
 }
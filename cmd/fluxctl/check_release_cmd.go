@@ -158,7 +158,14 @@ func (opts *serviceCheckReleaseOpts) RunE(cmd *cobra.Command, args []string) err
 		return err
 	}
 
-	spec := job.Params.(jobs.ReleaseJobParams)
+	// Rollbacks are followed just like releases.
+	var kind flux.ReleaseKind
+	switch params := job.Params.(type) {
+	case jobs.ReleaseJobParams:
+		kind = params.Kind
+	case jobs.RollbackJobParams:
+		kind = params.Kind
+	}
 
 	fmt.Fprintf(os.Stdout, "\n")
 	if !job.Success {
@@ -166,7 +173,7 @@ func (opts *serviceCheckReleaseOpts) RunE(cmd *cobra.Command, args []string) err
 		for i, msg := range job.Log {
 			fmt.Fprintf(os.Stdout, " %d) %s\n", i+1, msg)
 		}
-	} else if spec.Kind == flux.ReleaseKindPlan {
+	} else if kind == flux.ReleaseKindPlan {
 		fmt.Fprintf(os.Stdout, "Here's the plan:\n")
 		release.PrintResults(os.Stdout, job.Result.(flux.ReleaseResult), opts.verbose)
 	} else {
@@ -174,7 +181,7 @@ func (opts *serviceCheckReleaseOpts) RunE(cmd *cobra.Command, args []string) err
 		release.PrintResults(os.Stdout, job.Result.(flux.ReleaseResult), opts.verbose)
 	}
 
-	if spec.Kind == flux.ReleaseKindExecute {
+	if kind == flux.ReleaseKindExecute {
 		fmt.Fprintf(os.Stdout, "Took %s\n", job.Finished.Sub(job.Submitted))
 	}
 
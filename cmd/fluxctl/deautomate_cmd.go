@@ -9,6 +9,7 @@ import (
 type serviceDeautomateOpts struct {
 	*serviceOpts
 	service string
+	causeOpts
 }
 
 func newServiceDeautomate(parent *serviceOpts) *serviceDeautomateOpts {
@@ -25,6 +26,7 @@ func (opts *serviceDeautomateOpts) Command() *cobra.Command {
 		RunE: opts.RunE,
 	}
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to deautomate")
+	opts.causeOpts.addFlags(cmd)
 	return cmd
 }
 
@@ -41,5 +43,5 @@ func (opts *serviceDeautomateOpts) RunE(_ *cobra.Command, args []string) error {
 		return err
 	}
 
-	return opts.API.Deautomate(noInstanceID, serviceID)
+	return opts.API.Deautomate(noInstanceID, serviceID, opts.cause())
 }
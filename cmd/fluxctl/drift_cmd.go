@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type driftOpts struct {
+	*rootOpts
+	output string
+}
+
+func newDrift(parent *rootOpts) *driftOpts {
+	return &driftOpts{rootOpts: parent}
+}
+
+func (opts *driftOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Show how the services running differ from their definitions in the repo.",
+		Example: makeExample(
+			"fluxctl drift",
+			"fluxctl drift --output=json",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "table", `The format to output ("table" or "json")`)
+	return cmd
+}
+
+func (opts *driftOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return errors.New("unknown output format " + opts.output)
+	}
+
+	drifts, err := opts.API.Drift(noInstanceID)
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		bytes, err := json.MarshalIndent(drifts, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "marshalling to output format "+opts.output)
+		}
+		os.Stdout.Write(bytes)
+		return nil
+	}
+
+	var drifted int
+	w := newTabwriter()
+	fmt.Fprintf(w, "SERVICE\tCONTAINER\tFIELD\tDEFINED\tRUNNING\n")
+	for _, d := range drifts {
+		if d.Error != "" {
+			fmt.Fprintf(w, "%s\t\terror: %s\t\t\n", d.ID, d.Error)
+			continue
+		}
+		for i, diff := range d.Differences {
+			id := d.ID.String()
+			if i > 0 {
+				id = ""
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", id, diff.Container, diff.Field, diff.Defined, diff.Running)
+		}
+		if d.Drifted() {
+			drifted++
+		}
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stdout, "\n%d of %d services differ from their definitions.\n", drifted, len(drifts))
+	return nil
+}
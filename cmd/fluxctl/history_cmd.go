@@ -5,11 +5,14 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
 )
 
 type serviceHistoryOpts struct {
 	*serviceOpts
 	service string
+	user    string
 }
 
 func newServiceHistory(parent *serviceOpts) *serviceHistoryOpts {
@@ -23,10 +26,12 @@ func (opts *serviceHistoryOpts) Command() *cobra.Command {
 		Example: makeExample(
 			"fluxctl history --service=default/foo",
 			"fluxctl history",
+			"fluxctl history --user=alice",
 		),
 		RunE: opts.RunE,
 	}
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service for which to show history; if left empty, history for all services is shown")
+	cmd.Flags().StringVar(&opts.user, "user", "", fmt.Sprintf("Show only the changes made by this user (%q for automated releases)", flux.UserAutomated))
 	return cmd
 }
 
@@ -47,9 +52,20 @@ func (opts *serviceHistoryOpts) RunE(_ *cobra.Command, args []string) error {
 
 	out := newTabwriter()
 
-	fmt.Fprintln(out, "TIME\tTYPE\tMESSAGE")
+	fmt.Fprintln(out, "TIME\tTYPE\tUSER\tMESSAGE")
 	for _, event := range events {
-		fmt.Fprintf(out, "%s\t%s\t%s\n", event.Stamp.Format(time.RFC822), event.Type, event.Data)
+		cause := event.Cause
+		if opts.user != "" && cause.User != opts.user {
+			continue
+		}
+		user, message := cause.User, event.Data
+		if user == "" {
+			user = "-"
+		}
+		if cause.Message != "" {
+			message = fmt.Sprintf("%s (%s)", message, cause.Message)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", event.Stamp.Format(time.RFC822), event.Type, user, message)
 	}
 
 	out.Flush()
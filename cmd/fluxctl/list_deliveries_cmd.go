@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux/jobs"
+)
+
+type listDeliveriesOpts struct {
+	*rootOpts
+}
+
+func newListDeliveries(parent *rootOpts) *listDeliveriesOpts {
+	return &listDeliveriesOpts{rootOpts: parent}
+}
+
+func (opts *listDeliveriesOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list-deliveries",
+		Short:   "List the recent deliveries of events to notifiers, and how they went.",
+		Example: makeExample("fluxctl list-deliveries"),
+		RunE:    opts.RunE,
+	}
+	return cmd
+}
+
+func (opts *listDeliveriesOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+
+	deliveries, err := opts.API.ListDeliveries(noInstanceID)
+	if err != nil {
+		return err
+	}
+
+	w := newTabwriter()
+	fmt.Fprintf(w, "DELIVERY\tSUBMITTED\tEVENT\tSERVICES\tNOTIFIER\tTO\tATTEMPT\tSTATUS\n")
+	for _, job := range deliveries {
+		params, ok := job.Params.(jobs.NotifyJobParams)
+		if !ok {
+			continue
+		}
+		delivery := params.Delivery
+		if delivery == "" {
+			delivery = string(job.ID)
+		}
+		status := job.Status
+		if result, ok := job.Result.(jobs.NotifyJobResult); ok && result.DeadLetter {
+			status = "Dead letter. " + status
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			delivery,
+			job.Submitted.Format(time.RFC822),
+			params.Event.Type,
+			strings.Join(params.Event.ServiceIDStrings(), ", "),
+			params.NotifierType(),
+			params.URL,
+			params.Attempt+1,
+			status,
+		)
+	}
+	w.Flush()
+	return nil
+}
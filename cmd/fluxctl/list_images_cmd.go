@@ -60,20 +60,33 @@ func (opts *serviceShowOpts) RunE(_ *cobra.Command, args []string) error {
 
 		serviceName := service.ID
 		var lineCount int
+		// a filter that can't be parsed lets nothing through
+		filter, filterErr := flux.ParseTagFilter(string(service.TagFilter))
 		for _, container := range service.Containers {
 			containerName := container.Name
 			reg, repo, currentTag := container.Current.ID.Components()
 			if reg != "" {
 				reg += "/"
 			}
-			fmt.Fprintf(out, "%s\t%s\t%s%s\t\n", serviceName, containerName, reg, repo)
+			var filterNote string
+			if service.TagFilter != flux.TagFilterAll {
+				filterNote = fmt.Sprintf(" (tag filter %s)", service.TagFilter)
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s%s%s\t\n", serviceName, containerName, reg, repo, filterNote)
 			foundRunning := false
 			for _, available := range container.Available {
 				running := "|  "
 				_, _, tag := available.ID.Components()
+				// mark the tags that automation won't consider
+				var excluded string
+				if filterErr != nil || !filter.Matches(tag) {
+					excluded = " (filtered out)"
+				}
 				if currentTag == tag {
 					running = "'->"
 					foundRunning = true
+					// show the digest, if the running image is pinned to one
+					tag = container.Current.ID.TagOrDigest()
 				} else if foundRunning {
 					running = "   "
 				}
@@ -93,7 +106,7 @@ func (opts *serviceShowOpts) RunE(_ *cobra.Command, args []string) error {
 					if available.CreatedAt != nil {
 						createdAt = available.CreatedAt.Format(time.RFC822)
 					}
-					fmt.Fprintf(out, "\t\t%s %s\t%s\n", running, tag, createdAt)
+					fmt.Fprintf(out, "\t\t%s %s%s\t%s\n", running, tag, excluded, createdAt)
 				}
 			}
 			serviceName = ""
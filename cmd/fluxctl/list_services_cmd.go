@@ -46,7 +46,7 @@ func (opts *serviceListOpts) RunE(_ *cobra.Command, args []string) error {
 	for _, s := range services {
 		if len(s.Containers) > 0 {
 			c := s.Containers[0]
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, c.Name, c.Current.ID, s.Status, s.Policies())
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, c.Name, c.Current.ID, s.Status, policies(s))
 			for _, c := range s.Containers[1:] {
 				fmt.Fprintf(w, "\t%s\t%s\t\t\n", c.Name, c.Current.ID)
 			}
@@ -58,6 +58,17 @@ func (opts *serviceListOpts) RunE(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+// policies gives the policies of the service, with the details of
+// the lock if it's locked.
+func policies(s flux.ServiceStatus) string {
+	if s.Locked && s.Lock != nil {
+		if details := s.Lock.String(); details != "" {
+			return fmt.Sprintf("%s (%s)", s.Policies(), details)
+		}
+	}
+	return s.Policies()
+}
+
 type serviceStatusByName []flux.ServiceStatus
 
 func (s serviceStatusByName) Len() int {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 
 	"github.com/weaveworks/flux"
@@ -9,6 +11,8 @@ import (
 type serviceLockOpts struct {
 	*serviceOpts
 	service string
+	expires time.Duration
+	causeOpts
 }
 
 func newServiceLock(parent *serviceOpts) *serviceLockOpts {
@@ -21,10 +25,18 @@ func (opts *serviceLockOpts) Command() *cobra.Command {
 		Short: "Lock a service, so it cannot be deployed.",
 		Example: makeExample(
 			"fluxctl lock --service=helloworld",
+			"fluxctl lock --service=default:deployment/helloworld",
+			`fluxctl lock --service=helloworld --reason="incident 42" --expires=2h`,
 		),
 		RunE: opts.RunE,
 	}
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to lock")
+	cmd.Flags().StringVarP(&opts.message, "reason", "r", "", "why the service is locked, shown with it and recorded in the history")
+	// --message was the flag for the reason, before there was one
+	cmd.Flags().StringVarP(&opts.message, "message", "m", "", "why the service is locked")
+	cmd.Flags().MarkDeprecated("message", "use --reason instead")
+	cmd.Flags().DurationVar(&opts.expires, "expires", 0, "how long until the service is unlocked again, e.g., 2h; by default, it stays locked until unlocked")
+	opts.causeOpts.addUserFlag(cmd)
 	return cmd
 }
 
@@ -41,5 +53,9 @@ func (opts *serviceLockOpts) RunE(_ *cobra.Command, args []string) error {
 		return err
 	}
 
-	return opts.API.Lock(noInstanceID, serviceID)
+	if opts.expires < 0 {
+		return newUsageError("--expires must not be negative")
+	}
+
+	return opts.API.Lock(noInstanceID, serviceID, opts.cause(), opts.expires)
 }
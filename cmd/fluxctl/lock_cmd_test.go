@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+)
+
+func testLockArgs(t *testing.T, args []string, shouldErr bool, errMsg string) *genericMockRoundTripper {
+	svc := newMockService()
+	lockClient := newServiceLock(mockServiceOpts(svc))
+
+	cmd := lockClient.Command()
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); (err == nil) == shouldErr {
+		if errMsg != "" {
+			t.Fatal(errMsg)
+		} else {
+			t.Fatal(err)
+		}
+	}
+	return svc
+}
+
+func TestLockCommand_CLIConversion(t *testing.T) {
+	for _, v := range []struct {
+		args           []string
+		expectedParams map[string]string
+	}{
+		{[]string{"--service=default/helloworld"}, map[string]string{
+			"service": "default/helloworld",
+			"expires": "",
+		}},
+		{[]string{"--service=default/helloworld", "--user=alice", `--reason=incident 42`, "--expires=2h"}, map[string]string{
+			"service": "default/helloworld",
+			"user":    "alice",
+			"message": "incident 42",
+			"expires": "2h0m0s",
+		}},
+		{[]string{"--service=default/helloworld", `--message=incident 42`}, map[string]string{
+			"service": "default/helloworld",
+			"message": "incident 42",
+		}},
+	} {
+		svc := testLockArgs(t, v.args, false, "")
+
+		method := "Lock"
+		if calledURL(method, svc.requestHistory) == nil {
+			t.Fatalf("Expecting fluxctl to request %q, but did not.", method)
+		}
+		vars := calledRequest(method, svc.requestHistory).Vars
+		for kk, vv := range v.expectedParams {
+			assertString(t, vv, vars[kk])
+		}
+	}
+}
+
+func TestLockCommand_InputFailures(t *testing.T) {
+	for _, v := range []struct {
+		args []string
+		msg  string
+	}{
+		{[]string{}, "Should error when no service"},
+		{[]string{"--service=default/helloworld", "--expires=soon"}, "Should error with invalid expiry"},
+		{[]string{"--service=default/helloworld", "--expires=-2h"}, "Should error with negative expiry"},
+	} {
+		testLockArgs(t, v.args, true, v.msg)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type pauseSyncOpts struct {
+	*rootOpts
+}
+
+func newPauseSync(parent *rootOpts) *pauseSyncOpts {
+	return &pauseSyncOpts{rootOpts: parent}
+}
+
+func (opts *pauseSyncOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pause-sync",
+		Short: "Stop applying the definitions in the repo to the cluster, until resumed.",
+		Example: makeExample(
+			"fluxctl pause-sync",
+		),
+		RunE: opts.RunE,
+	}
+	return cmd
+}
+
+func (opts *pauseSyncOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	return opts.API.SetSyncPaused(noInstanceID, true)
+}
@@ -37,12 +37,23 @@ func TestReleaseCommand_CLIConversion(t *testing.T) {
 			"image":   string(flux.ImageSpecLatest),
 			"kind":    string(flux.ReleaseKindExecute),
 		}},
+		{[]string{"--update-all-images", "--service=kube-system:daemonset/agent"}, map[string]string{
+			"service": "kube-system:daemonset/agent",
+			"image":   string(flux.ImageSpecLatest),
+			"kind":    string(flux.ReleaseKindExecute),
+		}},
 		{[]string{"--update-all-images", "--all", "--exclude=default/test,default/yeah"}, map[string]string{
 			"service": string(flux.ServiceSpecAll),
 			"image":   string(flux.ImageSpecLatest),
 			"kind":    string(flux.ReleaseKindExecute),
 			"exclude": "default/test,default/yeah",
 		}},
+		{[]string{"--update-all-images", "--service=default/flux", "--force"}, map[string]string{
+			"service": "default/flux",
+			"image":   string(flux.ImageSpecLatest),
+			"kind":    string(flux.ReleaseKindExecute),
+			"force":   "true",
+		}},
 	} {
 		svc := testArgs(t, v.args, false, "")
 
@@ -99,6 +110,10 @@ func newMockService() *genericMockRoundTripper {
 				Status:    "ok",
 				ReleaseID: "1",
 			},
+			transport.NewRouter().Get("PostRollback"): transport.PostReleaseResponse{
+				Status:    "ok",
+				ReleaseID: "2",
+			},
 			transport.NewRouter().Get("GetRelease"): jobs.Job{
 				Done: true,
 				ID:   "1",
@@ -107,6 +122,7 @@ func newMockService() *genericMockRoundTripper {
 				},
 				Method: jobs.ReleaseJob,
 			},
+			transport.NewRouter().Get("Lock"): nil,
 		},
 	}
 }
@@ -19,6 +19,8 @@ type serviceReleaseOpts struct {
 	noUpdate    bool
 	exclude     []string
 	dryRun      bool
+	force       bool
+	causeOpts
 	serviceReleaseOutputOpts
 }
 
@@ -34,7 +36,9 @@ func (opts *serviceReleaseOpts) Command() *cobra.Command {
 			"fluxctl release --service=default/foo --update-image=library/hello:v2",
 			"fluxctl release --all --update-image=library/hello:v2",
 			"fluxctl release --service=default/foo --update-all-images",
+			"fluxctl release --service=default:daemonset/foo --update-all-images",
 			"fluxctl release --service=default/foo --no-update",
+			`fluxctl release --service=default/foo --update-image=library/hello:v2 --force --message="Fix for incident 42"`,
 		),
 		RunE: opts.RunE,
 	}
@@ -45,9 +49,11 @@ func (opts *serviceReleaseOpts) Command() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.noUpdate, "no-update", false, "don't update images; just deploy the service(s) as configured in the git repo")
 	cmd.Flags().StringSliceVar(&opts.exclude, "exclude", []string{}, "exclude a service")
 	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "do not release anything; just report back what would have been done")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "release services even if they are in a freeze window")
 	cmd.Flags().BoolVar(&opts.noFollow, "no-follow", false, "just submit the release job, don't invoke check-release afterwards")
 	cmd.Flags().BoolVar(&opts.noTty, "no-tty", false, "if not --no-follow, forces simpler, non-TTY status output")
 	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "include ignored services in output")
+	opts.causeOpts.addFlags(cmd)
 	return cmd
 }
 
@@ -117,6 +123,8 @@ func (opts *serviceReleaseOpts) RunE(cmd *cobra.Command, args []string) error {
 		ImageSpec:    image,
 		Kind:         kind,
 		Excludes:     excludes,
+		Cause:        opts.cause(),
+		Force:        opts.force,
 	})
 	if err != nil {
 		return err
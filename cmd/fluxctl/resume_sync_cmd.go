@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type resumeSyncOpts struct {
+	*rootOpts
+}
+
+func newResumeSync(parent *rootOpts) *resumeSyncOpts {
+	return &resumeSyncOpts{rootOpts: parent}
+}
+
+func (opts *resumeSyncOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume-sync",
+		Short: "Resume applying the definitions in the repo to the cluster.",
+		Example: makeExample(
+			"fluxctl resume-sync",
+		),
+		RunE: opts.RunE,
+	}
+	return cmd
+}
+
+func (opts *resumeSyncOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	return opts.API.SetSyncPaused(noInstanceID, false)
+}
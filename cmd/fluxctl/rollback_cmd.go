@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/jobs"
+)
+
+type serviceRollbackOpts struct {
+	*serviceOpts
+	releaseID string
+	dryRun    bool
+	force     bool
+	causeOpts
+	serviceReleaseOutputOpts
+}
+
+func newServiceRollback(parent *serviceOpts) *serviceRollbackOpts {
+	return &serviceRollbackOpts{serviceOpts: parent}
+}
+
+func (opts *serviceRollbackOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Revert the image updates made by a release.",
+		Example: makeExample(
+			"fluxctl rollback --release=1c5b8e2f-5c6e-4b5a-9b3c-6bd1f1b4a0c2",
+			"fluxctl rollback --release=1c5b8e2f-5c6e-4b5a-9b3c-6bd1f1b4a0c2 --dry-run",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.releaseID, "release", "r", "", "ID of the release to roll back")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "do not roll back anything; just report back what would have been done")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "roll back services even if they are in a freeze window")
+	cmd.Flags().BoolVar(&opts.noFollow, "no-follow", false, "just submit the rollback job, don't invoke check-release afterwards")
+	cmd.Flags().BoolVar(&opts.noTty, "no-tty", false, "if not --no-follow, forces simpler, non-TTY status output")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "include ignored services in output")
+	opts.causeOpts.addFlags(cmd)
+	return cmd
+}
+
+func (opts *serviceRollbackOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+	if opts.releaseID == "" {
+		return newUsageError("-r, --release is required")
+	}
+
+	var kind flux.ReleaseKind = flux.ReleaseKindExecute
+	if opts.dryRun {
+		kind = flux.ReleaseKindPlan
+	}
+
+	if opts.dryRun {
+		fmt.Fprintf(os.Stdout, "Submitting dry-run rollback job...\n")
+	} else {
+		fmt.Fprintf(os.Stdout, "Submitting rollback job...\n")
+	}
+
+	id, err := opts.API.PostRollback(noInstanceID, jobs.RollbackJobParams{
+		ReleaseID: flux.ReleaseID(opts.releaseID),
+		Kind:      kind,
+		Cause:     opts.cause(),
+		Force:     opts.force,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Rollback job submitted, ID %s\n", id)
+	if opts.noFollow {
+		fmt.Fprintf(os.Stdout, "To check the status of this rollback job, run\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "\tfluxctl check-release --release-id=%s\n", id)
+		fmt.Fprintf(os.Stdout, "\n")
+		return nil
+	}
+
+	return (&serviceCheckReleaseOpts{
+		serviceOpts:              opts.serviceOpts,
+		releaseID:                string(id),
+		serviceReleaseOutputOpts: opts.serviceReleaseOutputOpts,
+	}).RunE(cmd, nil)
+}
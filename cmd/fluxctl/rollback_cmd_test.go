@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func testRollbackArgs(t *testing.T, args []string, shouldErr bool, errMsg string) *genericMockRoundTripper {
+	svc := newMockService()
+	rollbackClient := newServiceRollback(mockServiceOpts(svc))
+
+	cmd := rollbackClient.Command()
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); (err == nil) == shouldErr {
+		if errMsg != "" {
+			t.Fatal(errMsg)
+		} else {
+			t.Fatal(err)
+		}
+	}
+	return svc
+}
+
+func TestRollbackCommand_CLIConversion(t *testing.T) {
+	for _, v := range []struct {
+		args           []string
+		expectedParams map[string]string
+	}{
+		{[]string{"--release=abc"}, map[string]string{
+			"release": "abc",
+			"kind":    string(flux.ReleaseKindExecute),
+		}},
+		{[]string{"--release=abc", "--dry-run"}, map[string]string{
+			"release": "abc",
+			"kind":    string(flux.ReleaseKindPlan),
+		}},
+		{[]string{"--release=abc", "--user=alice", "--message=Broke the build"}, map[string]string{
+			"release": "abc",
+			"kind":    string(flux.ReleaseKindExecute),
+			"user":    "alice",
+			"message": "Broke the build",
+		}},
+	} {
+		svc := testRollbackArgs(t, v.args, false, "")
+
+		method := "PostRollback"
+		if calledURL(method, svc.requestHistory) == nil {
+			t.Fatalf("Expecting fluxctl to request %q, but did not.", method)
+		}
+		vars := calledRequest(method, svc.requestHistory).Vars
+		for kk, vv := range v.expectedParams {
+			assertString(t, vv, vars[kk])
+		}
+
+		// Check that GetRelease was polled for status
+		method = "GetRelease"
+		if calledURL(method, svc.requestHistory) == nil {
+			t.Fatalf("Expecting fluxctl to request %q, but did not.", method)
+		}
+	}
+}
+
+func TestRollbackCommand_InputFailures(t *testing.T) {
+	for _, v := range []struct {
+		args []string
+		msg  string
+	}{
+		{[]string{}, "Should error when no release given"},
+		{[]string{"--release=abc", "subcommand"}, "Should error when given subcommand"},
+	} {
+		testRollbackArgs(t, v.args, true, v.msg)
+	}
+}
@@ -74,11 +74,19 @@ func (opts *rootOpts) Command() *cobra.Command {
 		newServiceList(svcopts).Command(),
 		newServiceRelease(svcopts).Command(),
 		newServiceCheckRelease(svcopts).Command(),
+		newServiceRollback(svcopts).Command(),
 		newServiceHistory(svcopts).Command(),
 		newServiceAutomate(svcopts).Command(),
 		newServiceDeautomate(svcopts).Command(),
 		newServiceLock(svcopts).Command(),
 		newServiceUnlock(svcopts).Command(),
+		newServiceImageOrder(svcopts).Command(),
+		newServiceTagFilter(svcopts).Command(),
+		newServiceAutoRollback(svcopts).Command(),
+		newPauseSync(opts).Command(),
+		newResumeSync(opts).Command(),
+		newDrift(opts).Command(),
+		newListDeliveries(opts).Command(),
 		newGetConfig(opts).Command(),
 		newSetConfig(opts).Command(),
 	)
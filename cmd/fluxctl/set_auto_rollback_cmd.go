@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+)
+
+type serviceAutoRollbackOpts struct {
+	*serviceOpts
+	service string
+	disable bool
+}
+
+func newServiceAutoRollback(parent *serviceOpts) *serviceAutoRollbackOpts {
+	return &serviceAutoRollbackOpts{serviceOpts: parent}
+}
+
+func (opts *serviceAutoRollbackOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-auto-rollback",
+		Short: "Choose whether a release of a service is rolled back automatically if it fails to apply.",
+		Example: makeExample(
+			"fluxctl set-auto-rollback --service=helloworld",
+			"fluxctl set-auto-rollback --service=helloworld --disable",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to set the rollback policy for")
+	cmd.Flags().BoolVar(&opts.disable, "disable", false, "stop rolling back failed releases of the service")
+	return cmd
+}
+
+func (opts *serviceAutoRollbackOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.service == "" {
+		return newUsageError("-s, --service is required")
+	}
+
+	serviceID, err := flux.ParseServiceID(opts.service)
+	if err != nil {
+		return err
+	}
+
+	return opts.API.SetAutoRollback(noInstanceID, serviceID, !opts.disable)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+)
+
+type serviceImageOrderOpts struct {
+	*serviceOpts
+	service string
+	order   string
+}
+
+func newServiceImageOrder(parent *serviceOpts) *serviceImageOrderOpts {
+	return &serviceImageOrderOpts{serviceOpts: parent}
+}
+
+func (opts *serviceImageOrderOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-image-order",
+		Short: "Choose how the latest image for a service is decided, for automation and --update-all-images.",
+		Example: makeExample(
+			"fluxctl set-image-order --service=helloworld --order=semver",
+			"fluxctl set-image-order --service=helloworld --order=semver-releases",
+			"fluxctl set-image-order --service=helloworld --order=created",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to set the image order for")
+	cmd.Flags().StringVar(&opts.order, "order", "", fmt.Sprintf(
+		"%s: the most recently created image; %s: the highest semantic version tag; %s: as %s, but ignoring prereleases",
+		flux.ImageOrderCreated, flux.ImageOrderSemver, flux.ImageOrderSemverReleases, flux.ImageOrderSemver))
+	return cmd
+}
+
+func (opts *serviceImageOrderOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.service == "" {
+		return newUsageError("-s, --service is required")
+	}
+	if opts.order == "" {
+		return newUsageError("--order is required")
+	}
+
+	serviceID, err := flux.ParseServiceID(opts.service)
+	if err != nil {
+		return err
+	}
+	order, err := flux.ParseImageOrder(opts.order)
+	if err != nil {
+		return err
+	}
+
+	return opts.API.SetImageOrder(noInstanceID, serviceID, order)
+}
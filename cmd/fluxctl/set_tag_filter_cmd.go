@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+)
+
+type serviceTagFilterOpts struct {
+	*serviceOpts
+	service string
+	filter  string
+}
+
+func newServiceTagFilter(parent *serviceOpts) *serviceTagFilterOpts {
+	return &serviceTagFilterOpts{serviceOpts: parent}
+}
+
+func (opts *serviceTagFilterOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-tag-filter",
+		Short: "Restrict the image tags considered for automation and --update-all-images.",
+		Example: makeExample(
+			"fluxctl set-tag-filter --service=helloworld --filter='master-*'",
+			"fluxctl set-tag-filter --service=helloworld --filter='~1.4'",
+			"fluxctl set-tag-filter --service=helloworld --filter='regexp:^v[0-9]+$'",
+			"fluxctl set-tag-filter --service=helloworld --filter=''  # allow all tags",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to set the tag filter for")
+	cmd.Flags().StringVar(&opts.filter, "filter", "", "glob:<pattern>, regexp:<regexp>, or semver:<range>; without a prefix, a semver range if it starts with one of ~^<>=, otherwise a glob")
+	return cmd
+}
+
+func (opts *serviceTagFilterOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.service == "" {
+		return newUsageError("-s, --service is required")
+	}
+	if !cmd.Flags().Changed("filter") {
+		return newUsageError("--filter is required (give --filter='' to allow all tags)")
+	}
+
+	serviceID, err := flux.ParseServiceID(opts.service)
+	if err != nil {
+		return err
+	}
+	filter, err := flux.ParseTagFilter(opts.filter)
+	if err != nil {
+		return err
+	}
+
+	return opts.API.SetTagFilter(noInstanceID, serviceID, filter.TagFilter)
+}
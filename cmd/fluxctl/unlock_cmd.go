@@ -9,6 +9,7 @@ import (
 type serviceUnlockOpts struct {
 	*serviceOpts
 	service string
+	causeOpts
 }
 
 func newServiceUnlock(parent *serviceOpts) *serviceUnlockOpts {
@@ -25,6 +26,7 @@ func (opts *serviceUnlockOpts) Command() *cobra.Command {
 		RunE: opts.RunE,
 	}
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to unlock")
+	opts.causeOpts.addFlags(cmd)
 	return cmd
 }
 
@@ -41,5 +43,5 @@ func (opts *serviceUnlockOpts) RunE(_ *cobra.Command, args []string) error {
 		return err
 	}
 
-	return opts.API.Unlock(noInstanceID, serviceID)
+	return opts.API.Unlock(noInstanceID, serviceID, opts.cause())
 }
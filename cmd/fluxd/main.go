@@ -33,12 +33,13 @@ func main() {
 	}
 	// This mirrors how kubectl extracts information from the environment.
 	var (
-		listenAddr        = fs.StringP("listen", "l", ":3031", "Listen address where /metrics will be served")
-		fluxsvcAddress    = fs.String("fluxsvc-address", "wss://cloud.weave.works/api/flux", "Address of the fluxsvc to connect to.")
-		token             = fs.String("token", "", "Token to use to authenticate with flux service")
-		kubernetesKubectl = fs.String("kubernetes-kubectl", "", "Optional, explicit path to kubectl tool")
-		versionFlag       = fs.Bool("version", false, "Get version number")
+		listenAddr     = fs.StringP("listen", "l", ":3031", "Listen address where /metrics will be served")
+		fluxsvcAddress = fs.String("fluxsvc-address", "wss://cloud.weave.works/api/flux", "Address of the fluxsvc to connect to.")
+		token          = fs.String("token", "", "Token to use to authenticate with flux service")
+		_              = fs.String("kubernetes-kubectl", "", "Optional, explicit path to kubectl tool")
+		versionFlag    = fs.Bool("version", false, "Get version number")
 	)
+	fs.MarkDeprecated("kubernetes-kubectl", "kubectl is no longer used")
 	fs.Parse(os.Args)
 
 	if version == "" {
@@ -75,7 +76,7 @@ func main() {
 		logger := log.NewContext(logger).With("component", "platform")
 		logger.Log("host", restClientConfig.Host)
 
-		cluster, err := kubernetes.NewCluster(restClientConfig, *kubernetesKubectl, version, logger)
+		cluster, err := kubernetes.NewCluster(restClientConfig, version, logger)
 		if err != nil {
 			logger.Log("err", err)
 			os.Exit(1)
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/weaveworks/flux/automator"
 	"github.com/weaveworks/flux/db"
+	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/history"
 	historysql "github.com/weaveworks/flux/history/sql"
 	transport "github.com/weaveworks/flux/http"
@@ -22,6 +24,7 @@ import (
 	"github.com/weaveworks/flux/instance"
 	instancedb "github.com/weaveworks/flux/instance/sql"
 	"github.com/weaveworks/flux/jobs"
+	"github.com/weaveworks/flux/notifications"
 	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/platform/rpc/nats"
 	"github.com/weaveworks/flux/registry"
@@ -53,6 +56,7 @@ func main() {
 		memcachedTimeout      = fs.Duration("memcached-timeout", 100*time.Millisecond, "Maximum time to wait before giving up on memcached requests.")
 		memcachedService      = fs.String("memcached-service", "memcached", "SRV service used to discover memcache servers.")
 		registryCacheExpiry   = fs.Duration("registry-cache-expiry", 20*time.Minute, "Duration to keep cached registry tag info. Must be < 1 month.")
+		gitMirrorDir          = fs.String("git-mirror-dir", filepath.Join(os.TempDir(), "flux-git-mirrors"), "Directory in which to keep a mirror of each config repo, to check out from; mirrors unused for a day are removed. If empty, repos are cloned afresh for each job.")
 		versionFlag           = fs.Bool("version", false, "Get version number")
 	)
 	fs.Parse(os.Args)
@@ -141,28 +145,33 @@ func main() {
 		defer memcacheClient.Stop()
 	}
 
+	// Job store.
+	var jobStore jobs.JobStore
+	{
+		s, err := jobs.NewDatabaseStore(dbDriver, *databaseSource, time.Hour)
+		if err != nil {
+			logger.Log("component", "release job store", "err", err)
+			os.Exit(1)
+		}
+		jobStore = jobs.InstrumentedJobStore(s)
+	}
+
 	var instancer instance.Instancer
 	{
 		// Instancer, for the instancing of operations
-		instancer = &instance.MultitenantInstancer{
+		multi := &instance.MultitenantInstancer{
 			DB:                  instanceDB,
 			Connecter:           messageBus,
 			Logger:              logger,
 			History:             historyDB,
 			MemcacheClient:      memcacheClient,
 			RegistryCacheExpiry: *registryCacheExpiry,
+			Jobs:                jobStore,
 		}
-	}
-
-	// Job store.
-	var jobStore jobs.JobStore
-	{
-		s, err := jobs.NewDatabaseStore(dbDriver, *databaseSource, time.Hour)
-		if err != nil {
-			logger.Log("component", "release job store", "err", err)
-			os.Exit(1)
+		if *gitMirrorDir != "" {
+			multi.GitMirrors = git.NewMirrors(*gitMirrorDir)
 		}
-		jobStore = jobs.InstrumentedJobStore(s)
+		instancer = multi
 	}
 
 	// Automator component.
@@ -185,6 +194,17 @@ func main() {
 
 	go auto.Start(log.NewContext(logger).With("component", "automator"))
 
+	// Syncer component.
+	syncer := release.NewSyncer(instancer, instanceDB, jobStore, log.NewContext(logger).With("component", "syncer"))
+	go syncer.Start()
+
+	// Expired lock remover.
+	unlocker := instance.NewUnlocker(instancer, instanceDB, jobStore, log.NewContext(logger).With("component", "unlocker"))
+	go unlocker.Start()
+
+	// Delivers events to notifiers.
+	deliverer := notifications.NewDeliverer(instanceDB)
+
 	// Job workers.
 	//
 	// Doing one worker (and one queue) for each job type for now. This way slow
@@ -195,11 +215,20 @@ func main() {
 		jobs.DefaultQueue,
 		jobs.ReleaseJob,
 		jobs.AutomatedInstanceJob,
+		jobs.SyncJob, // for sync jobs queued before they went on the release queue
+		jobs.NotifyJob,
+		jobs.UnlockJob,
 	} {
 		logger := log.NewContext(logger).With("component", "worker", "queues", fmt.Sprint([]string{queue}))
 		worker := jobs.NewWorker(jobStore, logger, []string{queue})
 		worker.Register(jobs.AutomatedInstanceJob, auto)
-		worker.Register(jobs.ReleaseJob, release.NewReleaser(instancer))
+		releaser := release.NewReleaser(instancer)
+		worker.Register(jobs.ReleaseJob, releaser)
+		worker.Register(jobs.RollbackJob, releaser)
+		worker.Register(jobs.MergeJob, releaser)
+		worker.Register(jobs.SyncJob, syncer)
+		worker.Register(jobs.NotifyJob, deliverer)
+		worker.Register(jobs.UnlockJob, unlocker)
 
 		defer func() {
 			logger.Log("stopping", "true")
@@ -1,16 +1,27 @@
 package flux
 
 import (
+	"bytes"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"strings"
 
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 	"golang.org/x/crypto/ssh"
 )
 
 const secretReplacement = "******"
 
+// How releases get their changes into the config repo: pushed
+// straight to the branch (the default), or proposed as a pull
+// request, and applied once that's merged.
+const (
+	ReleaseModePush        = "push"
+	ReleaseModePullRequest = "pull-request"
+)
+
 // Instance configuration, mutated via `fluxctl config`. It can be
 // supplied as YAML (hence YAML annotations) and is transported as
 // JSON (hence JSON annotations).
@@ -20,13 +31,131 @@ type GitConfig struct {
 	Path   string `json:"path" yaml:"path"`
 	Branch string `json:"branch" yaml:"branch"`
 	Key    string `json:"key" yaml:"key"`
+	// Username and Password are used instead of the key for a repo
+	// reached over HTTPS. The password may be an access token, in
+	// which case the username can be left out.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// ReleaseMode is ReleaseModePush or ReleaseModePullRequest; if
+	// empty, it's the former.
+	ReleaseMode string `json:"releaseMode,omitempty" yaml:"releaseMode,omitempty"`
+	// KnownHosts are the host keys, in the format of an ssh
+	// known_hosts file, the git host is checked against. If empty,
+	// it's filled in with the host's key on first connecting. A
+	// config update without known hosts keeps those there are;
+	// giving KnownHostsClear clears them.
+	KnownHosts string `json:"knownHosts,omitempty" yaml:"knownHosts,omitempty"`
+	// CommitterName and CommitterEmail are who commits are made as;
+	// if not given, "Weave Flux <support@weave.works>".
+	CommitterName  string `json:"committerName,omitempty" yaml:"committerName,omitempty"`
+	CommitterEmail string `json:"committerEmail,omitempty" yaml:"committerEmail,omitempty"`
+	// SigningKey is an ASCII-armored PGP private key to sign commits
+	// with. If empty, commits aren't signed.
+	SigningKey string `json:"signingKey,omitempty" yaml:"signingKey,omitempty"`
+}
+
+// KnownHostsClear, given as the known hosts in a config update,
+// clears them, so the git host's key is trusted afresh the next time
+// it's connected to.
+const KnownHostsClear = "clear"
+
+// GithubConfig is used to open pull requests, in the pull request
+// release mode, and to record releases as deployments. APIURL is only
+// needed for GitHub Enterprise.
+type GithubConfig struct {
+	Token  string `json:"token,omitempty" yaml:"token,omitempty"`
+	APIURL string `json:"apiURL,omitempty" yaml:"apiURL,omitempty"`
+	// Deployments records each release that pushes a commit as a
+	// GitHub deployment of the commit, with a commit status saying
+	// how it went.
+	Deployments bool `json:"deployments,omitempty" yaml:"deployments,omitempty"`
+	// Environment is what GitHub is told is being deployed to; if
+	// empty, it's DefaultGithubEnvironment.
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
 }
 
+const DefaultGithubEnvironment = "production"
+
+// The types of notifier there are.
+const (
+	NotifierTypeSlack   = "slack"
+	NotifierTypeWebhook = "webhook"
+	NotifierTypeSMTP    = "smtp"
+)
+
 // NotifierConfig is the config used to set up a notifier.
 type NotifierConfig struct {
+	// Type is the kind of notifier, e.g., NotifierTypeSlack; if
+	// empty, it's taken to be Slack, which was the only kind once.
+	Type            string `json:"type,omitempty" yaml:"type,omitempty"`
 	HookURL         string `json:"hookURL" yaml:"hookURL"`
 	Username        string `json:"username" yaml:"username"`
 	ReleaseTemplate string `json:"releaseTemplate" yaml:"releaseTemplate"`
+	// Secret is used by webhook notifiers to sign what they send, so
+	// that the receiver can check it came from here.
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// SMTP is where, and to whom, SMTP notifiers send email.
+	SMTP *SMTPConfig `json:"smtp,omitempty" yaml:"smtp,omitempty"`
+	// Events are the types of event to notify of, e.g.,
+	// EventRelease; if empty, it's all of them.
+	Events []string `json:"events,omitempty" yaml:"events,omitempty"`
+}
+
+// Wants says whether the notifier is to be told of events of the
+// type given.
+func (c NotifierConfig) Wants(eventType string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, t := range c.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifierType gives the type of the notifier, which is Slack if
+// it's not given.
+func (c NotifierConfig) NotifierType() string {
+	if c.Type == "" {
+		return NotifierTypeSlack
+	}
+	return c.Type
+}
+
+// Address gives where the notifier sends things: the hookURL, or for
+// SMTP notifiers, the addresses emailed. Along with the type, it
+// identifies the notifier.
+func (c NotifierConfig) Address() string {
+	if c.NotifierType() == NotifierTypeSMTP && c.SMTP != nil {
+		return strings.Join(append(append([]string{}, c.SMTP.To...), c.SMTP.Cc...), ", ")
+	}
+	return c.HookURL
+}
+
+// The ways of securing the connection to an SMTP server.
+const (
+	// SMTPTLSStartTLS upgrades the connection with STARTTLS, and
+	// insists on the server supporting it. It's the default.
+	SMTPTLSStartTLS = "starttls"
+	// SMTPTLSImplicit uses TLS from the start, as on port 465.
+	SMTPTLSImplicit = "tls"
+	// SMTPTLSNone sends everything in the clear.
+	SMTPTLSNone = "none"
+)
+
+// SMTPConfig is the mail server used by an SMTP notifier, and the
+// addresses to send from and to.
+type SMTPConfig struct {
+	// Server is the host and port, e.g., "smtp.example.com:587".
+	Server   string   `json:"server" yaml:"server"`
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+	TLS      string   `json:"tls,omitempty" yaml:"tls,omitempty"`
+	From     string   `json:"from" yaml:"from"`
+	To       []string `json:"to" yaml:"to"`
+	Cc       []string `json:"cc,omitempty" yaml:"cc,omitempty"`
 }
 
 type RegistryConfig struct {
@@ -41,9 +170,16 @@ type Auth struct {
 }
 
 type InstanceConfig struct {
-	Git      GitConfig      `json:"git" yaml:"git"`
-	Slack    NotifierConfig `json:"slack" yaml:"slack"`
-	Registry RegistryConfig `json:"registry" yaml:"registry"`
+	Git GitConfig `json:"git" yaml:"git"`
+	// Slack is the notifier there was before there could be a list
+	// of them; it's moved into Notifiers when the config is read.
+	Slack     NotifierConfig   `json:"slack" yaml:"slack"`
+	Notifiers []NotifierConfig `json:"notifiers,omitempty" yaml:"notifiers,omitempty"`
+	Registry  RegistryConfig   `json:"registry" yaml:"registry"`
+	Github    GithubConfig     `json:"github,omitempty" yaml:"github,omitempty"`
+	// Freezes are the times during which services aren't released,
+	// unless the release is forced, or synced.
+	Freezes FreezeWindows `json:"freezes,omitempty" yaml:"freezes,omitempty"`
 }
 
 // As a safeguard, we make the default behaviour to hide secrets when
@@ -56,8 +192,54 @@ func (c InstanceConfig) MarshalJSON() ([]byte, error) {
 	return json.Marshal(c.HideSecrets())
 }
 
+// UnmarshalJSON reads the config as it's stored, moving the slack
+// notifier (if there is one) into the list of notifiers.
+func (c *UnsafeInstanceConfig) UnmarshalJSON(data []byte) error {
+	var config InstanceConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	*c = UnsafeInstanceConfig(config.MigrateSlack())
+	return nil
+}
+
+// MigrateSlack moves the notifier in the slack field, if there is
+// one, to the list of notifiers.
+func (c InstanceConfig) MigrateSlack() InstanceConfig {
+	if c.Slack.HookURL == "" {
+		return c
+	}
+	slack := c.Slack
+	if slack.Type == "" {
+		slack.Type = NotifierTypeSlack
+	}
+	c.Notifiers = append([]NotifierConfig{slack}, c.Notifiers...)
+	c.Slack = NotifierConfig{}
+	return c
+}
+
 func (c InstanceConfig) HideSecrets() SafeInstanceConfig {
 	c.Git = c.Git.HideKey()
+	c.Git = c.Git.HideSigningKey()
+	if c.Git.Password != "" {
+		c.Git.Password = secretReplacement
+	}
+	if c.Github.Token != "" {
+		c.Github.Token = secretReplacement
+	}
+	notifiers := make([]NotifierConfig, len(c.Notifiers))
+	for i, notifier := range c.Notifiers {
+		if notifier.Secret != "" {
+			notifier.Secret = secretReplacement
+		}
+		if notifier.SMTP != nil && notifier.SMTP.Password != "" {
+			smtp := *notifier.SMTP
+			smtp.Password = secretReplacement
+			notifier.SMTP = &smtp
+		}
+		notifiers[i] = notifier
+	}
+	c.Notifiers = notifiers
 	for host, auth := range c.Registry.Auths {
 		c.Registry.Auths[host] = auth.HidePassword()
 	}
@@ -101,3 +283,30 @@ func (g GitConfig) HideKey() GitConfig {
 	g.Key = string(ssh.MarshalAuthorizedKey(pubKey))
 	return g
 }
+
+// HideSigningKey replaces the signing key with its public part, which
+// is what's needed to check the signatures (e.g., by GitHub).
+func (g GitConfig) HideSigningKey() GitConfig {
+	if g.SigningKey == "" {
+		return g
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(g.SigningKey))
+	if err != nil || len(entities) == 0 {
+		g.SigningKey = secretReplacement
+		return g
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		g.SigningKey = secretReplacement
+		return g
+	}
+	if err := entities[0].Serialize(w); err != nil {
+		g.SigningKey = secretReplacement
+		return g
+	}
+	w.Close()
+	g.SigningKey = buf.String()
+	return g
+}
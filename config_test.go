@@ -0,0 +1,118 @@
+package flux
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestHideSecrets(t *testing.T) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signingKey bytes.Buffer
+	w, _ := armor.Encode(&signingKey, openpgp.PrivateKeyType, nil)
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	config := UnsafeInstanceConfig{
+		Git: GitConfig{
+			URL:        "https://github.com/weaveworks/flux-example",
+			Password:   "s3cr3t",
+			SigningKey: signingKey.String(),
+		},
+		Notifiers: []NotifierConfig{
+			{Type: NotifierTypeWebhook, HookURL: "https://example.com/hook", Secret: "hmac-s3cr3t"},
+			{Type: NotifierTypeSMTP, SMTP: &SMTPConfig{Server: "smtp.example.com:587", Username: "flux", Password: "smtp-s3cr3t"}},
+		},
+	}
+	safe := InstanceConfig(config).HideSecrets()
+	if safe.Git.Password != secretReplacement {
+		t.Errorf("expected password to be hidden, got %q", safe.Git.Password)
+	}
+	if !strings.Contains(safe.Git.SigningKey, "BEGIN PGP PUBLIC KEY BLOCK") {
+		t.Errorf("expected the public part of the signing key, got %q", safe.Git.SigningKey)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(safe.Git.SigningKey))
+	if err != nil || len(keyring) != 1 || keyring[0].PrivateKey != nil || keyring[0].PrimaryKey.KeyId != entity.PrimaryKey.KeyId {
+		t.Errorf("expected the public key of the signing key, got %#v (err %v)", keyring, err)
+	}
+	if safe.Notifiers[0].Secret != secretReplacement {
+		t.Errorf("expected webhook secret to be hidden, got %q", safe.Notifiers[0].Secret)
+	}
+	if config.Notifiers[0].Secret != "hmac-s3cr3t" {
+		t.Errorf("expected the config's own webhook secret to be left alone, got %q", config.Notifiers[0].Secret)
+	}
+	if safe.Notifiers[1].SMTP.Password != secretReplacement || safe.Notifiers[1].SMTP.Username != "flux" {
+		t.Errorf("expected SMTP password, and only that, to be hidden, got %#v", safe.Notifiers[1].SMTP)
+	}
+	if config.Notifiers[1].SMTP.Password != "smtp-s3cr3t" {
+		t.Errorf("expected the config's own SMTP password to be left alone, got %q", config.Notifiers[1].SMTP.Password)
+	}
+}
+
+func TestMigrateSlack(t *testing.T) {
+	stored := `{
+  "slack": {"hookURL": "https://hooks.slack.com/services/XYZ", "username": "flux"},
+  "notifiers": [{"type": "slack", "hookURL": "https://hooks.slack.com/services/ABC", "events": ["rollback"]}]
+}`
+	var config UnsafeInstanceConfig
+	if err := json.Unmarshal([]byte(stored), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Slack.HookURL != "" {
+		t.Errorf("expected slack field to be emptied, got %#v", config.Slack)
+	}
+	if len(config.Notifiers) != 2 {
+		t.Fatalf("expected the slack notifier to be added to the list, got %#v", config.Notifiers)
+	}
+	if n := config.Notifiers[0]; n.Type != NotifierTypeSlack || n.HookURL != "https://hooks.slack.com/services/XYZ" || n.Username != "flux" {
+		t.Errorf("expected the slack notifier first, got %#v", n)
+	}
+	if n := config.Notifiers[1]; n.Wants(EventRelease) || !n.Wants(EventRollback) {
+		t.Errorf("expected the listed notifier to be kept as it was, got %#v", n)
+	}
+
+	// Once migrated, it stays that way
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again UnsafeInstanceConfig
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Notifiers) != 2 {
+		t.Errorf("expected the notifiers to be unchanged, got %#v", again.Notifiers)
+	}
+}
+
+func TestNotifierAddress(t *testing.T) {
+	for _, c := range []struct {
+		notifier NotifierConfig
+		typ      string
+		address  string
+	}{
+		{NotifierConfig{HookURL: "https://hooks.slack.com/services/XYZ"}, NotifierTypeSlack, "https://hooks.slack.com/services/XYZ"},
+		{NotifierConfig{Type: NotifierTypeWebhook, HookURL: "https://example.com/hook"}, NotifierTypeWebhook, "https://example.com/hook"},
+		{NotifierConfig{Type: NotifierTypeSMTP, SMTP: &SMTPConfig{
+			To: []string{"alice@example.com", "Bob <bob@example.com>"},
+			Cc: []string{"ops@example.com"},
+		}}, NotifierTypeSMTP, "alice@example.com, Bob <bob@example.com>, ops@example.com"},
+	} {
+		if typ := c.notifier.NotifierType(); typ != c.typ {
+			t.Errorf("expected type %q for %#v, got %q", c.typ, c.notifier, typ)
+		}
+		if address := c.notifier.Address(); address != c.address {
+			t.Errorf("expected address %q for %#v, got %q", c.address, c.notifier, address)
+		}
+	}
+}
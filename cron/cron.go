@@ -0,0 +1,202 @@
+// Package cron reads schedules written as in a crontab, so that times
+// can be checked against them.
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidSchedule = errors.New("invalid cron schedule")
+
+// Schedule is a set of times, to the minute, written as five fields
+// as in a crontab:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is a `*`, or a comma-separated list of values and ranges
+// (e.g., `1-5`), either of which may have a step (e.g., `*/15`).
+// Months and days of the week may be given by the first three letters
+// of their names (e.g., `mon-fri`). As in cron, if both the day of the
+// month and the day of the week are restricted, a day matching either
+// will do. `@yearly`, `@monthly`, `@weekly`, `@daily` and `@hourly`
+// are accepted too.
+type Schedule struct {
+	minute, hour, dom, month, dow bits
+	domAny, dowAny                bool
+}
+
+type bits uint64
+
+func (b bits) has(i int) bool {
+	return b&(1<<uint(i)) != 0
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes     = field{0, 59, nil}
+	hours       = field{0, 23, nil}
+	daysOfMonth = field{1, 31, nil}
+	months      = field{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is both 0 and 7.
+	daysOfWeek = field{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, errors.Wrapf(ErrInvalidSchedule, "%q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	var s Schedule
+	var err error
+	for i, f := range []struct {
+		bits *bits
+		field
+	}{
+		{&s.minute, minutes},
+		{&s.hour, hours},
+		{&s.dom, daysOfMonth},
+		{&s.month, months},
+		{&s.dow, daysOfWeek},
+	} {
+		if *f.bits, err = parseField(fields[i], f.field); err != nil {
+			return Schedule{}, errors.Wrapf(ErrInvalidSchedule, "%q: %s", spec, err)
+		}
+	}
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+func parseField(s string, f field) (bits, error) {
+	var b bits
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		hasStep := false
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, errors.Errorf("invalid step in %q", part)
+			}
+			rng, step, hasStep = part[:i], n, true
+		}
+
+		var lo, hi int
+		var err error
+		switch {
+		case rng == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+		default:
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// As in cron, "5/10" means every tenth from 5 on.
+			if hasStep {
+				hi = f.max
+			}
+		}
+		if lo > hi {
+			return 0, errors.Errorf("range %q goes backwards", rng)
+		}
+		for i := lo; i <= hi; i += step {
+			b |= 1 << uint(i)
+		}
+	}
+	return b, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if n, ok := f.names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("%q is not a number", s)
+	}
+	if n < f.min || n > f.max {
+		return 0, errors.Errorf("%d is not between %d and %d", n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Matches says whether the minute the time given falls in is in the
+// schedule.
+func (s Schedule) Matches(t time.Time) bool {
+	return s.dayMatches(t) && s.hour.has(t.Hour()) && s.minute.has(t.Minute())
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	if !s.month.has(int(t.Month())) {
+		return false
+	}
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Last gives the latest minute in the schedule at or before the time
+// given, looking back as far as since (and no further). If there is
+// no such minute, it returns false.
+func (s Schedule) Last(t, since time.Time) (time.Time, bool) {
+	for m := t.Truncate(time.Minute); !m.Before(since); {
+		y, mon, d := m.Date()
+		switch {
+		case !s.dayMatches(m):
+			m = time.Date(y, mon, d, 0, 0, 0, 0, m.Location()).Add(-time.Minute)
+		case !s.hour.has(m.Hour()):
+			m = time.Date(y, mon, d, m.Hour(), 0, 0, 0, m.Location()).Add(-time.Minute)
+		case !s.minute.has(m.Minute()):
+			m = m.Add(-time.Minute)
+		default:
+			return m, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,83 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+// 2017-06-02 was a Friday.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2017, time.June, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * * someday",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error parsing %q", spec)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	for _, x := range []struct {
+		spec    string
+		in, out []time.Time
+	}{
+		{"* * * * *", []time.Time{at(2, 0, 0), at(4, 23, 59)}, nil},
+		{"30 18 * * fri", []time.Time{at(2, 18, 30), at(9, 18, 30)}, []time.Time{at(2, 18, 31), at(3, 18, 30)}},
+		{"0 9-17/4 * * mon-fri", []time.Time{at(5, 9, 0), at(5, 13, 0), at(5, 17, 0)}, []time.Time{at(5, 11, 0), at(3, 9, 0)}},
+		{"0 0 * * 7", []time.Time{at(4, 0, 0)}, []time.Time{at(3, 0, 0)}},
+		{"*/15 * * jun *", []time.Time{at(2, 10, 45)}, []time.Time{at(2, 10, 50)}},
+		// Either the day of the month or the day of the week will do
+		{"0 0 1 * sat", []time.Time{at(1, 0, 0), at(3, 0, 0)}, []time.Time{at(2, 0, 0)}},
+		{"@daily", []time.Time{at(2, 0, 0)}, []time.Time{at(2, 0, 1)}},
+	} {
+		s, err := Parse(x.spec)
+		if err != nil {
+			t.Errorf("parsing %q: %v", x.spec, err)
+			continue
+		}
+		for _, tm := range x.in {
+			if !s.Matches(tm) {
+				t.Errorf("expected %q to match %s", x.spec, tm)
+			}
+		}
+		for _, tm := range x.out {
+			if s.Matches(tm) {
+				t.Errorf("expected %q not to match %s", x.spec, tm)
+			}
+		}
+	}
+}
+
+func TestLast(t *testing.T) {
+	s, err := Parse("0 18 * * fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sunday afternoon, looking back three days finds Friday evening
+	last, ok := s.Last(at(4, 15, 20), at(1, 15, 20))
+	if !ok || !last.Equal(at(2, 18, 0)) {
+		t.Errorf("expected %s, got %s (found: %v)", at(2, 18, 0), last, ok)
+	}
+	// ... and looking back one day finds nothing
+	if last, ok := s.Last(at(4, 15, 20), at(3, 15, 20)); ok {
+		t.Errorf("expected nothing, got %s", last)
+	}
+	// The minute given counts
+	if last, ok := s.Last(at(2, 18, 0).Add(30*time.Second), at(2, 18, 0)); !ok || !last.Equal(at(2, 18, 0)) {
+		t.Errorf("expected %s, got %s (found: %v)", at(2, 18, 0), last, ok)
+	}
+}
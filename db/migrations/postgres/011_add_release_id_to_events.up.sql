@@ -0,0 +1,10 @@
+-- So that a release can be looked up by its ID, rather than by
+-- reading through the whole history.
+ALTER TABLE events
+  ADD COLUMN release_id text;
+
+UPDATE events
+  SET release_id = metadata->'release'->>'id'
+  WHERE type IN ('release', 'rollback') AND metadata IS NOT NULL;
+
+CREATE INDEX events_release_id_idx ON events (instance_id, release_id);
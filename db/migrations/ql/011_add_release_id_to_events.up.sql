@@ -0,0 +1,7 @@
+-- So that a release can be looked up by its ID, rather than by
+-- reading through the whole history. ql can't get at the ID in the
+-- metadata, so releases recorded before this can't be looked up.
+ALTER TABLE events
+  ADD release_id string;
+
+CREATE INDEX IF NOT EXISTS events_release_id ON events (release_id);
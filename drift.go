@@ -0,0 +1,26 @@
+package flux
+
+// ServiceDrift reports how a service running differs from its
+// definition in the config repo. If the definition couldn't be read,
+// Error says why, and there are no differences.
+type ServiceDrift struct {
+	ID          ServiceID    `json:"id"`
+	Error       string       `json:"error,omitempty"`
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+func (d ServiceDrift) Drifted() bool {
+	return len(d.Differences) > 0
+}
+
+// A Difference is a field that has one value in a definition and
+// another in the running system, e.g., the image of a container, or
+// the value of an environment variable ("env GREETING"). Container is
+// empty for fields of the controller itself, like "replicas". A value
+// of "<none>" means the field isn't set on that side.
+type Difference struct {
+	Container string `json:"container,omitempty"`
+	Field     string `json:"field"`
+	Defined   string `json:"defined"`
+	Running   string `json:"running"`
+}
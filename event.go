@@ -1,6 +1,7 @@
 package flux
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -10,10 +11,12 @@ import (
 // These are all the types of events.
 const (
 	EventRelease    = "release"
+	EventRollback   = "rollback"
 	EventAutomate   = "automate"
 	EventDeautomate = "deautomate"
 	EventLock       = "lock"
 	EventUnlock     = "unlock"
+	EventSync       = "sync"
 
 	LogLevelDebug = "debug"
 	LogLevelInfo  = "info"
@@ -55,6 +58,54 @@ type Event struct {
 	Metadata interface{} `json:"metadata,omitempty"`
 }
 
+// UnmarshalJSON decodes the metadata as the type that goes with the
+// type of event, so it can be used as it was before it was encoded.
+func (e *Event) UnmarshalJSON(in []byte) error {
+	type EventAlias Event
+	var wireEvent struct {
+		*EventAlias
+		Metadata json.RawMessage `json:"metadata,omitempty"`
+	}
+	wireEvent.EventAlias = (*EventAlias)(e)
+	if err := json.Unmarshal(in, &wireEvent); err != nil {
+		return err
+	}
+	metadata, err := DecodeEventMetadata(e.Type, wireEvent.Metadata)
+	if err != nil {
+		return err
+	}
+	e.Metadata = metadata
+	return nil
+}
+
+// DecodeEventMetadata decodes the metadata of an event of the type
+// given, as the type that goes with that, so it can be used as it was
+// before it was encoded. The metadata of other types of event is
+// decoded generically; empty metadata gives nil.
+func DecodeEventMetadata(eventType string, data []byte) (interface{}, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	switch eventType {
+	case EventRelease, EventRollback:
+		var m ReleaseEventMetadata
+		err := json.Unmarshal(data, &m)
+		return m, err
+	case EventSync:
+		var m SyncEventMetadata
+		err := json.Unmarshal(data, &m)
+		return m, err
+	case EventLock, EventUnlock, EventAutomate, EventDeautomate:
+		var m PolicyEventMetadata
+		err := json.Unmarshal(data, &m)
+		return m, err
+	default:
+		var m interface{}
+		err := json.Unmarshal(data, &m)
+		return m, err
+	}
+}
+
 func (e Event) ServiceIDStrings() []string {
 	var strServiceIDs []string
 	for _, serviceID := range e.ServiceIDs {
@@ -64,6 +115,18 @@ func (e Event) ServiceIDStrings() []string {
 	return strServiceIDs
 }
 
+// Cause gives who made the change recorded by the event, and why, if
+// that's known.
+func (e Event) Cause() Cause {
+	switch metadata := e.Metadata.(type) {
+	case ReleaseEventMetadata:
+		return metadata.Release.Spec.Cause
+	case PolicyEventMetadata:
+		return metadata.Cause
+	}
+	return Cause{}
+}
+
 func (e Event) String() string {
 	if e.Message != "" {
 		return e.Message
@@ -86,28 +149,89 @@ func (e Event) String() string {
 		if len(strServiceIDs) == 0 {
 			strServiceIDs = []string{"no services"}
 		}
+		if metadata.Release.Status == ReleaseStatusAwaitingMerge {
+			return fmt.Sprintf(
+				"Proposed release: %s to %s, in %s%s",
+				strings.Join(strImageIDs, ", "),
+				strings.Join(strServiceIDs, ", "),
+				metadata.Release.PullRequestURL,
+				forced(metadata.Release.Spec),
+			)
+		}
 		return fmt.Sprintf(
-			"Released: %s to %s",
+			"Released: %s to %s%s",
 			strings.Join(strImageIDs, ", "),
 			strings.Join(strServiceIDs, ", "),
+			forced(metadata.Release.Spec),
+		)
+	case EventRollback:
+		metadata := e.Metadata.(ReleaseEventMetadata)
+		strImageIDs := metadata.Release.Result.ImageIDs()
+		if len(strImageIDs) == 0 {
+			strImageIDs = []string{"no image changes"}
+		}
+		if len(strServiceIDs) == 0 {
+			strServiceIDs = []string{"no services"}
+		}
+		return fmt.Sprintf(
+			"Rolled back release %s: %s to %s%s",
+			metadata.Release.RollbackOf,
+			strings.Join(strImageIDs, ", "),
+			strings.Join(strServiceIDs, ", "),
+			forced(metadata.Release.Spec),
 		)
 	case EventAutomate:
 		return fmt.Sprintf("Automated: %s", strings.Join(strServiceIDs, ", "))
 	case EventDeautomate:
 		return fmt.Sprintf("Deautomated: %s", strings.Join(strServiceIDs, ", "))
 	case EventLock:
+		if metadata, ok := e.Metadata.(PolicyEventMetadata); ok && metadata.Expires != nil {
+			return fmt.Sprintf("Locked: %s until %s", strings.Join(strServiceIDs, ", "), metadata.Expires.UTC().Format(LockTimeFormat))
+		}
 		return fmt.Sprintf("Locked: %s", strings.Join(strServiceIDs, ", "))
 	case EventUnlock:
 		return fmt.Sprintf("Unlocked: %s", strings.Join(strServiceIDs, ", "))
+	case EventSync:
+		metadata, _ := e.Metadata.(SyncEventMetadata)
+		if metadata.Error != "" {
+			return fmt.Sprintf("Sync failed: %s (%s)", strings.Join(strServiceIDs, ", "), metadata.Error)
+		}
+		return fmt.Sprintf("Synced: %s", strings.Join(strServiceIDs, ", "))
 	default:
 		return "Unknown event"
 	}
 }
 
-// ReleaseEventMetadata is the metadata for when service(s) are released
+// forced marks a release that was forced through any freeze windows.
+func forced(spec ReleaseSpec) string {
+	if spec.Force {
+		return " (forced)"
+	}
+	return ""
+}
+
+// ReleaseEventMetadata is the metadata for when service(s) are
+// released, or a release is rolled back
 type ReleaseEventMetadata struct {
 	// Release points to this release
 	Release Release `json:"release"`
 	// Message of the error if there was one.
 	Error string `json:"error,omitempty"`
 }
+
+// PolicyEventMetadata is the metadata for when a service is locked
+// or unlocked, or automated or deautomated.
+type PolicyEventMetadata struct {
+	Cause Cause `json:"cause"`
+	// Expires is when a lock runs out, if it does.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// SyncEventMetadata is the metadata for when services are brought
+// back in line with their definitions in the repo.
+type SyncEventMetadata struct {
+	// Result says what happened to each service applied
+	Result ReleaseResult `json:"result"`
+	// Message of the error if there was one.
+	Error string `json:"error,omitempty"`
+}
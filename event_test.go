@@ -0,0 +1,34 @@
+package flux
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventMetadataEncoding(t *testing.T) {
+	expires := time.Date(2017, 6, 1, 14, 30, 0, 0, time.UTC)
+	for _, event := range []Event{
+		{Type: EventRelease, Metadata: ReleaseEventMetadata{
+			Release: Release{ID: ReleaseID("abc"), Status: ReleaseStatusFailed},
+			Error:   "timed out",
+		}},
+		{Type: EventRollback, Metadata: ReleaseEventMetadata{Release: Release{RollbackOf: ReleaseID("abc")}}},
+		{Type: EventSync, Metadata: SyncEventMetadata{Error: "no such service"}},
+		{Type: EventLock, Metadata: PolicyEventMetadata{Cause: Cause{User: "alice"}, Expires: &expires}},
+		{Type: EventAutomate},
+	} {
+		bytes, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Event
+		if err := json.Unmarshal(bytes, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(event.Metadata, decoded.Metadata) {
+			t.Errorf("expected metadata %#v, got %#v", event.Metadata, decoded.Metadata)
+		}
+	}
+}
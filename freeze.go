@@ -0,0 +1,146 @@
+package flux
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/cron"
+)
+
+var ErrInvalidFreezeWindow = errors.New("invalid freeze window")
+
+// FreezeWindow is a time during which services aren't released. It
+// either recurs, starting each time the cron schedule given matches
+// and lasting for the duration given (e.g., "0 18 * * fri" and "63h"
+// for weekends), or is a one-off, between two times (in RFC3339
+// format). It covers every namespace, unless it's given some.
+type FreezeWindow struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	Cron     string `json:"cron,omitempty" yaml:"cron,omitempty"`
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// Timezone is the location the cron schedule is in, e.g.,
+	// "Europe/London"; if not given, it's UTC.
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+	To   string `json:"to,omitempty" yaml:"to,omitempty"`
+
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+// Validate checks that the window is either recurring or one-off,
+// and that its times can be made sense of.
+func (w FreezeWindow) Validate() error {
+	_, err := w.activeUntil(time.Now())
+	return err
+}
+
+// activeUntil gives the time the window ends, if it's in force at the
+// time given; otherwise, the zero time.
+func (w FreezeWindow) activeUntil(now time.Time) (time.Time, error) {
+	recurring, oneOff := w.Cron != "" || w.Duration != "", w.From != "" || w.To != ""
+	switch {
+	case recurring && oneOff:
+		return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s has both a cron schedule and from/to times", w)
+	case recurring:
+		schedule, err := cron.Parse(w.Cron)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s: %s", w, err)
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil || duration <= 0 {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s: expected a duration after the cron schedule, e.g., \"2h\", got %q", w, w.Duration)
+		}
+		location, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s: unknown timezone %q", w, w.Timezone)
+		}
+		now = now.In(location)
+		if start, ok := schedule.Last(now, now.Add(-duration)); ok && now.Before(start.Add(duration)) {
+			return start.Add(duration), nil
+		}
+		return time.Time{}, nil
+	case oneOff:
+		from, err := time.Parse(time.RFC3339, w.From)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s: expected from time like \"2017-12-22T18:00:00Z\", got %q", w, w.From)
+		}
+		to, err := time.Parse(time.RFC3339, w.To)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s: expected to time like \"2018-01-02T09:00:00Z\", got %q", w, w.To)
+		}
+		if !to.After(from) {
+			return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s ends before it starts", w)
+		}
+		if !now.Before(from) && now.Before(to) {
+			return to, nil
+		}
+		return time.Time{}, nil
+	default:
+		return time.Time{}, errors.Wrapf(ErrInvalidFreezeWindow, "%s needs either a cron schedule and duration, or from and to times", w)
+	}
+}
+
+// Covers says whether the window applies to the namespace given.
+func (w FreezeWindow) Covers(namespace string) bool {
+	if len(w.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range w.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (w FreezeWindow) String() string {
+	if w.Name != "" {
+		return fmt.Sprintf("freeze window %q", w.Name)
+	}
+	if w.Cron != "" {
+		return fmt.Sprintf("freeze window %q for %s", w.Cron, w.Duration)
+	}
+	return fmt.Sprintf("freeze window from %s to %s", w.From, w.To)
+}
+
+// Freeze is a freeze window that's in force, and when it ends (if
+// that's known).
+type Freeze struct {
+	Window FreezeWindow
+	Until  time.Time
+}
+
+// Describe gives a short description of the freeze, e.g., for saying
+// why a service was skipped.
+func (f Freeze) Describe() string {
+	if f.Until.IsZero() {
+		return fmt.Sprintf("frozen (%s)", f.Window)
+	}
+	return fmt.Sprintf("frozen (%s, until %s)", f.Window, f.Until.UTC().Format(LockTimeFormat))
+}
+
+type FreezeWindows []FreezeWindow
+
+// Frozen gives the first of the windows that covers the namespace and
+// is in force at the time given, if there is one. Windows that can't
+// be made sense of are taken to be in force, so that a mistake in
+// them doesn't let releases through.
+func (ws FreezeWindows) Frozen(namespace string, now time.Time) (Freeze, bool) {
+	for _, w := range ws {
+		if !w.Covers(namespace) {
+			continue
+		}
+		until, err := w.activeUntil(now)
+		if err != nil {
+			return Freeze{Window: w}, true
+		}
+		if !until.IsZero() {
+			return Freeze{Window: w, Until: until}, true
+		}
+	}
+	return Freeze{}, false
+}
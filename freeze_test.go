@@ -0,0 +1,99 @@
+package flux
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreezeWindowValidate(t *testing.T) {
+	for _, w := range []FreezeWindow{
+		{},
+		{Cron: "0 18 * * fri"},
+		{Cron: "0 18 * * someday", Duration: "63h"},
+		{Cron: "0 18 * * fri", Duration: "-1h"},
+		{Cron: "0 18 * * fri", Duration: "63h", Timezone: "Nowhere/Special"},
+		{From: "2017-12-22T18:00:00Z"},
+		{From: "22 Dec 2017", To: "2018-01-02T09:00:00Z"},
+		{From: "2018-01-02T09:00:00Z", To: "2017-12-22T18:00:00Z"},
+		{Cron: "0 18 * * fri", Duration: "63h", From: "2017-12-22T18:00:00Z", To: "2018-01-02T09:00:00Z"},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Errorf("expected %#v to be invalid", w)
+		}
+	}
+	for _, w := range []FreezeWindow{
+		{Cron: "0 18 * * fri", Duration: "63h"},
+		{Cron: "0 18 * * fri", Duration: "63h", Timezone: "UTC"},
+		{From: "2017-12-22T18:00:00Z", To: "2018-01-02T09:00:00Z"},
+	} {
+		if err := w.Validate(); err != nil {
+			t.Errorf("expected %#v to be valid, got %v", w, err)
+		}
+	}
+}
+
+func TestFreezeWindowsFrozen(t *testing.T) {
+	weekends := FreezeWindow{Name: "weekends", Cron: "0 18 * * fri", Duration: "63h"}
+	holidays := FreezeWindow{
+		Name:       "holidays",
+		From:       "2017-12-22T18:00:00Z",
+		To:         "2018-01-02T09:00:00Z",
+		Namespaces: []string{"default"},
+	}
+	windows := FreezeWindows{weekends, holidays}
+
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	for _, x := range []struct {
+		namespace string
+		now       string
+		frozen    bool
+		window    string
+		until     string
+	}{
+		// Thursday
+		{"default", "2017-06-01T12:00:00Z", false, "", ""},
+		// Friday evening, Sunday, and first thing Monday
+		{"default", "2017-06-02T18:00:00Z", true, "weekends", "2017-06-05T09:00:00Z"},
+		{"monitoring", "2017-06-04T12:00:00Z", true, "weekends", "2017-06-05T09:00:00Z"},
+		{"default", "2017-06-05T09:00:00Z", false, "", ""},
+		// A Wednesday in the holidays, for only the default namespace
+		{"default", "2017-12-27T12:00:00Z", true, "holidays", "2018-01-02T09:00:00Z"},
+		{"monitoring", "2017-12-27T12:00:00Z", false, "", ""},
+	} {
+		freeze, frozen := windows.Frozen(x.namespace, at(x.now))
+		if frozen != x.frozen {
+			t.Errorf("%s in %s: expected frozen = %v, got %v", x.now, x.namespace, x.frozen, frozen)
+			continue
+		}
+		if frozen && (freeze.Window.Name != x.window || !freeze.Until.Equal(at(x.until))) {
+			t.Errorf("%s in %s: expected frozen by %s until %s, got %#v", x.now, x.namespace, x.window, x.until, freeze)
+		}
+	}
+}
+
+func TestFreezeWindowTimezone(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	w := FreezeWindows{{Cron: "0 18 * * fri", Duration: "1h", Timezone: "America/New_York"}}
+	// 18:30 on a Friday in New York is 22:30 UTC, in the summer
+	if _, frozen := w.Frozen("default", time.Date(2017, 6, 2, 18, 30, 0, 0, location)); !frozen {
+		t.Error("expected window to be in force at 18:30 in New York")
+	}
+	if _, frozen := w.Frozen("default", time.Date(2017, 6, 2, 18, 30, 0, 0, time.UTC)); frozen {
+		t.Error("expected window not to be in force at 18:30 UTC")
+	}
+}
+
+func TestFreezeWindowsFrozenInvalid(t *testing.T) {
+	// A window that can't be made sense of holds everything up,
+	// rather than letting it through.
+	windows := FreezeWindows{{Name: "broken", Cron: "whenever"}}
+	if freeze, frozen := windows.Frozen("default", time.Now()); !frozen || freeze.Describe() != `frozen (freeze window "broken")` {
+		t.Errorf("expected invalid window to be in force, got %v %#v", frozen, freeze)
+	}
+}
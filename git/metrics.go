@@ -0,0 +1,24 @@
+package git
+
+import (
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+var (
+	fetchDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "flux",
+		Subsystem: "fluxsvc",
+		Name:      "git_fetch_duration_seconds",
+		Help:      "Duration in seconds of bringing a repo mirror up to date, including cloning it the first time.",
+		Buckets:   stdprometheus.DefBuckets,
+	}, []string{fluxmetrics.LabelSuccess})
+	mirrorsSize = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "fluxsvc",
+		Name:      "git_mirrors_size_bytes",
+		Help:      "Total size on disk of the repo mirrors.",
+	}, []string{})
+)
@@ -0,0 +1,235 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+// Mirrors keeps a bare copy of each remote repo in a local directory,
+// so that rather than cloning a repo afresh for each job, only what's
+// changed since last time need be fetched. Each job then gets its own
+// worktree, checked out from the mirror.
+//
+// The mirror of a repo is shared by everything that uses the same URL
+// and key; i.e., in practice, it belongs to an instance. It's safe to
+// check out worktrees from the same mirror concurrently.
+//
+// Mirrors that haven't been checked out from for a while (e.g.,
+// because the instance's key or credentials have changed, so it's
+// using another mirror) are removed.
+type Mirrors struct {
+	dir string
+
+	mu          sync.Mutex
+	mirrors     map[string]*mirror
+	lastEvicted time.Time
+}
+
+type mirror struct {
+	dir string
+
+	// Fetching and adding worktrees both update the mirror, so they
+	// take turns.
+	mu   sync.Mutex
+	size int64
+	// When the mirror was last checked out from; guarded by the
+	// Mirrors' mu, not the mirror's.
+	lastUsed time.Time
+}
+
+// How long a mirror can go unused before it's removed, and how often
+// to look for such mirrors.
+var (
+	mirrorExpiry        = 24 * time.Hour
+	mirrorEvictInterval = time.Hour
+)
+
+func NewMirrors(dir string) *Mirrors {
+	return &Mirrors{
+		dir:     dir,
+		mirrors: map[string]*mirror{},
+	}
+}
+
+// checkout brings the mirror of the repo up to date, then checks out
+// the repo's branch into a new worktree at path. The worktree is
+// detached, so that any number can be checked out at once; pushing
+// from it must name the branch to push to. If the mirror turns out to
+// be corrupt, it's removed and cloned afresh.
+func (m *Mirrors) checkout(r Repo, s *session, path string) error {
+	mirror := m.mirrorFor(r)
+	mirror.mu.Lock()
+	defer mirror.mu.Unlock()
+
+	ref := "HEAD"
+	if r.Branch != "" {
+		ref = "refs/heads/" + r.Branch
+	}
+	err := mirror.fetch(s, r.URL)
+	if err == nil {
+		err = mirror.addWorktree(path, ref)
+	}
+	if err != nil && mirror.corrupt() {
+		if rmErr := os.RemoveAll(mirror.dir); rmErr != nil {
+			return err
+		}
+		os.RemoveAll(path)
+		if err = mirror.fetch(s, r.URL); err == nil {
+			err = mirror.addWorktree(path, ref)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m.recordSize(mirror)
+	m.evictUnused(time.Now())
+	return nil
+}
+
+// mirrorFor gives the mirror for a repo, which is named for its URL
+// and key or credentials; using a different key means a different
+// mirror, so a key without access to a repo can't be used to read the
+// mirror of it.
+func (m *Mirrors) mirrorFor(r Repo) *mirror {
+	hash := sha256.Sum256([]byte(strings.Join([]string{r.URL, r.Key, r.Username, r.Password}, "\n")))
+	name := hex.EncodeToString(hash[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mir, ok := m.mirrors[name]
+	if !ok {
+		mir = &mirror{dir: filepath.Join(m.dir, name)}
+		m.mirrors[name] = mir
+	}
+	mir.lastUsed = time.Now()
+	return mir
+}
+
+// recordSize exports the size of all the mirrors, having updated the
+// size of the one given.
+func (m *Mirrors) recordSize(updated *mirror) {
+	size, err := dirSize(updated.dir)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	updated.size = size
+	m.recordTotalSize()
+}
+
+// recordTotalSize exports the size of all the mirrors. It must be
+// called with mu held.
+func (m *Mirrors) recordTotalSize() {
+	var total int64
+	for _, mirror := range m.mirrors {
+		total += mirror.size
+	}
+	mirrorsSize.Set(float64(total))
+}
+
+// evictUnused removes the mirrors that haven't been checked out from
+// within mirrorExpiry of now, including those left in the directory
+// from before a restart; it looks at most once every
+// mirrorEvictInterval. Anything checking out a mirror records that
+// it's used first, so a mirror found to be unused isn't being checked
+// out from.
+func (m *Mirrors) evictUnused(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastEvicted) < mirrorEvictInterval {
+		return
+	}
+	m.lastEvicted = now
+
+	entries, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		return
+	}
+	expired := now.Add(-mirrorExpiry)
+	for _, entry := range entries {
+		name := entry.Name()
+		lastUsed := entry.ModTime()
+		if mirror, ok := m.mirrors[name]; ok {
+			lastUsed = mirror.lastUsed
+		}
+		if lastUsed.After(expired) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.dir, name)); err != nil {
+			continue
+		}
+		delete(m.mirrors, name)
+	}
+	m.recordTotalSize()
+}
+
+// fetch gets the branches from the remote repo, cloning it first if
+// there's no mirror yet. Worktrees that have since been removed are
+// forgotten at the same time.
+func (m *mirror) fetch(s *session, url string) (err error) {
+	defer func(started time.Time) {
+		fetchDuration.With(fluxmetrics.LabelSuccess, fmt.Sprint(err == nil)).Observe(time.Since(started).Seconds())
+	}(time.Now())
+
+	if _, err := os.Stat(m.dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(m.dir), 0700); err != nil {
+			return err
+		}
+		if err := execGitCmd("", s, "clone", "--bare", url, m.dir); err != nil {
+			os.RemoveAll(m.dir)
+			return errors.Wrap(err, "git clone --bare")
+		}
+		return nil
+	}
+
+	if err := execGitCmd(m.dir, nil, "worktree", "prune"); err != nil {
+		return errors.Wrap(err, "git worktree prune")
+	}
+	if err := execGitCmd(m.dir, s, "fetch", "--prune", "--update-head-ok", url, "+refs/heads/*:refs/heads/*"); err != nil {
+		return errors.Wrap(err, "git fetch")
+	}
+	return nil
+}
+
+func (m *mirror) addWorktree(path, ref string) error {
+	if err := execGitCmd(m.dir, nil, "worktree", "add", "--detach", path, ref); err != nil {
+		return errors.Wrap(err, "git worktree add")
+	}
+	return nil
+}
+
+// corrupt checks the mirror, after something's failed, to see whether
+// that's because the mirror itself is broken (e.g., by a fetch being
+// interrupted), rather than, say, the remote repo being unavailable.
+func (m *mirror) corrupt() bool {
+	if _, err := os.Stat(m.dir); os.IsNotExist(err) {
+		return false
+	}
+	return execGitCmd(m.dir, nil, "fsck", "--connectivity-only", "--no-dangling") != nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
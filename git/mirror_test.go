@@ -0,0 +1,238 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, dir string, args ...string) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %s\n%s", args, err, out)
+	}
+}
+
+// setupRemote makes a bare repo with a file in it, to stand in for a
+// remote repo.
+func setupRemote(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "flux-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := filepath.Join(dir, "files")
+	remote := filepath.Join(dir, "remote")
+	os.Mkdir(files, 0700)
+	run(t, files, "init")
+	if err := ioutil.WriteFile(filepath.Join(files, "file.yaml"), []byte("version: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	run(t, files, "add", "--all")
+	run(t, files, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-m", "Initial revision")
+	run(t, files, "branch", "-M", "master")
+	run(t, "", "clone", "--bare", files, remote)
+	return remote, func() { os.RemoveAll(dir) }
+}
+
+func readFile(t *testing.T, repoDir string) string {
+	bytes, err := ioutil.ReadFile(filepath.Join(repoDir, "file.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+func TestMirrorCheckout(t *testing.T) {
+	remote, cleanup := setupRemote(t)
+	defer cleanup()
+	mirrorDir, _ := ioutil.TempDir("", "flux-mirrors")
+	defer os.RemoveAll(mirrorDir)
+
+	repo := Repo{URL: remote, Branch: "master", Mirrors: NewMirrors(mirrorDir)}
+
+	// Jobs can check out the repo at the same time
+	var wg sync.WaitGroup
+	paths := make([]string, 4)
+	errs := make([]error, 4)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = repo.Clone()
+		}(i)
+	}
+	wg.Wait()
+	for i, path := range paths {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		defer os.RemoveAll(filepath.Dir(path))
+		if content := readFile(t, path); content != "version: 1\n" {
+			t.Errorf("expected file to be checked out, got %q", content)
+		}
+	}
+	if entries, _ := ioutil.ReadDir(mirrorDir); len(entries) != 1 {
+		t.Errorf("expected one mirror, got %d", len(entries))
+	}
+
+	// Changes pushed from one checkout show up in the next
+	if err := ioutil.WriteFile(filepath.Join(paths[0], "file.yaml"), []byte("version: 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAndPush(paths[0], "Update"); err != nil {
+		t.Fatal(err)
+	}
+	path, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	if content := readFile(t, path); content != "version: 2\n" {
+		t.Errorf("expected the change pushed to be checked out, got %q", content)
+	}
+}
+
+func TestMirrorForgetsRemovedWorktrees(t *testing.T) {
+	remote, cleanup := setupRemote(t)
+	defer cleanup()
+	mirrorDir, _ := ioutil.TempDir("", "flux-mirrors")
+	defer os.RemoveAll(mirrorDir)
+
+	repo := Repo{URL: remote, Branch: "master", Mirrors: NewMirrors(mirrorDir)}
+	for i := 0; i < 2; i++ {
+		path, err := repo.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.RemoveAll(filepath.Dir(path))
+	}
+	mirror := repo.Mirrors.mirrorFor(repo)
+	if worktrees, _ := ioutil.ReadDir(filepath.Join(mirror.dir, "worktrees")); len(worktrees) != 1 {
+		t.Errorf("expected only the latest worktree to be known, got %d", len(worktrees))
+	}
+}
+
+func TestPushRejectedThenReset(t *testing.T) {
+	remote, cleanup := setupRemote(t)
+	defer cleanup()
+	mirrorDir, _ := ioutil.TempDir("", "flux-mirrors")
+	defer os.RemoveAll(mirrorDir)
+
+	repo := Repo{URL: remote, Branch: "master", Mirrors: NewMirrors(mirrorDir)}
+	ours, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(ours))
+	theirs, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(theirs))
+
+	ioutil.WriteFile(filepath.Join(theirs, "file.yaml"), []byte("version: theirs\n"), 0600)
+	if err := repo.CommitAndPush(theirs, "Theirs"); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(ours, "file.yaml"), []byte("version: ours\n"), 0600)
+	if err := repo.CommitAndPush(ours, "Ours"); err != ErrNonFastForward {
+		t.Fatalf("expected push to be rejected as non-fast-forward, got %v", err)
+	}
+
+	if err := repo.ResetToRemote(ours); err != nil {
+		t.Fatal(err)
+	}
+	if content := readFile(t, ours); content != "version: theirs\n" {
+		t.Errorf("expected to have caught up with the remote, got %q", content)
+	}
+	ioutil.WriteFile(filepath.Join(ours, "file.yaml"), []byte("version: ours\n"), 0600)
+	if err := repo.CommitAndPush(ours, "Ours"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMirrorRecoversFromCorruption(t *testing.T) {
+	remote, cleanup := setupRemote(t)
+	defer cleanup()
+	mirrorDir, _ := ioutil.TempDir("", "flux-mirrors")
+	defer os.RemoveAll(mirrorDir)
+
+	repo := Repo{URL: remote, Branch: "master", Mirrors: NewMirrors(mirrorDir)}
+	path, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Dir(path))
+
+	// Garble the objects in the mirror, as a full disk might
+	mirror := repo.Mirrors.mirrorFor(repo)
+	objects, _ := filepath.Glob(filepath.Join(mirror.dir, "objects", "??", "*"))
+	if len(objects) == 0 {
+		t.Fatal("expected objects in the mirror")
+	}
+	for _, object := range objects {
+		os.Remove(object)
+		if err := ioutil.WriteFile(object, []byte("garbage"), 0400); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err = repo.Clone()
+	if err != nil {
+		t.Fatalf("expected the mirror to be cloned again, got %s", err)
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	if content := readFile(t, path); content != "version: 1\n" {
+		t.Errorf("expected file to be checked out, got %q", content)
+	}
+}
+
+func TestMirrorsEvictUnused(t *testing.T) {
+	defer func(interval time.Duration) { mirrorEvictInterval = interval }(mirrorEvictInterval)
+	mirrorEvictInterval = 0
+
+	old, cleanupOld := setupRemote(t)
+	defer cleanupOld()
+	current, cleanupCurrent := setupRemote(t)
+	defer cleanupCurrent()
+	mirrorDir, _ := ioutil.TempDir("", "flux-mirrors")
+	defer os.RemoveAll(mirrorDir)
+	mirrors := NewMirrors(mirrorDir)
+
+	// A mirror that's since gone unused, e.g., because the repo's
+	// credentials changed
+	oldRepo := Repo{URL: old, Branch: "master", Mirrors: mirrors}
+	path, err := oldRepo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Dir(path))
+	oldMirror := mirrors.mirrorFor(oldRepo)
+	oldMirror.lastUsed = time.Now().Add(-2 * mirrorExpiry)
+
+	// A mirror left from before a restart
+	leftover := filepath.Join(mirrorDir, "leftover")
+	os.Mkdir(leftover, 0700)
+	longAgo := time.Now().Add(-2 * mirrorExpiry)
+	os.Chtimes(leftover, longAgo, longAgo)
+
+	repo := Repo{URL: current, Branch: "master", Mirrors: mirrors}
+	path, err = repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+
+	entries, _ := ioutil.ReadDir(mirrorDir)
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(mirrors.mirrorFor(repo).dir) {
+		t.Errorf("expected only the mirror in use to be kept, got %d mirrors", len(entries))
+	}
+	if len(mirrors.mirrors) != 1 {
+		t.Errorf("expected the unused mirror to be forgotten (and not counted), got %d mirrors", len(mirrors.mirrors))
+	}
+}
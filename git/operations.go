@@ -17,12 +17,7 @@ import (
 // Do a shallow clone of the repo. We only need the files, and not the
 // history. A shallow clone is marginally quicker, and takes less
 // space, than a full clone.
-func clone(workingDir, keyData, repoURL, repoBranch string) (path string, err error) {
-	keyPath, err := writeKey(keyData)
-	if err != nil {
-		return "", err
-	}
-	defer os.Remove(keyPath)
+func clone(workingDir string, s *session, repoURL, repoBranch string) (path string, err error) {
 	repoPath := filepath.Join(workingDir, "repo")
 	// --single-branch is also useful, but is implied by --depth=1
 	args := []string{"clone", "--depth=1"}
@@ -30,16 +25,16 @@ func clone(workingDir, keyData, repoURL, repoBranch string) (path string, err er
 		args = append(args, "--branch", repoBranch)
 	}
 	args = append(args, repoURL, repoPath)
-	if err := execGitCmd(workingDir, keyPath, args...); err != nil {
+	if err := execGitCmd(workingDir, s, args...); err != nil {
 		return "", errors.Wrap(err, "git clone")
 	}
 	return repoPath, nil
 }
 
-func commit(workingDir, commitMessage string) error {
+func commit(workingDir, name, email, commitMessage string) error {
 	if err := execGitCmd(
-		workingDir, "",
-		"-c", "user.name=Weave Flux", "-c", "user.email=support@weave.works",
+		workingDir, nil,
+		"-c", "user.name="+name, "-c", "user.email="+email,
 		"commit",
 		"--no-verify", "-a", "-m", commitMessage,
 	); err != nil {
@@ -48,53 +43,105 @@ func commit(workingDir, commitMessage string) error {
 	return nil
 }
 
-func push(keyData, repoBranch, workingDir string) error {
-	keyPath, err := writeKey(keyData)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(keyPath)
-	if err := execGitCmd(workingDir, keyPath, "push", "origin", repoBranch); err != nil {
+// push pushes what's checked out to the branch given, which needn't
+// be the branch checked out (if any; a worktree from a mirror is
+// detached).
+func push(s *session, repoBranch, workingDir string) error {
+	if err := execGitCmd(workingDir, s, "push", "origin", "HEAD:refs/heads/"+repoBranch); err != nil {
 		return errors.Wrap(err, fmt.Sprintf("git push origin %s", repoBranch))
 	}
 	return nil
 }
 
-func execGitCmd(dir, keyPath string, args ...string) error {
+// forcePush pushes what's checked out to the branch given, replacing
+// whatever is there.
+func forcePush(s *session, repoBranch, workingDir string) error {
+	if err := execGitCmd(workingDir, s, "push", "--force", "origin", "HEAD:refs/heads/"+repoBranch); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("git push --force origin %s", repoBranch))
+	}
+	return nil
+}
+
+func fetchBranch(s *session, repoBranch, workingDir string) error {
+	if err := execGitCmd(workingDir, s, "fetch", "origin", "refs/heads/"+repoBranch); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("git fetch origin %s", repoBranch))
+	}
+	return nil
+}
+
+func reset(workingDir, ref string) error {
+	if err := execGitCmd(workingDir, nil, "reset", "--hard", ref); err != nil {
+		return errors.Wrap(err, "git reset")
+	}
+	return nil
+}
+
+// revision gives the commit ref refers to, e.g., HEAD.
+func revision(workingDir, ref string) (string, error) {
+	out := &bytes.Buffer{}
+	if err := execGitCmdIO(workingDir, nil, nil, out, "rev-parse", "--verify", ref); err != nil {
+		return "", errors.Wrap(err, "git rev-parse")
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// execGitCmd runs a git command in dir. If the command talks to a
+// remote repo, a session must be given.
+func execGitCmd(dir string, s *session, args ...string) error {
+	return execGitCmdIO(dir, s, nil, ioutil.Discard, args...)
+}
+
+// execGitCmdIO runs a git command like execGitCmd, but with the
+// input and output given.
+func execGitCmdIO(dir string, s *session, stdin io.Reader, stdout io.Writer, args ...string) error {
+	if s != nil {
+		args = append(s.args(), args...)
+	}
 	c := exec.Command("git", args...)
 	if dir != "" {
 		c.Dir = dir
 	}
-	c.Env = env(keyPath)
-	c.Stdout = ioutil.Discard
+	c.Env = env(s)
+	c.Stdin = stdin
+	c.Stdout = stdout
 	errOut := &bytes.Buffer{}
 	c.Stderr = errOut
 	err := c.Run()
 	if err != nil {
-		msg := findFatalMessage(errOut)
-		if msg != "" {
+		output := errOut.String()
+		if s != nil {
+			output = s.redact(output)
+		}
+		if hostErr := hostKeyError(output); hostErr != nil {
+			err = hostErr
+		} else if msg := findFatalMessage(strings.NewReader(output)); msg != "" {
 			err = errors.New(msg)
+		} else if isNonFastForward(output) {
+			err = ErrNonFastForward
 		}
 	}
 	return err
 }
 
-func env(keyPath string) []string {
-	base := `GIT_SSH_COMMAND=ssh -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no`
-	if keyPath == "" {
-		return []string{base}
+func env(s *session) []string {
+	if s == nil {
+		return []string{"GIT_TERMINAL_PROMPT=0"}
 	}
-	return []string{fmt.Sprintf("%s -i %q", base, keyPath), "GIT_TERMINAL_PROMPT=0"}
+	return s.env()
 }
 
 // check returns true if there are changes locally.
 func check(workingDir, subdir string) bool {
 	// `--quiet` means "exit with 1 if there are changes"
-	return execGitCmd(workingDir, "", "diff", "--quiet", "--", subdir) != nil
+	return execGitCmd(workingDir, nil, "diff", "--quiet", "--", subdir) != nil
 }
 
 func writeKey(keyData string) (string, error) {
-	f, err := ioutil.TempFile("", "flux-key")
+	return writeTempFile("flux-key", keyData, 0400)
+}
+
+func writeTempFile(prefix, content string, mode os.FileMode) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
 	if err != nil {
 		return "", err
 	}
@@ -102,13 +149,25 @@ func writeKey(keyData string) (string, error) {
 		os.Remove(f.Name())
 		return "", err
 	}
-	if err := ioutil.WriteFile(f.Name(), []byte(keyData), 0400); err != nil {
+	if err := ioutil.WriteFile(f.Name(), []byte(content), mode); err != nil {
 		os.Remove(f.Name())
 		return "", err
 	}
 	return f.Name(), nil
 }
 
+// isNonFastForward says whether the output of a push shows it was
+// rejected because the remote branch has commits not pushed from here.
+func isNonFastForward(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "[rejected]") &&
+			(strings.Contains(line, "(fetch first)") || strings.Contains(line, "(non-fast-forward)")) {
+			return true
+		}
+	}
+	return false
+}
+
 func findFatalMessage(output io.Reader) string {
 	sc := bufio.NewScanner(output)
 	for sc.Scan() {
@@ -4,10 +4,21 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+const (
+	defaultCommitterName  = "Weave Flux"
+	defaultCommitterEmail = "support@weave.works"
 )
 
 var (
 	ErrNoChanges = errors.New("no changes made in repo")
+	// ErrNonFastForward means a push was rejected because the branch
+	// has moved on since the repo was cloned.
+	ErrNonFastForward = errors.New("push rejected as the branch has changed since it was cloned")
 )
 
 // Repo represents a remote git repo
@@ -23,8 +34,36 @@ type Repo struct {
 	// permissions to clone and push to the config repo.
 	Key string
 
+	// The username and password with permissions to clone and push to
+	// the config repo, if it's reached over HTTPS. The password may be
+	// an access token, in which case the username can be left empty.
+	Username string
+	Password string
+
 	// The path within the config repo where files are stored.
 	Path string
+
+	// The host keys (in the form of a known_hosts file) against which
+	// the git host is checked. If empty, the host is trusted on first
+	// use.
+	KnownHosts string
+
+	// TrustHosts, if set, is given the host keys seen on first use,
+	// so they can be recorded and checked against from then on.
+	TrustHosts func(knownHosts string)
+
+	// Mirrors, if set, keeps a local copy of the repo to check out
+	// from, rather than it being cloned each time.
+	Mirrors *Mirrors
+
+	// The name and email address commits are made with. If not
+	// given, they're "Weave Flux" and "support@weave.works".
+	CommitterName  string
+	CommitterEmail string
+
+	// The (ASCII-armored, unencrypted) private PGP key to sign
+	// commits with, if they're to be signed.
+	SigningKey string
 }
 
 func (r Repo) Clone() (path string, err error) {
@@ -37,22 +76,128 @@ func (r Repo) Clone() (path string, err error) {
 		return "", err
 	}
 
-	repoDir, err := clone(workingDir, r.Key, r.URL, r.Branch)
+	err = r.withSession(func(s *session) error {
+		if r.Mirrors != nil {
+			path = filepath.Join(workingDir, "repo")
+			return r.Mirrors.checkout(r, s, path)
+		}
+		path, err = clone(workingDir, s, r.URL, r.Branch)
+		return err
+	})
 	if err != nil {
-		return "", CloningError(r.URL, err)
+		return "", r.remoteError(err, CloningError)
 	}
-	return repoDir, nil
+	return path, nil
 }
 
 func (r Repo) CommitAndPush(path, commitMessage string) error {
 	if !check(path, r.Path) {
 		return ErrNoChanges
 	}
-	if err := commit(path, commitMessage); err != nil {
+	if err := r.commit(path, commitMessage); err != nil {
+		return err
+	}
+	err := r.withSession(func(s *session) error {
+		return push(s, r.Branch, path)
+	})
+	if err != nil {
+		if pkgerrors.Cause(err) == ErrNonFastForward {
+			return ErrNonFastForward
+		}
+		return r.remoteError(err, PushError)
+	}
+	return nil
+}
+
+// ResetToRemote throws away any changes, committed or not, in the
+// clone at path, and brings it up to date with the branch in the
+// remote repo, e.g., so that changes can be made again after a push
+// was rejected.
+func (r Repo) ResetToRemote(path string) error {
+	err := r.withSession(func(s *session) error {
+		return fetchBranch(s, r.Branch, path)
+	})
+	if err != nil {
+		return r.remoteError(err, CloningError)
+	}
+	return reset(path, "FETCH_HEAD")
+}
+
+// HeadRevision gives the commit checked out in the clone at path,
+// e.g., the one just pushed.
+func (r Repo) HeadRevision(path string) (string, error) {
+	return revision(path, "HEAD")
+}
+
+// CommitAndPushBranch commits the changes in the clone at path, and
+// pushes them to a branch of their own, rather than the branch cloned,
+// e.g., so that they can be reviewed before being merged. Anything
+// already on that branch is replaced.
+func (r Repo) CommitAndPushBranch(path, branch, commitMessage string) error {
+	if !check(path, r.Path) {
+		return ErrNoChanges
+	}
+	if err := r.commit(path, commitMessage); err != nil {
+		return err
+	}
+	err := r.withSession(func(s *session) error {
+		return forcePush(s, branch, path)
+	})
+	if err != nil {
+		return r.remoteError(err, PushError)
+	}
+	return nil
+}
+
+// commit commits the changes in the clone at path as the committer
+// configured, signing the commit if there's a key to sign with.
+func (r Repo) commit(path, commitMessage string) error {
+	name, email := r.CommitterName, r.CommitterEmail
+	if name == "" {
+		name = defaultCommitterName
+	}
+	if email == "" {
+		email = defaultCommitterEmail
+	}
+	if err := commit(path, name, email, commitMessage); err != nil {
+		return err
+	}
+	if r.SigningKey == "" {
+		return nil
+	}
+	if err := signCommit(path, r.SigningKey); err != nil {
+		return SigningError(err)
+	}
+	return nil
+}
+
+// withSession runs an operation on the remote repo, authenticating
+// with the key or credentials, and checking the git host's key against
+// those known. If none are known, the host is trusted, and once the
+// operation has succeeded the key it presented is passed on to be
+// recorded.
+func (r Repo) withSession(f func(*session) error) error {
+	s, err := newSession(r)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if err := f(s); err != nil {
 		return err
 	}
-	if err := push(r.Key, r.Branch, path); err != nil {
-		return PushError(r.URL, err)
+	if r.TrustHosts != nil {
+		if learned := s.learnedHosts(); learned != "" {
+			r.TrustHosts(learned)
+		}
 	}
 	return nil
 }
+
+// remoteError explains an error from an operation on the remote repo,
+// telling failures to verify the host apart from other problems.
+func (r Repo) remoteError(err error, otherwise func(string, error) error) error {
+	if isHostKeyError(err) {
+		return HostKeyError(r.URL, pkgerrors.Cause(err))
+	}
+	return otherwise(r.URL, err)
+}
@@ -0,0 +1,143 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrHostKeyChanged means the git host presented a key other
+	// than the one recorded for it.
+	ErrHostKeyChanged = errors.New("the git host's key has changed")
+	// ErrHostKeyUnknown means there's no key recorded for the git
+	// host, though there are keys recorded for others.
+	ErrHostKeyUnknown = errors.New("the git host's key is not known")
+)
+
+const (
+	// The username given with a password on its own, which is
+	// usually an access token; GitHub, e.g., accepts any username
+	// with a token, but there has to be one.
+	tokenUsername = "x-access-token"
+
+	// credentialHelper gives git the username and password from the
+	// environment, so they're never in the URL or the arguments of a
+	// command. It only answers requests for credentials, and ignores
+	// requests to store or forget them.
+	credentialHelper = `!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$FLUX_GIT_USERNAME" "$FLUX_GIT_PASSWORD"; }; f`
+
+	redacted = "<redacted>"
+)
+
+// A session holds what's needed to authenticate with a remote repo
+// for an operation. Over SSH, that's the files ssh needs: the private
+// key to use, and the known hosts, against which the git host's key is
+// checked. If no hosts are known yet, the host is trusted on first
+// use, and its key can be read afterwards. Over HTTPS, it's the
+// username and password (or token), which are given to git by a
+// credential helper.
+type session struct {
+	keyPath        string
+	knownHostsPath string
+	firstUse       bool
+
+	username string
+	password string
+}
+
+func newSession(r Repo) (*session, error) {
+	keyPath, err := writeKey(r.Key)
+	if err != nil {
+		return nil, err
+	}
+	knownHostsPath, err := writeTempFile("flux-known-hosts", r.KnownHosts, 0600)
+	if err != nil {
+		os.Remove(keyPath)
+		return nil, err
+	}
+	s := &session{
+		keyPath:        keyPath,
+		knownHostsPath: knownHostsPath,
+		firstUse:       strings.TrimSpace(r.KnownHosts) == "",
+		username:       r.Username,
+		password:       r.Password,
+	}
+	if s.password != "" && s.username == "" {
+		s.username = tokenUsername
+	}
+	return s, nil
+}
+
+func (s *session) close() {
+	os.Remove(s.keyPath)
+	os.Remove(s.knownHostsPath)
+}
+
+func (s *session) env() []string {
+	// Only the hosts given are trusted (unless there are none, in
+	// which case ssh records the host it connects to).
+	strict := "yes"
+	if s.firstUse {
+		strict = "no"
+	}
+	env := []string{
+		fmt.Sprintf("GIT_SSH_COMMAND=ssh -o UserKnownHostsFile=%q -o GlobalKnownHostsFile=/dev/null -o HashKnownHosts=no -o StrictHostKeyChecking=%s -i %q",
+			s.knownHostsPath, strict, s.keyPath),
+		"GIT_TERMINAL_PROMPT=0",
+	}
+	if s.password != "" {
+		env = append(env, "FLUX_GIT_USERNAME="+s.username, "FLUX_GIT_PASSWORD="+s.password)
+	}
+	return env
+}
+
+// args gives the options to put before the git command, to use the
+// credentials (if there are any) in place of any other helpers.
+func (s *session) args() []string {
+	if s.password == "" {
+		return nil
+	}
+	return []string{"-c", "credential.helper=", "-c", "credential.helper=" + credentialHelper}
+}
+
+// redact removes the password from output, in case git or the host
+// repeats it back.
+func (s *session) redact(output string) string {
+	if s.password == "" {
+		return output
+	}
+	return strings.Replace(output, s.password, redacted, -1)
+}
+
+// learnedHosts gives the host keys recorded on first use, if any.
+func (s *session) learnedHosts() string {
+	if !s.firstUse {
+		return ""
+	}
+	bytes, err := ioutil.ReadFile(s.knownHostsPath)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+// hostKeyError picks out the failures of ssh to verify the git host,
+// from what it outputs.
+func hostKeyError(output string) error {
+	switch {
+	case strings.Contains(output, "REMOTE HOST IDENTIFICATION HAS CHANGED"):
+		return ErrHostKeyChanged
+	case strings.Contains(output, "Host key verification failed"):
+		return ErrHostKeyUnknown
+	}
+	return nil
+}
+
+func isHostKeyError(err error) bool {
+	cause := errors.Cause(err)
+	return cause == ErrHostKeyChanged || cause == ErrHostKeyUnknown
+}
@@ -0,0 +1,67 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+)
+
+// signCommit signs the commit at HEAD in the clone at workingDir with
+// the (ASCII-armored) private key given, replacing the commit with the
+// signed one. This is what `git commit -S` does, except that it
+// doesn't need gpg or a keyring to have been set up.
+func signCommit(workingDir, signingKey string) error {
+	signer, err := readSigningKey(signingKey)
+	if err != nil {
+		return err
+	}
+
+	var commit bytes.Buffer
+	if err := execGitCmdIO(workingDir, nil, nil, &commit, "cat-file", "commit", "HEAD"); err != nil {
+		return errors.Wrap(err, "reading commit")
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader(commit.Bytes()), nil); err != nil {
+		return errors.Wrap(err, "signing commit")
+	}
+
+	var signed bytes.Buffer
+	if err := execGitCmdIO(workingDir, nil, addSignature(commit.String(), sig.String()), &signed, "hash-object", "-t", "commit", "-w", "--stdin"); err != nil {
+		return errors.Wrap(err, "writing signed commit")
+	}
+	if err := execGitCmd(workingDir, nil, "update-ref", "HEAD", strings.TrimSpace(signed.String())); err != nil {
+		return errors.Wrap(err, "replacing commit with signed commit")
+	}
+	return nil
+}
+
+func readSigningKey(signingKey string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(signingKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading signing key")
+	}
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			return nil, errors.New("signing key is protected with a passphrase")
+		}
+		return entity, nil
+	}
+	return nil, errors.New("no private key found in signing key")
+}
+
+// addSignature puts the signature in a commit object, as the gpgsig
+// header after the others. Each line of the signature after the first
+// is indented, to mark it as continuing the header.
+func addSignature(commit, sig string) *strings.Reader {
+	headers, message := commit, ""
+	if i := strings.Index(commit, "\n\n"); i >= 0 {
+		headers, message = commit[:i+1], commit[i+1:]
+	}
+	header := "gpgsig " + strings.Replace(strings.TrimRight(sig, "\n"), "\n", "\n ", -1) + "\n"
+	return strings.NewReader(headers + header + message)
+}
@@ -0,0 +1,123 @@
+package git
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// throwawayKey makes a PGP key just for the test, returning the
+// keyring to check signatures against and the armored private key.
+func throwawayKey(t *testing.T) (openpgp.EntityList, string) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return openpgp.EntityList{entity}, buf.String()
+}
+
+// splitSignature takes the signature out of a commit object, giving
+// the commit as it was signed, and the signature.
+func splitSignature(t *testing.T, commit string) (string, string) {
+	var payload, sig []string
+	inSig := false
+	for _, line := range strings.SplitAfter(commit, "\n") {
+		switch {
+		case strings.HasPrefix(line, "gpgsig "):
+			inSig = true
+			sig = append(sig, strings.TrimPrefix(line, "gpgsig "))
+		case inSig && strings.HasPrefix(line, " "):
+			sig = append(sig, strings.TrimPrefix(line, " "))
+		default:
+			inSig = false
+			payload = append(payload, line)
+		}
+	}
+	if len(sig) == 0 {
+		t.Fatalf("expected commit to be signed, got:\n%s", commit)
+	}
+	return strings.Join(payload, ""), strings.Join(sig, "")
+}
+
+func TestSignedCommit(t *testing.T) {
+	remote, cleanup := setupRemote(t)
+	defer cleanup()
+	keyring, signingKey := throwawayKey(t)
+
+	repo := Repo{
+		URL:            remote,
+		Branch:         "master",
+		CommitterName:  "Release Bot",
+		CommitterEmail: "releases@example.com",
+		SigningKey:     signingKey,
+	}
+	path, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "file.yaml"), []byte("version: 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAndPush(path, "Change version\n\nReleased-by: alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	var commit bytes.Buffer
+	if err := execGitCmdIO(remote, nil, nil, &commit, "cat-file", "commit", "master"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(commit.String(), "\ncommitter Release Bot <releases@example.com>") {
+		t.Errorf("expected commit to be made by the configured committer, got:\n%s", commit.String())
+	}
+	payload, sig := splitSignature(t, commit.String())
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, strings.NewReader(payload), strings.NewReader(sig))
+	if err != nil {
+		t.Fatalf("expected signature to verify, got %v", err)
+	}
+	if signer.PrimaryKey.KeyId != keyring[0].PrimaryKey.KeyId {
+		t.Errorf("expected commit to be signed with the throwaway key, got key %X", signer.PrimaryKey.KeyId)
+	}
+
+	// The signature is of the commit as made, so that's the same
+	// (including the trailer) once the signature is taken out.
+	if !strings.HasSuffix(payload, "\n\nChange version\n\nReleased-by: alice\n") {
+		t.Errorf("expected message with trailer, got:\n%s", payload)
+	}
+}
+
+func TestSigningKeyInvalid(t *testing.T) {
+	remote, cleanup := setupRemote(t)
+	defer cleanup()
+
+	repo := Repo{URL: remote, Branch: "master", SigningKey: "not a key"}
+	path, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "file.yaml"), []byte("version: 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAndPush(path, "Change version"); err == nil {
+		t.Fatal("expected error from signing with an invalid key")
+	}
+	var log bytes.Buffer
+	execGitCmdIO(remote, nil, nil, &log, "log", "--format=%s", "master")
+	if strings.Contains(log.String(), "Change version") {
+		t.Errorf("expected the unsigned commit not to be pushed, got log:\n%s", log.String())
+	}
+}
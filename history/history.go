@@ -1,11 +1,14 @@
 package history
 
 import (
+	"errors"
 	"io"
 
 	"github.com/weaveworks/flux"
 )
 
+var ErrEventNotFound = errors.New("event not found")
+
 type EventReadWriter interface {
 	EventReader
 	EventWriter
@@ -27,6 +30,12 @@ type EventReader interface {
 
 	// GetEvent finds a single event, by ID.
 	GetEvent(flux.EventID) (flux.Event, error)
+
+	// ReleaseEvent finds the event recording the release (or
+	// rollback) given, returning ErrEventNotFound if there isn't one.
+	// A release proposed as a pull request is recorded again once
+	// it's merged; the latest event is returned.
+	ReleaseEvent(flux.ReleaseID) (flux.Event, error)
 }
 
 type DB interface {
@@ -34,5 +43,6 @@ type DB interface {
 	AllEvents(flux.InstanceID) ([]flux.Event, error)
 	EventsForService(flux.InstanceID, flux.ServiceID) ([]flux.Event, error)
 	GetEvent(flux.EventID) (flux.Event, error)
+	ReleaseEvent(flux.InstanceID, flux.ReleaseID) (flux.Event, error)
 	io.Closer
 }
@@ -73,6 +73,16 @@ func (i *instrumentedDB) GetEvent(id flux.EventID) (e flux.Event, err error) {
 	return i.db.GetEvent(id)
 }
 
+func (i *instrumentedDB) ReleaseEvent(inst flux.InstanceID, id flux.ReleaseID) (e flux.Event, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			LabelMethod, "ReleaseEvent",
+			LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.db.ReleaseEvent(inst, id)
+}
+
 func (i *instrumentedDB) Close() (err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
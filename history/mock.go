@@ -25,6 +25,10 @@ func (m mock) GetEvent(_ flux.EventID) (flux.Event, error) {
 	return flux.Event{}, nil
 }
 
+func (m mock) ReleaseEvent(_ flux.ReleaseID) (flux.Event, error) {
+	return flux.Event{}, ErrEventNotFound
+}
+
 func (m mock) LogEvent(_ flux.Event) error {
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
 )
 
 // A history DB that uses a postgres database
@@ -46,16 +47,11 @@ func (db *pgDB) queryEvents(query string, params ...interface{}) ([]flux.Event,
 			h.ServiceIDs = append(h.ServiceIDs, flux.ServiceID(id))
 		}
 
-		if len(metadataBytes) > 0 {
-			switch h.Type {
-			case flux.EventRelease:
-				var m flux.ReleaseEventMetadata
-				if err := json.Unmarshal(metadataBytes, &m); err != nil {
-					return nil, err
-				}
-				h.Metadata = m
-			}
+		metadata, err := flux.DecodeEventMetadata(h.Type, metadataBytes)
+		if err != nil {
+			return nil, err
 		}
+		h.Metadata = metadata
 		events = append(events, h)
 	}
 	return events, rows.Err()
@@ -99,6 +95,25 @@ func (db *pgDB) GetEvent(id flux.EventID) (flux.Event, error) {
 	return es[0], nil
 }
 
+func (db *pgDB) ReleaseEvent(inst flux.InstanceID, id flux.ReleaseID) (flux.Event, error) {
+	es, err := db.queryEvents(
+		`SELECT id, service_ids, type, started_at, ended_at, log_level, message, metadata
+		FROM events
+		WHERE instance_id = $1
+		AND release_id = $2
+		ORDER BY ended_at DESC`,
+		string(inst),
+		string(id),
+	)
+	if err != nil {
+		return flux.Event{}, err
+	}
+	if len(es) <= 0 {
+		return flux.Event{}, history.ErrEventNotFound
+	}
+	return es[0], nil
+}
+
 func (db *pgDB) LogEvent(inst flux.InstanceID, e flux.Event) error {
 	j, err := json.Marshal(e.Metadata)
 	if err != nil {
@@ -114,8 +129,8 @@ func (db *pgDB) LogEvent(inst flux.InstanceID, e flux.Event) error {
 	}
 	_, err = db.driver.Exec(
 		`INSERT INTO events
-		(instance_id, service_ids, type, log_level, metadata, started_at, ended_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		(instance_id, service_ids, type, log_level, metadata, started_at, ended_at, release_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
 		string(inst),
 		serviceIDs,
 		e.Type,
@@ -123,6 +138,7 @@ func (db *pgDB) LogEvent(inst flux.InstanceID, e flux.Event) error {
 		j,
 		startedAt,
 		pq.NullTime{Time: e.EndedAt.UTC(), Valid: !e.EndedAt.IsZero()},
+		releaseID(e),
 	)
 	return err
 }
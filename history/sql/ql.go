@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
 )
 
 // A history DB that uses a ql database
@@ -41,16 +42,11 @@ func (db *qlDB) queryEvents(query string, params ...interface{}) ([]flux.Event,
 			return nil, err
 		}
 
-		if len(metadataBytes) > 0 {
-			switch h.Type {
-			case flux.EventRelease:
-				var m flux.ReleaseEventMetadata
-				if err := json.Unmarshal(metadataBytes, &m); err != nil {
-					return nil, err
-				}
-				h.Metadata = m
-			}
+		metadata, err := flux.DecodeEventMetadata(h.Type, metadataBytes)
+		if err != nil {
+			return nil, err
 		}
+		h.Metadata = metadata
 		events = append(events, h)
 	}
 	return events, rows.Err()
@@ -103,6 +99,26 @@ func (db *qlDB) GetEvent(id flux.EventID) (flux.Event, error) {
 	return es[0], err
 }
 
+func (db *qlDB) ReleaseEvent(inst flux.InstanceID, id flux.ReleaseID) (flux.Event, error) {
+	es, err := db.queryEvents(
+		`SELECT id(events), type, started_at, ended_at, log_level, message, metadata
+		FROM events
+		WHERE instance_id = $1
+		AND release_id = $2
+		ORDER BY ended_at DESC`,
+		string(inst),
+		string(id),
+	)
+	if err != nil {
+		return flux.Event{}, err
+	}
+	if len(es) <= 0 {
+		return flux.Event{}, history.ErrEventNotFound
+	}
+	es, err = db.loadServiceIDs(es)
+	return es[0], err
+}
+
 func (db *qlDB) loadServiceIDs(events []flux.Event) ([]flux.Event, error) {
 	for _, e := range events {
 		rows, err := db.driver.Query(`SELECT service_id from event_service_ids where event_id = $1`, e.ID)
@@ -146,14 +162,15 @@ func (db *qlDB) LogEvent(inst flux.InstanceID, e flux.Event) (err error) {
 
 	result, err := tx.Exec(
 		`INSERT INTO events
-		(instance_id, type, log_level, metadata, started_at, ended_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
+		(instance_id, type, log_level, metadata, started_at, ended_at, release_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
 		string(inst),
 		e.Type,
 		e.LogLevel,
 		string(metadata),
 		startedAt,
 		pq.NullTime{Time: e.EndedAt.UTC(), Valid: !e.EndedAt.IsZero()},
+		releaseID(e),
 	)
 	if err != nil {
 		return err
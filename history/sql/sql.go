@@ -1,8 +1,11 @@
 package sql
 
 import (
+	"database/sql"
+
 	"github.com/jmoiron/sqlx"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/history"
 )
 
@@ -30,3 +33,12 @@ func NewSQL(driver, datasource string) (history.DB, error) {
 func (db *DB) Close() error {
 	return db.driver.Close()
 }
+
+// releaseID gives the ID of the release recorded by the event, if
+// it's a release or rollback, so it can be looked up by that.
+func releaseID(e flux.Event) sql.NullString {
+	if metadata, ok := e.Metadata.(flux.ReleaseEventMetadata); ok {
+		return sql.NullString{String: string(metadata.Release.ID), Valid: metadata.Release.ID != ""}
+	}
+	return sql.NullString{}
+}
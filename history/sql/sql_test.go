@@ -91,6 +91,91 @@ func TestHistoryLog(t *testing.T) {
 	checkInDescOrder(t, es)
 }
 
+func TestHistoryCause(t *testing.T) {
+	instance := flux.InstanceID("instance")
+	db := newSQL(t)
+	defer db.Close()
+
+	cause := flux.Cause{User: "alice", Message: "investigating a memory leak"}
+	bailIfErr(t, db.LogEvent(instance, flux.Event{
+		ServiceIDs: []flux.ServiceID{flux.ServiceID("namespace/service")},
+		Type:       flux.EventLock,
+		Metadata:   flux.PolicyEventMetadata{Cause: cause},
+	}))
+	bailIfErr(t, db.LogEvent(instance, flux.Event{
+		ServiceIDs: []flux.ServiceID{flux.ServiceID("namespace/service")},
+		Type:       flux.EventRelease,
+		Metadata: flux.ReleaseEventMetadata{
+			Release: flux.Release{Spec: flux.ReleaseSpec{Cause: flux.Cause{User: flux.UserAutomated}}},
+		},
+	}))
+
+	es, err := db.EventsForService(instance, flux.ServiceID("namespace/service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	causes := map[string]flux.Cause{}
+	for _, e := range es {
+		causes[e.Type] = e.Cause()
+	}
+	if causes[flux.EventLock] != cause {
+		t.Errorf("expected lock to be recorded with cause %#v, got %#v", cause, causes[flux.EventLock])
+	}
+	if causes[flux.EventRelease].User != flux.UserAutomated {
+		t.Errorf("expected release to be recorded as automated, got %#v", causes[flux.EventRelease])
+	}
+}
+
+func TestHistoryReleaseEvent(t *testing.T) {
+	instance := flux.InstanceID("instance")
+	db := newSQL(t)
+	defer db.Close()
+
+	for _, id := range []flux.ReleaseID{"release1", "release2"} {
+		bailIfErr(t, db.LogEvent(instance, flux.Event{
+			ServiceIDs: []flux.ServiceID{flux.ServiceID("namespace/service")},
+			Type:       flux.EventRelease,
+			Metadata:   flux.ReleaseEventMetadata{Release: flux.Release{ID: id}},
+		}))
+	}
+	bailIfErr(t, db.LogEvent(instance, flux.Event{Type: flux.EventLock}))
+
+	e, err := db.ReleaseEvent(instance, flux.ReleaseID("release1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata, ok := e.Metadata.(flux.ReleaseEventMetadata); !ok || metadata.Release.ID != "release1" {
+		t.Errorf("expected the event for release1, got %#v", e)
+	}
+
+	// A release awaiting merge is recorded again once it's merged
+	now := time.Now().UTC()
+	for _, status := range []flux.ServiceReleaseStatus{flux.ReleaseStatusAwaitingMerge, flux.ReleaseStatusSuccess} {
+		bailIfErr(t, db.LogEvent(instance, flux.Event{
+			ServiceIDs: []flux.ServiceID{flux.ServiceID("namespace/service")},
+			Type:       flux.EventRelease,
+			StartedAt:  now,
+			EndedAt:    now,
+			Metadata:   flux.ReleaseEventMetadata{Release: flux.Release{ID: "release4", Status: status}},
+		}))
+		now = now.Add(time.Minute)
+	}
+	e, err = db.ReleaseEvent(instance, flux.ReleaseID("release4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata, ok := e.Metadata.(flux.ReleaseEventMetadata); !ok || metadata.Release.Status != flux.ReleaseStatusSuccess {
+		t.Errorf("expected the latest event for release4, got %#v", e)
+	}
+
+	if _, err := db.ReleaseEvent(flux.InstanceID("other"), flux.ReleaseID("release1")); err != history.ErrEventNotFound {
+		t.Errorf("expected releases of other instances not to be found, got %v", err)
+	}
+	if _, err := db.ReleaseEvent(instance, flux.ReleaseID("release3")); err != history.ErrEventNotFound {
+		t.Errorf("expected ErrEventNotFound, got %v", err)
+	}
+}
+
 func checkInDescOrder(t *testing.T, events []flux.Event) {
 	var last time.Time = time.Now()
 	for _, event := range events {
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -52,6 +54,10 @@ func (c *client) PostRelease(_ flux.InstanceID, s jobs.ReleaseJobParams) (jobs.J
 	for _, ex := range s.Excludes {
 		args = append(args, "exclude", string(ex))
 	}
+	args = append(args, causeArgs(s.Cause)...)
+	if s.Force {
+		args = append(args, "force", "true")
+	}
 
 	var resp transport.PostReleaseResponse
 	err := c.postWithResp(&resp, "PostRelease", nil, args...)
@@ -64,20 +70,53 @@ func (c *client) GetRelease(_ flux.InstanceID, id jobs.JobID) (jobs.Job, error)
 	return res, err
 }
 
-func (c *client) Automate(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Automate", "service", string(id))
+func (c *client) PostRollback(_ flux.InstanceID, p jobs.RollbackJobParams) (jobs.JobID, error) {
+	var resp transport.PostReleaseResponse
+	args := append([]string{"release", string(p.ReleaseID), "kind", string(p.Kind)}, causeArgs(p.Cause)...)
+	if p.Force {
+		args = append(args, "force", "true")
+	}
+	err := c.postWithResp(&resp, "PostRollback", nil, args...)
+	return resp.ReleaseID, err
+}
+
+func (c *client) Automate(_ flux.InstanceID, id flux.ServiceID, cause flux.Cause) error {
+	args := append([]string{"service", string(id)}, causeArgs(cause)...)
+	return c.post("Automate", args...)
+}
+
+func (c *client) Deautomate(_ flux.InstanceID, id flux.ServiceID, cause flux.Cause) error {
+	args := append([]string{"service", string(id)}, causeArgs(cause)...)
+	return c.post("Deautomate", args...)
+}
+
+func (c *client) Lock(_ flux.InstanceID, id flux.ServiceID, cause flux.Cause, expires time.Duration) error {
+	args := append([]string{"service", string(id)}, causeArgs(cause)...)
+	if expires != 0 {
+		args = append(args, "expires", expires.String())
+	}
+	return c.post("Lock", args...)
+}
+
+func (c *client) Unlock(_ flux.InstanceID, id flux.ServiceID, cause flux.Cause) error {
+	args := append([]string{"service", string(id)}, causeArgs(cause)...)
+	return c.post("Unlock", args...)
 }
 
-func (c *client) Deautomate(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Deautomate", "service", string(id))
+func (c *client) SetImageOrder(_ flux.InstanceID, id flux.ServiceID, order flux.ImageOrder) error {
+	return c.post("SetImageOrder", "service", string(id), "order", string(order))
 }
 
-func (c *client) Lock(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Lock", "service", string(id))
+func (c *client) SetTagFilter(_ flux.InstanceID, id flux.ServiceID, filter flux.TagFilter) error {
+	return c.post("SetTagFilter", "service", string(id), "filter", string(filter))
 }
 
-func (c *client) Unlock(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Unlock", "service", string(id))
+func (c *client) SetAutoRollback(_ flux.InstanceID, id flux.ServiceID, enabled bool) error {
+	return c.post("SetAutoRollback", "service", string(id), "enabled", strconv.FormatBool(enabled))
+}
+
+func (c *client) SetSyncPaused(_ flux.InstanceID, paused bool) error {
+	return c.post("SetSyncPaused", "paused", strconv.FormatBool(paused))
 }
 
 func (c *client) History(_ flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryEntry, error) {
@@ -86,6 +125,12 @@ func (c *client) History(_ flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryE
 	return res, err
 }
 
+func (c *client) ListDeliveries(_ flux.InstanceID) ([]jobs.Job, error) {
+	var res []jobs.Job
+	err := c.get(&res, "ListDeliveries")
+	return res, err
+}
+
 func (c *client) GetConfig(_ flux.InstanceID) (flux.InstanceConfig, error) {
 	var res flux.InstanceConfig
 	err := c.get(&res, "GetConfig")
@@ -100,12 +145,31 @@ func (c *client) GenerateDeployKey(_ flux.InstanceID) error {
 	return c.post("GenerateDeployKeys")
 }
 
+func (c *client) Drift(_ flux.InstanceID) ([]flux.ServiceDrift, error) {
+	var res []flux.ServiceDrift
+	err := c.get(&res, "Drift")
+	return res, err
+}
+
 func (c *client) Status(_ flux.InstanceID) (flux.Status, error) {
 	var res flux.Status
 	err := c.get(&res, "Status")
 	return res, err
 }
 
+// causeArgs gives the query parameters saying who is making a
+// change, and why, if they've said.
+func causeArgs(cause flux.Cause) []string {
+	var args []string
+	if cause.User != "" {
+		args = append(args, "user", cause.User)
+	}
+	if cause.Message != "" {
+		args = append(args, "message", cause.Message)
+	}
+	return args
+}
+
 // post is a simple query-param only post request
 func (c *client) post(route string, queryParams ...string) error {
 	return c.postWithBody(route, nil, queryParams...)
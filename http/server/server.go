@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,10 +36,17 @@ func NewHandler(s api.FluxService, r *mux.Router, logger log.Logger) http.Handle
 		"ListImages":             handle.ListImages,
 		"PostRelease":            handle.PostRelease,
 		"GetRelease":             handle.GetRelease,
+		"PostRollback":           handle.PostRollback,
 		"Automate":               handle.Automate,
 		"Deautomate":             handle.Deautomate,
 		"Lock":                   handle.Lock,
 		"Unlock":                 handle.Unlock,
+		"SetImageOrder":          handle.SetImageOrder,
+		"SetTagFilter":           handle.SetTagFilter,
+		"SetAutoRollback":        handle.SetAutoRollback,
+		"SetSyncPaused":          handle.SetSyncPaused,
+		"Drift":                  handle.Drift,
+		"ListDeliveries":         handle.ListDeliveries,
 		"History":                handle.History,
 		"Status":                 handle.Status,
 		"GetConfig":              handle.GetConfig,
@@ -138,6 +146,8 @@ func (s HTTPService) PostRelease(w http.ResponseWriter, r *http.Request) {
 		ImageSpec:    imageSpec,
 		Kind:         releaseKind,
 		Excludes:     excludes,
+		Cause:        getCause(r),
+		Force:        r.FormValue("force") == "true",
 	})
 	if err != nil {
 		errorResponse(w, r, err)
@@ -162,6 +172,40 @@ func (s HTTPService) GetRelease(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, r, job)
 }
 
+func (s HTTPService) PostRollback(w http.ResponseWriter, r *http.Request) {
+	var (
+		inst    = getInstanceID(r)
+		vars    = mux.Vars(r)
+		release = vars["release"]
+		kind    = vars["kind"]
+	)
+	if release == "" {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.New("no release ID given"))
+		return
+	}
+	releaseKind, err := flux.ParseReleaseKind(kind)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing release kind %q", kind))
+		return
+	}
+
+	id, err := s.service.PostRollback(inst, jobs.RollbackJobParams{
+		ReleaseID: flux.ReleaseID(release),
+		Kind:      releaseKind,
+		Cause:     getCause(r),
+		Force:     r.FormValue("force") == "true",
+	})
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	jsonResponse(w, r, transport.PostReleaseResponse{
+		Status:    "Queued.",
+		ReleaseID: id,
+	})
+}
+
 func (s HTTPService) Automate(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 	service := mux.Vars(r)["service"]
@@ -171,7 +215,7 @@ func (s HTTPService) Automate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.service.Automate(inst, id); err != nil {
+	if err = s.service.Automate(inst, id, getCause(r)); err != nil {
 		errorResponse(w, r, err)
 		return
 	}
@@ -188,7 +232,7 @@ func (s HTTPService) Deautomate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.service.Deautomate(inst, id); err != nil {
+	if err = s.service.Deautomate(inst, id, getCause(r)); err != nil {
 		errorResponse(w, r, err)
 		return
 	}
@@ -205,7 +249,15 @@ func (s HTTPService) Lock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.service.Lock(inst, id); err != nil {
+	var expires time.Duration
+	if e := r.FormValue("expires"); e != "" {
+		if expires, err = time.ParseDuration(e); err != nil || expires < 0 {
+			transport.WriteError(w, r, http.StatusBadRequest, errors.Errorf("invalid lock expiry %q; expected a duration, e.g., 2h", e))
+			return
+		}
+	}
+
+	if err = s.service.Lock(inst, id, getCause(r), expires); err != nil {
 		errorResponse(w, r, err)
 		return
 	}
@@ -222,7 +274,89 @@ func (s HTTPService) Unlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.service.Unlock(inst, id); err != nil {
+	if err = s.service.Unlock(inst, id, getCause(r)); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s HTTPService) SetImageOrder(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	service := mux.Vars(r)["service"]
+	id, err := flux.ParseServiceID(service)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing service ID %q", id))
+		return
+	}
+	order, err := flux.ParseImageOrder(mux.Vars(r)["order"])
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = s.service.SetImageOrder(inst, id, order); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s HTTPService) SetTagFilter(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	service := mux.Vars(r)["service"]
+	id, err := flux.ParseServiceID(service)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing service ID %q", id))
+		return
+	}
+	filter, err := flux.ParseTagFilter(mux.Vars(r)["filter"])
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = s.service.SetTagFilter(inst, id, filter.TagFilter); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s HTTPService) SetAutoRollback(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	service := mux.Vars(r)["service"]
+	id, err := flux.ParseServiceID(service)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing service ID %q", id))
+		return
+	}
+	enabled, err := strconv.ParseBool(mux.Vars(r)["enabled"])
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing enabled %q", mux.Vars(r)["enabled"]))
+		return
+	}
+
+	if err = s.service.SetAutoRollback(inst, id, enabled); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s HTTPService) SetSyncPaused(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	paused, err := strconv.ParseBool(mux.Vars(r)["paused"])
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing paused %q", mux.Vars(r)["paused"]))
+		return
+	}
+
+	if err = s.service.SetSyncPaused(inst, paused); err != nil {
 		errorResponse(w, r, err)
 		return
 	}
@@ -346,6 +480,28 @@ func (s HTTPService) Status(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, r, status)
 }
 
+func (s HTTPService) Drift(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	drift, err := s.service.Drift(inst)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	jsonResponse(w, r, drift)
+}
+
+func (s HTTPService) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	deliveries, err := s.service.ListDeliveries(inst)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	jsonResponse(w, r, deliveries)
+}
+
 func (s HTTPService) RegisterV4(w http.ResponseWriter, r *http.Request) {
 	s.doRegister(w, r, func(conn io.ReadWriteCloser) platformCloser {
 		return rpc.NewClientV4(conn)
@@ -440,6 +596,20 @@ func getInstanceID(req *http.Request) flux.InstanceID {
 	return flux.InstanceID(s)
 }
 
+// getCause gives who's making a request, and why. If the request has
+// been authenticated, the user is who it was authenticated as;
+// otherwise it's whoever the client says.
+func getCause(req *http.Request) flux.Cause {
+	user := req.Header.Get(flux.UserIDHeaderKey)
+	if user == "" {
+		user = req.FormValue("user")
+	}
+	return flux.Cause{
+		User:    user,
+		Message: req.FormValue("message"),
+	}
+}
+
 func jsonResponse(w http.ResponseWriter, r *http.Request, result interface{}) {
 	body, err := json.Marshal(result)
 	if err != nil {
@@ -29,10 +29,17 @@ func NewRouter() *mux.Router {
 	r.NewRoute().Name("ListImages").Methods("GET").Path("/v3/images").Queries("service", "{service}")
 	r.NewRoute().Name("PostRelease").Methods("POST").Path("/v4/release").Queries("service", "{service}", "image", "{image}", "kind", "{kind}")
 	r.NewRoute().Name("GetRelease").Methods("GET").Path("/v4/release").Queries("id", "{id}")
+	r.NewRoute().Name("ListDeliveries").Methods("GET").Path("/v5/deliveries")
+	r.NewRoute().Name("PostRollback").Methods("POST").Path("/v5/rollback").Queries("release", "{release}", "kind", "{kind}")
 	r.NewRoute().Name("Automate").Methods("POST").Path("/v3/automate").Queries("service", "{service}")
 	r.NewRoute().Name("Deautomate").Methods("POST").Path("/v3/deautomate").Queries("service", "{service}")
 	r.NewRoute().Name("Lock").Methods("POST").Path("/v3/lock").Queries("service", "{service}")
 	r.NewRoute().Name("Unlock").Methods("POST").Path("/v3/unlock").Queries("service", "{service}")
+	r.NewRoute().Name("SetImageOrder").Methods("POST").Path("/v5/image-order").Queries("service", "{service}", "order", "{order}")
+	r.NewRoute().Name("SetTagFilter").Methods("POST").Path("/v5/tag-filter").Queries("service", "{service}", "filter", "{filter}")
+	r.NewRoute().Name("SetAutoRollback").Methods("POST").Path("/v5/auto-rollback").Queries("service", "{service}", "enabled", "{enabled}")
+	r.NewRoute().Name("SetSyncPaused").Methods("POST").Path("/v5/sync-paused").Queries("paused", "{paused}")
+	r.NewRoute().Name("Drift").Methods("GET").Path("/v5/drift")
 	r.NewRoute().Name("History").Methods("GET").Path("/v3/history").Queries("service", "{service}")
 	r.NewRoute().Name("Status").Methods("GET").Path("/v3/status")
 	r.NewRoute().Name("GetConfig").Methods("GET").Path("/v4/config")
@@ -3,6 +3,7 @@ package flux
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -18,13 +19,28 @@ var (
 	ErrInvalidImageID   = errors.New("invalid image ID")
 	ErrBlankImageID     = errors.Wrap(ErrInvalidImageID, "blank image name")
 	ErrMalformedImageID = errors.Wrap(ErrInvalidImageID, `expected image name as either <image>:<tag> or just <image>`)
+	ErrMalformedDigest  = errors.Wrap(ErrInvalidImageID, `expected digest in the form <algorithm>:<hex>, e.g., sha256:...`)
+)
+
+var (
+	// tag and digest formats from
+	// https://github.com/docker/distribution/blob/master/reference/regexp.go
+	tagRegexp    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
 )
 
 // ImageID is a fully qualified name that refers to a particular Image.
-// It is in the format: host[:port]/Namespace/Image[:tag]
-// Here, we refer to the "name" == Namespace/Image
+// It is in the format: host[:port]/Namespace/Image[:tag][@digest]
+// Here, we refer to the "name" == Namespace/Image. The Namespace may
+// itself contain slashes, for registries that allow nested
+// repositories; it may also be empty if the image lives at the top
+// level of a (non-dockerhub) registry.
 type ImageID struct {
 	Host, Namespace, Image, Tag string
+	// Digest, if present, pins the image to specific content,
+	// e.g., "sha256:0123...". An image may have a digest with or
+	// without a tag.
+	Digest string
 }
 
 func ParseImageID(s string) (ImageID, error) {
@@ -32,51 +48,83 @@ func ParseImageID(s string) (ImageID, error) {
 		return ImageID{}, ErrBlankImageID
 	}
 	var img ImageID
-	parts := strings.Split(s, ":")
-	switch len(parts) {
-	case 0:
-		return ImageID{}, ErrMalformedImageID
-	case 1:
+	if at := strings.Index(s, "@"); at > -1 {
+		img.Digest = s[at+1:]
+		if !digestRegexp.MatchString(img.Digest) {
+			return ImageID{}, ErrMalformedDigest
+		}
+		s = s[:at]
+	}
+	if s == "" {
+		return ImageID{}, ErrBlankImageID
+	}
+
+	// A tag can only appear after the last slash; any colon before
+	// that belongs to the host (as a port).
+	if colon := strings.LastIndex(s, ":"); colon > strings.LastIndex(s, "/") {
+		img.Tag = s[colon+1:]
+		if !tagRegexp.MatchString(img.Tag) {
+			return ImageID{}, ErrMalformedImageID
+		}
+		s = s[:colon]
+	} else if img.Digest == "" {
 		img.Tag = "latest"
-	case 2:
-		img.Tag = parts[1]
-		s = parts[0]
-	default:
-		return ImageID{}, ErrMalformedImageID
 	}
 	if s == "" {
 		return ImageID{}, ErrBlankImageID
 	}
-	parts = strings.Split(s, "/")
-	switch len(parts) {
-	case 1:
-		img.Host = dockerHubHost
-		img.Namespace = dockerHubLibrary
-		img.Image = parts[0]
-	case 2:
-		img.Host = dockerHubHost
-		img.Namespace = parts[0]
-		img.Image = parts[1]
-	case 3:
+
+	parts := strings.Split(s, "/")
+	for _, part := range parts {
+		if part == "" {
+			return ImageID{}, ErrMalformedImageID
+		}
+	}
+	if len(parts) > 1 && looksLikeHost(parts[0]) {
 		img.Host = parts[0]
-		img.Namespace = parts[1]
-		img.Image = parts[2]
-	default:
-		return ImageID{}, ErrMalformedImageID
+		parts = parts[1:]
+	} else {
+		img.Host = dockerHubHost
+		if len(parts) == 1 {
+			parts = []string{dockerHubLibrary, parts[0]}
+		}
+	}
+	for _, part := range parts {
+		if strings.Contains(part, ":") {
+			return ImageID{}, ErrMalformedImageID
+		}
 	}
+	img.Namespace = strings.Join(parts[:len(parts)-1], "/")
+	img.Image = parts[len(parts)-1]
 	return img, nil
 }
 
+// looksLikeHost decides whether the first component of an image name
+// is a registry host, following the same rules as docker: it's a host
+// if it has a domain (a dot) or a port, or is "localhost".
+func looksLikeHost(s string) bool {
+	return strings.ContainsAny(s, ".:") || s == "localhost"
+}
+
 // Fully qualified name
 func (i ImageID) String() string {
 	if i.Image == "" {
 		return "" // Doesn't make sense to return anything if it doesn't even have an image
 	}
-	var ta string
+	return i.Repository() + i.suffix()
+}
+
+// suffix gives the tag and digest parts of the image ID, as they
+// would appear at the end of the image reference.
+func (i ImageID) suffix() string {
+	var s string
 	if i.Tag != "" {
-		ta = fmt.Sprintf(":%s", i.Tag)
+		s = ":" + i.Tag
+	}
+	if i.Digest != "" {
+		s += "@" + i.Digest
 	}
-	return fmt.Sprintf("%s%s", i.Repository(), ta)
+	return s
 }
 
 // ImageID is serialized/deserialized as a string
@@ -104,19 +152,56 @@ func (i ImageID) Repository() string {
 
 // HostNamespaceImage includes all parts of the image, even if it is from dockerhub.
 func (i ImageID) HostNamespaceImage() string {
-	return fmt.Sprintf("%s/%s/%s", i.Host, i.Namespace, i.Image)
+	return fmt.Sprintf("%s/%s", i.Host, i.NamespaceImage())
 }
 
 func (i ImageID) NamespaceImage() string {
+	if i.Namespace == "" {
+		return i.Image
+	}
 	return fmt.Sprintf("%s/%s", i.Namespace, i.Image)
 }
 
 func (i ImageID) FullID() string {
-	return fmt.Sprintf("%s/%s/%s:%s", i.Host, i.Namespace, i.Image, i.Tag)
+	return i.HostNamespaceImage() + i.suffix()
 }
 
 func (i ImageID) Components() (host, repo, tag string) {
-	return i.Host, fmt.Sprintf("%s/%s", i.Namespace, i.Image), i.Tag
+	return i.Host, i.NamespaceImage(), i.Tag
+}
+
+// UpToDateWith says whether an image running as `i` would be left
+// alone if asked to update it to `other`. This is the case if they
+// are the same; or, if `other` has no digest (as with images listed
+// from a registry), if they have the same tag, so that pinning a
+// digest does not cause the same tag to be released again.
+func (i ImageID) UpToDateWith(other ImageID) bool {
+	if other.Digest == "" {
+		i.Digest = ""
+	}
+	return i == other
+}
+
+// Reference is what you would ask a registry for to get this
+// particular image: the digest if there is one, since that's exact,
+// and otherwise the tag.
+func (i ImageID) Reference() string {
+	if i.Digest != "" {
+		return i.Digest
+	}
+	return i.Tag
+}
+
+// TagOrDigest is a short form of the image version suitable for
+// display, e.g., in release summaries.
+func (i ImageID) TagOrDigest() string {
+	switch {
+	case i.Digest == "":
+		return i.Tag
+	case i.Tag == "":
+		return i.Digest
+	}
+	return i.Tag + "@" + i.Digest
 }
 
 // Image can't really be a primitive string only, because we need to also
@@ -6,6 +6,8 @@ import (
 	"testing"
 )
 
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
 func TestImageID_ParseImageID(t *testing.T) {
 	for _, x := range []struct {
 		test     string
@@ -17,6 +19,13 @@ func TestImageID_ParseImageID(t *testing.T) {
 		{"quay.io/library/alpine", "quay.io/library/alpine:latest"},
 		{"quay.io/library/alpine:latest", "quay.io/library/alpine:latest"},
 		{"quay.io/library/alpine:mytag", "quay.io/library/alpine:mytag"},
+		{"localhost:5000/alpine", "localhost:5000/alpine:latest"},
+		{"localhost:5000/library/alpine:mytag", "localhost:5000/library/alpine:mytag"},
+		{"registry.example.com:443/team/project/alpine:mytag", "registry.example.com:443/team/project/alpine:mytag"},
+		{"gcr.io/a/b/c/d/alpine:mytag", "gcr.io/a/b/c/d/alpine:mytag"},
+		{"alpine@" + testDigest, "alpine@" + testDigest},
+		{"alpine:mytag@" + testDigest, "alpine:mytag@" + testDigest},
+		{"localhost:5000/alpine:mytag@" + testDigest, "localhost:5000/alpine:mytag@" + testDigest},
 	} {
 		i, err := ParseImageID(x.test)
 		if err != nil {
@@ -37,6 +46,13 @@ func TestImageID_ParseImageIDErrorCases(t *testing.T) {
 		{"alpine::"},
 		{"alpine:invalid:"},
 		{"/too/many/slashes/"},
+		{"double//slash"},
+		{"alpine@"},
+		{"alpine@sha256:"},
+		{"alpine@sha256:nothex"},
+		{"alpine:mytag@" + testDigest + "@" + testDigest},
+		{"alpine:in valid"},
+		{"@" + testDigest},
 	} {
 		_, err := ParseImageID(x.test)
 		if err == nil {
@@ -72,6 +88,55 @@ func TestImageID_TestComponents(t *testing.T) {
 
 }
 
+func TestImageID_TestComponentsWithPortAndDigest(t *testing.T) {
+	fqn := "localhost:5000/some/nested/myrepo:mytag@" + testDigest
+	i, err := ParseImageID(fqn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct {
+		test     string
+		expected string
+	}{
+		{i.Host, "localhost:5000"},
+		{i.Namespace, "some/nested"},
+		{i.Image, "myrepo"},
+		{i.Tag, "mytag"},
+		{i.Digest, testDigest},
+		{i.Repository(), "localhost:5000/some/nested/myrepo"},
+		{i.NamespaceImage(), "some/nested/myrepo"},
+		{i.FullID(), fqn},
+		{i.Reference(), testDigest},
+		{i.TagOrDigest(), "mytag@" + testDigest},
+		{i.String(), fqn},
+	} {
+		if x.test != x.expected {
+			t.Fatalf("Expected %v, but got %v", x.expected, x.test)
+		}
+	}
+}
+
+func TestImageID_UpToDateWith(t *testing.T) {
+	pinned, _ := ParseImageID("alpine:1.0@" + testDigest)
+	for _, x := range []struct {
+		other    string
+		expected bool
+	}{
+		{"alpine:1.0", true},
+		{"alpine:1.0@" + testDigest, true},
+		{"alpine:1.1", false},
+		{"alpine:1.0@sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", false},
+	} {
+		other, err := ParseImageID(x.other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pinned.UpToDateWith(other) != x.expected {
+			t.Errorf("Expected %v.UpToDateWith(%v) to be %v", pinned, other, x.expected)
+		}
+	}
+}
+
 func TestImageID_Serialization(t *testing.T) {
 	for _, x := range []struct {
 		test     ImageID
@@ -79,6 +144,8 @@ func TestImageID_Serialization(t *testing.T) {
 	}{
 		{ImageID{Host: dockerHubHost, Namespace: dockerHubLibrary, Image: "alpine", Tag: "a123"}, `"alpine:a123"`},
 		{ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "foobar", Tag: "baz"}, `"quay.io/weaveworks/foobar:baz"`},
+		{ImageID{Host: "localhost:5000", Namespace: "", Image: "foobar", Tag: "baz"}, `"localhost:5000/foobar:baz"`},
+		{ImageID{Host: "quay.io", Namespace: "weaveworks/nested", Image: "foobar", Digest: testDigest}, `"quay.io/weaveworks/nested/foobar@` + testDigest + `"`},
 	} {
 		serialized, err := json.Marshal(x.test)
 		if err != nil {
@@ -5,8 +5,16 @@ import (
 )
 
 type ServiceConfig struct {
-	Automated bool `json:"automation"`
-	Locked    bool `json:"locked"`
+	Automated  bool            `json:"automation"`
+	Locked     bool            `json:"locked"`
+	ImageOrder flux.ImageOrder `json:"image_order,omitempty"`
+	TagFilter  flux.TagFilter  `json:"tag_filter,omitempty"`
+	// AutoRollback opts the service in to having a release rolled
+	// back if it fails to apply.
+	AutoRollback bool `json:"auto_rollback,omitempty"`
+	// Lock says why the service is locked, by whom, and until when,
+	// if that was given.
+	Lock *flux.LockInfo `json:"lock,omitempty"`
 }
 
 func (c ServiceConfig) Policy() flux.Policy {
@@ -22,6 +30,9 @@ func (c ServiceConfig) Policy() flux.Policy {
 type Config struct {
 	Services map[flux.ServiceID]ServiceConfig `json:"services"`
 	Settings flux.UnsafeInstanceConfig        `json:"settings"`
+	// SyncPaused stops the cluster being brought in line with the
+	// repo, until it's unpaused.
+	SyncPaused bool `json:"sync_paused,omitempty"`
 }
 
 type NamedConfig struct {
@@ -1,8 +1,13 @@
 package instance
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/jobs"
 )
 
 type EventReadWriter struct {
@@ -25,3 +30,68 @@ func (rw EventReadWriter) EventsForService(service flux.ServiceID) ([]flux.Event
 func (rw EventReadWriter) GetEvent(id flux.EventID) (flux.Event, error) {
 	return rw.db.GetEvent(id)
 }
+
+func (rw EventReadWriter) ReleaseEvent(id flux.ReleaseID) (flux.Event, error) {
+	return rw.db.ReleaseEvent(rw.inst, id)
+}
+
+// NotifyingEventWriter writes events to the history, then queues a
+// job to deliver each event to every notifier that wants it. Webhooks
+// are sent every event they want; the other kinds of notifier are
+// only told of releases and rollbacks that were applied.
+type NotifyingEventWriter struct {
+	inst      flux.InstanceID
+	events    history.EventWriter
+	jobs      jobs.JobReadPusher
+	notifiers []flux.NotifierConfig
+}
+
+func NewNotifyingEventWriter(inst flux.InstanceID, events history.EventWriter, js jobs.JobReadPusher, notifiers []flux.NotifierConfig) NotifyingEventWriter {
+	return NotifyingEventWriter{
+		inst:      inst,
+		events:    events,
+		jobs:      js,
+		notifiers: notifiers,
+	}
+}
+
+func (w NotifyingEventWriter) LogEvent(e flux.Event) error {
+	// So that the time is the same in what's delivered as in the
+	// history
+	if e.StartedAt.IsZero() {
+		e.StartedAt = time.Now().UTC()
+	}
+	if err := w.events.LogEvent(e); err != nil {
+		return err
+	}
+	for _, notifier := range w.notifiers {
+		if !notifier.Wants(e.Type) || !delivers(notifier.NotifierType(), e) {
+			continue
+		}
+		if _, err := w.jobs.PutJob(w.inst, jobs.Job{
+			Queue:    jobs.NotifyJob,
+			Method:   jobs.NotifyJob,
+			Priority: jobs.PriorityBackground,
+			Params: jobs.NotifyJobParams{
+				Type:  notifier.NotifierType(),
+				URL:   notifier.Address(),
+				Event: e,
+			},
+		}); err != nil {
+			return errors.Wrapf(err, "queueing delivery of event to %s notifier", notifier.NotifierType())
+		}
+	}
+	return nil
+}
+
+// delivers says whether a notifier of the type given is told of the
+// event at all.
+func delivers(notifierType string, e flux.Event) bool {
+	if notifierType == flux.NotifierTypeWebhook {
+		return true
+	}
+	metadata, ok := e.Metadata.(flux.ReleaseEventMetadata)
+	return ok &&
+		metadata.Release.Spec.Kind == flux.ReleaseKindExecute &&
+		metadata.Release.Status != flux.ReleaseStatusAwaitingMerge
+}
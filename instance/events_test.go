@@ -0,0 +1,81 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/jobs"
+)
+
+// jobQueue records the jobs put.
+type jobQueue struct {
+	jobs.JobReadPusher
+	jobs []jobs.Job
+}
+
+func (q *jobQueue) PutJob(_ flux.InstanceID, job jobs.Job) (jobs.JobID, error) {
+	q.jobs = append(q.jobs, job)
+	return jobs.NewJobID(), nil
+}
+
+func TestNotifyingEventWriter(t *testing.T) {
+	events := &eventLog{EventReader: history.NewMock()}
+	queue := &jobQueue{}
+	w := NewNotifyingEventWriter(testInstanceID, events, queue, []flux.NotifierConfig{
+		{Type: flux.NotifierTypeSlack, HookURL: "https://hooks.slack.com/services/XYZ"},
+		{Type: flux.NotifierTypeWebhook, HookURL: "https://example.com/all", Secret: "s3cr3t"},
+		{Type: flux.NotifierTypeWebhook, HookURL: "https://example.com/locks", Secret: "s3cr3t", Events: []string{flux.EventLock}},
+	})
+
+	if err := w.LogEvent(flux.Event{
+		ServiceIDs: []flux.ServiceID{"default/helloworld"},
+		Type:       flux.EventAutomate,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events.events) != 1 {
+		t.Fatalf("expected event to be written, got %#v", events.events)
+	}
+	if len(queue.jobs) != 1 {
+		t.Fatalf("expected one delivery to be queued, got %#v", queue.jobs)
+	}
+	job := queue.jobs[0]
+	params, ok := job.Params.(jobs.NotifyJobParams)
+	if job.Method != jobs.NotifyJob || !ok || params.Type != flux.NotifierTypeWebhook || params.URL != "https://example.com/all" {
+		t.Errorf("expected notify job for the webhook taking all events, got %#v", job)
+	}
+	if params.Event.Type != flux.EventAutomate || params.Event.StartedAt.IsZero() || !params.Event.StartedAt.Equal(events.events[0].StartedAt) {
+		t.Errorf("expected the event as written to be delivered, got %#v", params.Event)
+	}
+
+	if err := w.LogEvent(flux.Event{Type: flux.EventLock}); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.jobs) != 3 {
+		t.Errorf("expected a delivery to each webhook, got %#v", queue.jobs[1:])
+	}
+
+	// Releases go to the other notifiers too, once they've been
+	// applied
+	queue.jobs = nil
+	release := flux.Release{Spec: flux.ReleaseSpec{Kind: flux.ReleaseKindExecute}, Status: flux.ReleaseStatusAwaitingMerge}
+	if err := w.LogEvent(flux.Event{Type: flux.EventRelease, Metadata: flux.ReleaseEventMetadata{Release: release}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.jobs) != 1 {
+		t.Errorf("expected a proposed release to be delivered only to the webhook, got %#v", queue.jobs)
+	}
+	queue.jobs = nil
+	release.Status = flux.ReleaseStatusSuccess
+	if err := w.LogEvent(flux.Event{Type: flux.EventRelease, Metadata: flux.ReleaseEventMetadata{Release: release}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.jobs) != 2 {
+		t.Fatalf("expected the release to be delivered to the webhook and to Slack, got %#v", queue.jobs)
+	}
+	params = queue.jobs[0].Params.(jobs.NotifyJobParams)
+	if params.Type != flux.NotifierTypeSlack || params.URL != "https://hooks.slack.com/services/XYZ" {
+		t.Errorf("expected a delivery to Slack, got %#v", params)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	fluxmetrics "github.com/weaveworks/flux/metrics"
 	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/registry"
+	"github.com/weaveworks/flux/semver"
 )
 
 type Instancer interface {
@@ -53,15 +54,25 @@ func New(
 
 type ImageMap map[string][]flux.ImageDescription
 
-// LatestImage returns the latest releasable image for a repository.
-// A releasable image is one that is not tagged "latest". (Assumes the
-// available images are in descending order of latestness.) If no such
-// image exists, returns nil, and the caller can decide whether that's
-// an error or not.
-func (m ImageMap) LatestImage(repo string) *flux.ImageDescription {
+// LatestImage returns the latest releasable image for a repository,
+// according to the order given. A releasable image is one that is
+// not tagged "latest", and has a tag that gets through the filter
+// given. (Assumes the available images are in descending order of
+// creation time.) If no such image exists, returns nil, and the
+// caller can decide whether that's an error or not. A filter that
+// can't be parsed lets nothing through.
+func (m ImageMap) LatestImage(repo string, order flux.ImageOrder, filter flux.TagFilter) *flux.ImageDescription {
+	matcher, err := flux.ParseTagFilter(string(filter))
+	if err != nil {
+		return nil
+	}
+	switch order {
+	case flux.ImageOrderSemver, flux.ImageOrderSemverReleases:
+		return m.latestSemverImage(repo, order == flux.ImageOrderSemverReleases, matcher)
+	}
 	for _, image := range m[repo] {
 		_, _, tag := image.ID.Components()
-		if strings.EqualFold(tag, "latest") {
+		if strings.EqualFold(tag, "latest") || !matcher.Matches(tag) {
 			continue
 		}
 		return &image
@@ -69,6 +80,29 @@ func (m ImageMap) LatestImage(repo string) *flux.ImageDescription {
 	return nil
 }
 
+// latestSemverImage returns the image with the highest semantic
+// version tag, ignoring tags that aren't semantic versions (and
+// prereleases, if asked to). Where two tags have the same
+// precedence, e.g., "v1.0.0" and "1.0.0", the most recent wins.
+func (m ImageMap) latestSemverImage(repo string, releasesOnly bool, filter flux.TagMatcher) *flux.ImageDescription {
+	var latest *flux.ImageDescription
+	var latestVersion semver.Version
+	for _, image := range m[repo] {
+		version, err := semver.Parse(image.ID.Tag)
+		if err != nil || !filter.Matches(image.ID.Tag) {
+			continue
+		}
+		if releasesOnly && version.IsPrerelease() {
+			continue
+		}
+		if latest == nil || latestVersion.LessThan(version) {
+			image := image
+			latest, latestVersion = &image, version
+		}
+	}
+	return latest
+}
+
 func (h *Instance) ConfigRepo() git.Repo {
 	return h.Repo
 }
@@ -90,6 +124,27 @@ func (h *Instance) GetServices(ids []flux.ServiceID) ([]platform.Service, error)
 	return h.Platform.SomeServices(ids)
 }
 
+// ResolveServiceID gives the ID by which the platform knows the
+// service or workload given; e.g., a deployment addressed as
+// "default:deployment/helloworld" is known as "default/helloworld" if
+// that's the service in front of it, and a daemonset with no service
+// in front of it, addressed as "kube-system/weave-net", is known as
+// "kube-system:daemonset/weave-net". Config and history use the ID
+// the platform knows, so that it doesn't matter which way a service is
+// addressed. If the platform doesn't know the service, the ID is
+// returned as given; if the platform can't be asked, it's returned as
+// given along with the error.
+func (h *Instance) ResolveServiceID(id flux.ServiceID) (flux.ServiceID, error) {
+	services, err := h.Platform.SomeServices([]flux.ServiceID{id})
+	if err != nil {
+		return id, errors.Wrapf(err, "resolving service %s", id)
+	}
+	if len(services) != 1 {
+		return id, nil
+	}
+	return services[0].ID, nil
+}
+
 // Get the images available for the services given. An image may be
 // mentioned more than once in the services, but will only be fetched
 // once.
@@ -182,7 +237,7 @@ func (h *Instance) imageExists(imageID flux.ImageID) (bool, error) {
 		return false, err
 	}
 	// Get a specific image.
-	_, err = h.Registry.GetImage(registry.RepositoryFromImage(img), img.Tag)
+	_, err = h.Registry.GetImage(registry.RepositoryFromImage(img), img.Reference())
 	if err != nil {
 		return false, nil
 	}
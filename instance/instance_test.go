@@ -1,9 +1,12 @@
 package instance
 
 import (
+	"errors"
+	"testing"
+
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/registry"
-	"testing"
 )
 
 var (
@@ -47,3 +50,78 @@ func TestInstance_ErrWhenBlank(t *testing.T) {
 		t.Fatal("Was expecting error")
 	}
 }
+
+func TestImageMap_LatestImage(t *testing.T) {
+	var images []flux.ImageDescription
+	// in descending order of creation time, as from the registry
+	for _, tag := range []string{"latest", "v1.2.9", "master-a000001", "v2.0.0-rc.1", "v1.3.0", "1.10.0-beta", "v1.2.8"} {
+		id, _ := flux.ParseImageID("weaveworks/helloworld:" + tag)
+		images = append(images, flux.ImageDescription{ID: id})
+	}
+	m := ImageMap{"weaveworks/helloworld": images}
+
+	for _, x := range []struct {
+		order    flux.ImageOrder
+		filter   flux.TagFilter
+		expected string
+	}{
+		{"", "", "v1.2.9"},
+		{flux.ImageOrderCreated, "", "v1.2.9"},
+		{flux.ImageOrderSemver, "", "v2.0.0-rc.1"},
+		{flux.ImageOrderSemverReleases, "", "v1.3.0"},
+		{flux.ImageOrderCreated, "master-*", "master-a000001"},
+		{flux.ImageOrderCreated, "~1.3", "v1.3.0"},
+		{flux.ImageOrderSemver, "~1.2", "v1.2.9"},
+		{flux.ImageOrderSemver, "regexp:v1\\..*", "v1.3.0"},
+		{flux.ImageOrderSemverReleases, "semver:^1", "v1.3.0"},
+	} {
+		latest := m.LatestImage("weaveworks/helloworld", x.order, x.filter)
+		if latest == nil {
+			t.Fatalf("Expected latest image for order %q and filter %q, got nil", x.order, x.filter)
+		}
+		if latest.ID.Tag != x.expected {
+			t.Errorf("Expected latest image for order %q and filter %q to be %q, got %q", x.order, x.filter, x.expected, latest.ID.Tag)
+		}
+	}
+
+	if latest := m.LatestImage("weaveworks/helloworld", flux.ImageOrderCreated, "pr-*"); latest != nil {
+		t.Errorf("Expected no image through filter, got %v", latest.ID)
+	}
+	if latest := m.LatestImage("weaveworks/nonexistent", flux.ImageOrderSemver, ""); latest != nil {
+		t.Errorf("Expected no latest image, got %v", latest.ID)
+	}
+	id, _ := flux.ParseImageID("weaveworks/helloworld:master-a000002")
+	noSemver := ImageMap{"weaveworks/helloworld": []flux.ImageDescription{{ID: id}}}
+	if latest := noSemver.LatestImage("weaveworks/helloworld", flux.ImageOrderSemver, ""); latest != nil {
+		t.Errorf("Expected no latest semver image, got %v", latest.ID)
+	}
+}
+
+func TestInstance_ResolveServiceID(t *testing.T) {
+	mockPlatform := &platform.MockPlatform{
+		SomeServicesAnswer: []platform.Service{{ID: "kube-system:daemonset/weave-net"}},
+	}
+	i := Instance{Platform: mockPlatform}
+
+	// A service ID is given as the platform knows it
+	if id, err := i.ResolveServiceID("kube-system/weave-net"); err != nil || id != "kube-system:daemonset/weave-net" {
+		t.Errorf("expected the workload's ID, got %q (err %v)", id, err)
+	}
+
+	// A workload ID, as the service in front of the workload
+	mockPlatform.SomeServicesAnswer = []platform.Service{{ID: "default/helloworld"}}
+	if id, err := i.ResolveServiceID("default:deployment/helloworld"); err != nil || id != "default/helloworld" {
+		t.Errorf("expected the service's ID, got %q (err %v)", id, err)
+	}
+
+	// Something the platform doesn't know is left as it is
+	mockPlatform.SomeServicesAnswer = nil
+	if id, err := i.ResolveServiceID("default/unknown"); err != nil || id != "default/unknown" {
+		t.Errorf("expected ID to be used as given, got %q (err %v)", id, err)
+	}
+
+	mockPlatform.SomeServicesError = errors.New("platform unavailable")
+	if id, err := i.ResolveServiceID("kube-system/weave-net"); err == nil || id != "kube-system/weave-net" {
+		t.Errorf("expected the platform's error and the ID as given, got %q (err %v)", id, err)
+	}
+}
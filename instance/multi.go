@@ -9,6 +9,7 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/jobs"
 	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/registry"
 )
@@ -20,6 +21,10 @@ type MultitenantInstancer struct {
 	History             history.DB
 	MemcacheClient      registry.MemcacheClient
 	RegistryCacheExpiry time.Duration
+	GitMirrors          *git.Mirrors
+	// Jobs is where deliveries of events to notifiers are queued; if
+	// it's nil, no one is notified of events.
+	Jobs jobs.JobReadPusher
 }
 
 func (m *MultitenantInstancer) Get(instanceID flux.InstanceID) (*Instance, error) {
@@ -49,14 +54,20 @@ func (m *MultitenantInstancer) Get(instanceID flux.InstanceID) (*Instance, error
 	)
 	reg = registry.NewInstrumentedRegistry(reg)
 
-	repo := gitRepoFromSettings(c.Settings)
-
 	// Events for this instance
 	eventRW := EventReadWriter{instanceID, m.History}
+	var eventW history.EventWriter = eventRW
+	if m.Jobs != nil {
+		eventW = NewNotifyingEventWriter(instanceID, eventRW, m.Jobs, c.Settings.Notifiers)
+	}
 
 	// Configuration for this instance
 	config := configurer{instanceID, m.DB}
 
+	repo := gitRepoFromSettings(c.Settings)
+	repo.Mirrors = m.GitMirrors
+	repo.TrustHosts = trustHosts(config, instanceLogger)
+
 	return New(
 		platform,
 		reg,
@@ -64,7 +75,7 @@ func (m *MultitenantInstancer) Get(instanceID flux.InstanceID) (*Instance, error
 		repo,
 		instanceLogger,
 		eventRW,
-		eventRW,
+		eventW,
 	), nil
 }
 
@@ -78,5 +89,30 @@ func gitRepoFromSettings(settings flux.UnsafeInstanceConfig) git.Repo {
 		Branch: branch,
 		Key:    settings.Git.Key,
 		Path:   settings.Git.Path,
+
+		Username:   settings.Git.Username,
+		Password:   settings.Git.Password,
+		KnownHosts: settings.Git.KnownHosts,
+
+		CommitterName:  settings.Git.CommitterName,
+		CommitterEmail: settings.Git.CommitterEmail,
+		SigningKey:     settings.Git.SigningKey,
+	}
+}
+
+// trustHosts records the git host keys seen on first use, so they're
+// checked from then on. If some have been recorded in the meantime,
+// those stay.
+func trustHosts(config Configurer, logger log.Logger) func(string) {
+	return func(knownHosts string) {
+		err := config.Update(func(c Config) (Config, error) {
+			if c.Settings.Git.KnownHosts == "" {
+				c.Settings.Git.KnownHosts = knownHosts
+			}
+			return c, nil
+		})
+		if err != nil {
+			logger.Log("err", errors.Wrap(err, "recording git host keys"))
+		}
 	}
 }
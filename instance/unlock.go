@@ -0,0 +1,148 @@
+package instance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/jobs"
+)
+
+// lockExpired is the reason recorded for unlocking a service when its
+// lock runs out.
+const lockExpired = "Lock expired"
+
+const unlockCycle = time.Minute
+
+// Unlocker unlocks services once their locks have expired, recording
+// an unlock event for each, as though someone had unlocked it. The
+// unlocking is done in a job for each instance, so that however many
+// fluxsvcs are running, only one unlocks a given service.
+type Unlocker struct {
+	instancer Instancer
+	db        DB
+	jobs      jobs.JobReadPusher
+	logger    log.Logger
+}
+
+func NewUnlocker(instancer Instancer, db DB, jobs jobs.JobReadPusher, logger log.Logger) *Unlocker {
+	return &Unlocker{
+		instancer: instancer,
+		db:        db,
+		jobs:      jobs,
+		logger:    logger,
+	}
+}
+
+// Start queues an unlock job for each instance with an expired lock,
+// every cycle.
+func (u *Unlocker) Start() {
+	u.queueAll(time.Now().UTC())
+	tick := time.Tick(unlockCycle)
+	for now := range tick {
+		u.queueAll(now.UTC())
+	}
+}
+
+func (u *Unlocker) queueAll(now time.Time) {
+	insts, err := u.db.All()
+	if err != nil {
+		u.logger.Log("err", err)
+		return
+	}
+	for _, inst := range insts {
+		if len(expiredLocks(inst.Config, now)) == 0 {
+			continue
+		}
+		_, err := u.jobs.PutJob(inst.ID, unlockJob(inst.ID))
+		if err != nil && err != jobs.ErrJobAlreadyQueued {
+			u.logger.Log("err", errors.Wrap(err, "queueing unlock job"))
+		}
+	}
+}
+
+func unlockJob(instanceID flux.InstanceID) jobs.Job {
+	return jobs.Job{
+		Queue: jobs.UnlockJob,
+		// Key stops us getting two jobs for the same instance
+		Key: strings.Join([]string{
+			jobs.UnlockJob,
+			string(instanceID),
+		}, "|"),
+		Method:   jobs.UnlockJob,
+		Priority: jobs.PriorityBackground,
+		Params: jobs.UnlockJobParams{
+			InstanceID: instanceID,
+		},
+	}
+}
+
+// expiredLocks gives the services whose locks have expired.
+func expiredLocks(config Config, now time.Time) []flux.ServiceID {
+	var ids []flux.ServiceID
+	for id, service := range config.Services {
+		if service.Locked && service.Lock != nil && service.Lock.Expired(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (u *Unlocker) Handle(job *jobs.Job, _ jobs.JobUpdater) ([]jobs.Job, error) {
+	params := job.Params.(jobs.UnlockJobParams)
+	now := time.Now().UTC()
+	config, err := u.db.GetConfig(params.InstanceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting instance config")
+	}
+	var lastErr error
+	for _, id := range expiredLocks(config, now) {
+		if err := u.unlock(params.InstanceID, id, now); err != nil {
+			job.Log = append(job.Log, fmt.Sprintf("Unlocking %s failed: %s", id, err))
+			lastErr = err
+			continue
+		}
+		job.Log = append(job.Log, fmt.Sprintf("Unlocked %s.", id))
+	}
+	return nil, lastErr
+}
+
+func (u *Unlocker) unlock(instID flux.InstanceID, service flux.ServiceID, now time.Time) error {
+	inst, err := u.instancer.Get(instID)
+	if err != nil {
+		return errors.Wrap(err, "getting instance")
+	}
+	// The service may have been unlocked, or locked again, since the
+	// config was read, so it's checked again in the update.
+	unlocked := false
+	if err := inst.UpdateConfig(func(conf Config) (Config, error) {
+		serviceConf, found := conf.Services[service]
+		if !found || !serviceConf.Locked || serviceConf.Lock == nil || !serviceConf.Lock.Expired(now) {
+			return conf, nil
+		}
+		serviceConf.Locked = false
+		serviceConf.Lock = nil
+		conf.Services[service] = serviceConf
+		unlocked = true
+		return conf, nil
+	}); err != nil {
+		return errors.Wrap(err, "unlocking service")
+	}
+	if !unlocked {
+		return nil
+	}
+	return inst.LogEvent(flux.Event{
+		ServiceIDs: []flux.ServiceID{service},
+		Type:       flux.EventUnlock,
+		StartedAt:  now,
+		EndedAt:    now,
+		LogLevel:   flux.LogLevelInfo,
+		Metadata: flux.PolicyEventMetadata{
+			Cause: flux.Cause{User: flux.UserAutomated, Message: lockExpired},
+		},
+	})
+}
@@ -0,0 +1,123 @@
+package instance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/jobs"
+)
+
+const testInstanceID = flux.InstanceID("test")
+
+// configDB is a DB holding the config of the one instance.
+type configDB struct {
+	*MockConfigurer
+}
+
+func (db configDB) UpdateConfig(_ flux.InstanceID, update UpdateFunc) error {
+	return db.Update(update)
+}
+
+func (db configDB) GetConfig(_ flux.InstanceID) (Config, error) {
+	return db.Get()
+}
+
+func (db configDB) All() ([]NamedConfig, error) {
+	return []NamedConfig{{ID: testInstanceID, Config: db.Config}}, nil
+}
+
+type eventLog struct {
+	history.EventReader
+	events []flux.Event
+}
+
+func (l *eventLog) LogEvent(e flux.Event) error {
+	l.events = append(l.events, e)
+	return nil
+}
+
+func TestUnlockExpired(t *testing.T) {
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	config := MakeConfig()
+	config.Services["default/expired"] = ServiceConfig{
+		Locked:    true,
+		Automated: true,
+		Lock:      &flux.LockInfo{Reason: "incident 42", User: "alice", Expires: &past},
+	}
+	config.Services["default/unexpired"] = ServiceConfig{
+		Locked: true,
+		Lock:   &flux.LockInfo{Expires: &future},
+	}
+	config.Services["default/indefinite"] = ServiceConfig{
+		Locked: true,
+		Lock:   &flux.LockInfo{Reason: "do not touch"},
+	}
+
+	db := configDB{&MockConfigurer{Config: config}}
+	events := &eventLog{EventReader: history.NewMock()}
+	instancer := &MockInstancer{Instance: &Instance{
+		Config:      db.MockConfigurer,
+		EventReader: events,
+		EventWriter: events,
+	}}
+	queue := &jobQueue{}
+	unlocker := NewUnlocker(instancer, db, queue, log.NewNopLogger())
+
+	// An unlock job is queued for the instance, since it has an
+	// expired lock ...
+	unlocker.queueAll(now)
+	if len(queue.jobs) != 1 {
+		t.Fatalf("expected one unlock job to be queued, got %#v", queue.jobs)
+	}
+	job := queue.jobs[0]
+	if job.Method != jobs.UnlockJob || job.Params != (jobs.UnlockJobParams{InstanceID: testInstanceID}) {
+		t.Fatalf("expected an unlock job for the instance, got %#v", job)
+	}
+
+	// ... which unlocks the service.
+	if _, err := unlocker.Handle(&job, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	services := db.Config.Services
+	if s := services["default/expired"]; s.Locked || s.Lock != nil {
+		t.Errorf("expected expired lock to be removed, got %#v", s)
+	} else if !s.Automated {
+		t.Error("expected the rest of the service's config to be kept")
+	}
+	if s := services["default/unexpired"]; !s.Locked {
+		t.Error("expected lock that hasn't expired to be kept")
+	}
+	if s := services["default/indefinite"]; !s.Locked {
+		t.Error("expected lock without an expiry to be kept")
+	}
+
+	if len(events.events) != 1 {
+		t.Fatalf("expected one unlock event, got %#v", events.events)
+	}
+	event := events.events[0]
+	if event.Type != flux.EventUnlock || len(event.ServiceIDs) != 1 || event.ServiceIDs[0] != "default/expired" {
+		t.Errorf("expected unlock event for default/expired, got %#v", event)
+	}
+	if cause := event.Cause(); cause.User != flux.UserAutomated {
+		t.Errorf("expected unlock to be recorded as automated, got %#v", cause)
+	}
+
+	// Nothing else has expired, so there's nothing more to do.
+	unlocker.queueAll(now)
+	if len(queue.jobs) != 1 {
+		t.Errorf("expected no more jobs, got %#v", queue.jobs[1:])
+	}
+	if _, err := unlocker.Handle(&job, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(events.events) != 1 {
+		t.Errorf("expected no more events, got %#v", events.events[1:])
+	}
+}
@@ -2,10 +2,17 @@ package github
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
 	gh "github.com/google/go-github/github"
-	"github.com/weaveworks/flux/http/httperror"
 	"golang.org/x/oauth2"
-	"net/http"
+
+	"github.com/weaveworks/flux/http/httperror"
 )
 
 var (
@@ -69,6 +76,176 @@ func (g *github) InsertDeployKey(ownerName string, repoName string, deployKey st
 	return nil
 }
 
+// SetBaseURL points the client at another API endpoint than
+// api.github.com, e.g., that of a GitHub Enterprise installation.
+func (g *github) SetBaseURL(baseURL string) error {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	g.client.BaseURL = u
+	return nil
+}
+
+// CreatePullRequest opens a pull request to merge the branch head
+// into the branch base, and returns its URL.
+func (g *github) CreatePullRequest(ownerName, repoName, head, base, title, body string) (string, error) {
+	pr, resp, err := g.client.PullRequests.Create(ownerName, repoName, &gh.NewPullRequest{
+		Title: &title,
+		Head:  &head,
+		Base:  &base,
+		Body:  &body,
+	})
+	if err != nil {
+		return "", parseError(resp, err)
+	}
+	if pr.HTMLURL == nil {
+		return "", fmt.Errorf("no URL given for pull request")
+	}
+	return *pr.HTMLURL, nil
+}
+
+// FindPullRequest gives the URL of the open pull request to merge the
+// branch head into the branch base, if there is one, or else an empty
+// string.
+func (g *github) FindPullRequest(ownerName, repoName, head, base string) (string, error) {
+	prs, resp, err := g.client.PullRequests.List(ownerName, repoName, &gh.PullRequestListOptions{
+		State: "open",
+		Head:  ownerName + ":" + head,
+		Base:  base,
+	})
+	if err != nil {
+		return "", parseError(resp, err)
+	}
+	for _, pr := range prs {
+		if pr.HTMLURL != nil {
+			return *pr.HTMLURL, nil
+		}
+	}
+	return "", nil
+}
+
+// The states a pull request can be in, as far as releases go.
+const (
+	PullRequestOpen   = "open"
+	PullRequestMerged = "merged"
+	// PullRequestClosed is closed without being merged.
+	PullRequestClosed = "closed"
+)
+
+// PullRequestState gives whether the pull request at the URL given is
+// open, merged, or closed without being merged.
+func (g *github) PullRequestState(ownerName, repoName, pullRequestURL string) (string, error) {
+	number, err := strconv.Atoi(path.Base(pullRequestURL))
+	if err != nil {
+		return "", fmt.Errorf("could not find the number of the pull request in %q", pullRequestURL)
+	}
+	pr, resp, err := g.client.PullRequests.Get(ownerName, repoName, number)
+	if err != nil {
+		return "", parseError(resp, err)
+	}
+	switch {
+	case pr.Merged != nil && *pr.Merged:
+		return PullRequestMerged, nil
+	case pr.State != nil && *pr.State == "closed":
+		return PullRequestClosed, nil
+	default:
+		return PullRequestOpen, nil
+	}
+}
+
+// The states a deployment, and the commit deployed, can be in.
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// Deployment is a deployment of a commit, recorded on GitHub, the
+// status of which can be updated as it goes.
+type Deployment struct {
+	g           *github
+	owner, repo string
+	ref         string
+	environment string
+	setStatus   func(*gh.DeploymentStatusRequest) (*gh.Response, error)
+}
+
+// CreateDeployment records that the commit ref is being deployed to
+// the environment given. Nothing else needs to have succeeded for
+// the commit, since it's deployed regardless.
+func (g *github) CreateDeployment(ownerName, repoName, ref, environment, description string) (*Deployment, error) {
+	autoMerge := false
+	requiredContexts := []string{}
+	d, resp, err := g.client.Repositories.CreateDeployment(ownerName, repoName, &gh.DeploymentRequest{
+		Ref:              &ref,
+		AutoMerge:        &autoMerge,
+		RequiredContexts: &requiredContexts,
+		Environment:      &environment,
+		Description:      &description,
+	})
+	if err != nil {
+		return nil, parseError(resp, err)
+	}
+	if d.ID == nil {
+		return nil, fmt.Errorf("no ID given for deployment")
+	}
+	id := *d.ID
+	return &Deployment{
+		g:           g,
+		owner:       ownerName,
+		repo:        repoName,
+		ref:         ref,
+		environment: environment,
+		setStatus: func(status *gh.DeploymentStatusRequest) (*gh.Response, error) {
+			_, resp, err := g.client.Repositories.CreateDeploymentStatus(ownerName, repoName, id, status)
+			return resp, err
+		},
+	}, nil
+}
+
+// SetStatus sets the state of the deployment, and of the commit
+// deployed, so that it shows alongside the commit. The commit's
+// status has the context "flux/<environment>", so that deployments to
+// other environments don't overwrite it.
+func (d *Deployment) SetStatus(state, description string) error {
+	if resp, err := d.setStatus(&gh.DeploymentStatusRequest{
+		State:       &state,
+		Description: &description,
+	}); err != nil {
+		return parseError(resp, err)
+	}
+	context := "flux/" + d.environment
+	_, resp, err := d.g.client.Repositories.CreateStatus(d.owner, d.repo, d.ref, &gh.RepoStatus{
+		State:       &state,
+		Description: &description,
+		Context:     &context,
+	})
+	if err != nil {
+		return parseError(resp, err)
+	}
+	return nil
+}
+
+// The owner and name of a repository are the last two parts of the
+// path in its URL, whether that's for SSH (git@github.com:owner/repo.git)
+// or HTTPS (https://github.com/owner/repo).
+var repoURLRegexp = regexp.MustCompile(`([^/:]+)/([^/:]+?)(?:\.git)?/?$`)
+
+// ParseRepoURL gives the owner and name of the repository at a git
+// URL.
+func ParseRepoURL(repoURL string) (ownerName, repoName string, err error) {
+	m := repoURLRegexp.FindStringSubmatch(repoURL)
+	if m == nil {
+		return "", "", fmt.Errorf("could not find the owner and name of the repository in %q", repoURL)
+	}
+	return m[1], m[2], nil
+}
+
 func populateError(err httperror.APIError, resp *gh.Response) *httperror.APIError {
 	err.StatusCode = resp.StatusCode
 	err.Status = resp.Status
@@ -76,6 +253,12 @@ func populateError(err httperror.APIError, resp *gh.Response) *httperror.APIErro
 }
 
 func parseError(resp *gh.Response, err error) error {
+	if resp == nil {
+		// We didn't get as far as a response
+		e := errGeneric
+		e.Body = fmt.Sprintf("%s - %s", e.Body, err.Error())
+		return &e
+	}
 	switch resp.StatusCode {
 	case http.StatusUnauthorized:
 		return populateError(errUnauthorized, resp)
@@ -1,6 +1,7 @@
 package github
 
 import (
+	"encoding/json"
 	"fmt"
 	gh "github.com/google/go-github/github"
 	"net/http"
@@ -30,7 +31,7 @@ func setup() {
 
 	// github client configured to use test server
 	client = gh.NewClient(nil)
-	url, _ := url.Parse(server.URL)
+	url, _ := url.Parse(server.URL + "/")
 	client.BaseURL = url
 	client.UploadURL = url
 }
@@ -108,6 +109,199 @@ func TestInsertDeployKey_KeyDoesExist(t *testing.T) {
 	}
 }
 
+func TestCreatePullRequest(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var pull gh.NewPullRequest
+	mux.HandleFunc("/repos/o/r/pulls", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if err := json.NewDecoder(r.Body).Decode(&pull); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"number":1,"html_url":"https://github.com/o/r/pull/1"}`)
+	})
+
+	g := github{
+		client: client,
+	}
+
+	url, err := g.CreatePullRequest("o", "r", "flux-release", "master", "Release", "Details")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://github.com/o/r/pull/1" {
+		t.Errorf("expected URL of pull request, got %q", url)
+	}
+	if *pull.Head != "flux-release" || *pull.Base != "master" || *pull.Title != "Release" || *pull.Body != "Details" {
+		t.Errorf("unexpected pull request %#v", pull)
+	}
+}
+
+func TestFindPullRequest(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/repos/o/r/pulls", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		q := r.URL.Query()
+		if q.Get("state") != "open" || q.Get("base") != "master" {
+			t.Errorf("expected open pull requests into master to be asked for, got %q", r.URL.RawQuery)
+		}
+		if q.Get("head") == "o:flux-release" {
+			fmt.Fprint(w, `[{"number":1,"html_url":"https://github.com/o/r/pull/1"}]`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+
+	g := github{
+		client: client,
+	}
+
+	url, err := g.FindPullRequest("o", "r", "flux-release", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://github.com/o/r/pull/1" {
+		t.Errorf("expected URL of pull request, got %q", url)
+	}
+	url, err = g.FindPullRequest("o", "r", "another-branch", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "" {
+		t.Errorf("expected no pull request, got %q", url)
+	}
+}
+
+func TestPullRequestState(t *testing.T) {
+	setup()
+	defer teardown()
+
+	for number, pr := range map[string]string{
+		"1": `{"number":1,"state":"open","merged":false}`,
+		"2": `{"number":2,"state":"closed","merged":true}`,
+		"3": `{"number":3,"state":"closed","merged":false}`,
+	} {
+		body := pr
+		mux.HandleFunc("/repos/o/r/pulls/"+number, func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, body)
+		})
+	}
+
+	g := github{
+		client: client,
+	}
+
+	for url, expected := range map[string]string{
+		"https://github.com/o/r/pull/1": PullRequestOpen,
+		"https://github.com/o/r/pull/2": PullRequestMerged,
+		"https://github.com/o/r/pull/3": PullRequestClosed,
+	} {
+		state, err := g.PullRequestState("o", "r", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != expected {
+			t.Errorf("%s: expected %q, got %q", url, expected, state)
+		}
+	}
+	if _, err := g.PullRequestState("o", "r", "https://github.com/o/r/pulls"); err == nil {
+		t.Error("expected an error for a URL without a pull request number")
+	}
+}
+
+func TestCreateDeployment(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var deployment gh.DeploymentRequest
+	mux.HandleFunc("/repos/o/r/deployments", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if err := json.NewDecoder(r.Body).Decode(&deployment); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":42,"sha":"abc123"}`)
+	})
+	var deploymentStatus gh.DeploymentStatusRequest
+	mux.HandleFunc("/repos/o/r/deployments/42/statuses", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if err := json.NewDecoder(r.Body).Decode(&deploymentStatus); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":1,"state":"success"}`)
+	})
+	var commitStatus gh.RepoStatus
+	mux.HandleFunc("/repos/o/r/statuses/abc123", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if err := json.NewDecoder(r.Body).Decode(&commitStatus); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":1,"state":"success"}`)
+	})
+
+	g := github{
+		client: client,
+	}
+
+	d, err := g.CreateDeployment("o", "r", "abc123", "staging", "Release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Ref != "abc123" || *deployment.Environment != "staging" || *deployment.Description != "Release" {
+		t.Errorf("unexpected deployment %#v", deployment)
+	}
+	if deployment.RequiredContexts == nil || len(*deployment.RequiredContexts) != 0 || *deployment.AutoMerge {
+		t.Errorf("expected deployment not to check or merge anything, got %#v", deployment)
+	}
+
+	if err := d.SetStatus(StateSuccess, "Applied"); err != nil {
+		t.Fatal(err)
+	}
+	if *deploymentStatus.State != StateSuccess || *deploymentStatus.Description != "Applied" {
+		t.Errorf("unexpected deployment status %#v", deploymentStatus)
+	}
+	if *commitStatus.State != StateSuccess || *commitStatus.Context != "flux/staging" || *commitStatus.Description != "Applied" {
+		t.Errorf("unexpected commit status %#v", commitStatus)
+	}
+}
+
+func TestCreateDeploymentNotFound(t *testing.T) {
+	setup()
+	defer teardown()
+
+	g := github{
+		client: client,
+	}
+	if _, err := g.CreateDeployment("o", "r", "abc123", "production", "Release"); err == nil {
+		t.Error("expected an error when the repository isn't found")
+	}
+}
+
+func TestParseRepoURL(t *testing.T) {
+	for _, u := range []string{
+		"git@github.com:o/r.git",
+		"git@github.com:o/r",
+		"ssh://git@github.com/o/r.git",
+		"https://github.com/o/r",
+		"https://github.com/o/r.git/",
+	} {
+		owner, repo, err := ParseRepoURL(u)
+		if err != nil || owner != "o" || repo != "r" {
+			t.Errorf("%s: expected o, r, got %q, %q, %v", u, owner, repo, err)
+		}
+	}
+	if _, _, err := ParseRepoURL("r"); err == nil {
+		t.Error("expected error for URL without an owner")
+	}
+}
+
 func testMethod(t *testing.T, r *http.Request, want string) {
 	if got := r.Method; got != want {
 		t.Errorf("Request method: %v, want %v", got, want)
@@ -101,6 +101,52 @@ func (s *DatabaseStore) GetJob(inst flux.InstanceID, id JobID) (Job, error) {
 	return job, nil
 }
 
+// ListJobs gives the jobs with the method given that an instance has
+// had, most recently submitted first. Jobs are only kept for so long
+// after they finish, so older ones won't be among them.
+func (s *DatabaseStore) ListJobs(inst flux.InstanceID, method string) ([]Job, error) {
+	rows, err := s.conn.Query(`
+		SELECT id, submitted_at
+		  FROM jobs
+		 WHERE instance_id = $1
+		   AND method = $2
+		 ORDER BY submitted_at DESC
+	`, string(inst), method)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing jobs")
+	}
+	var ids []JobID
+	for rows.Next() {
+		var (
+			id        string
+			submitted time.Time
+		)
+		if err := rows.Scan(&id, &submitted); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "listing jobs")
+		}
+		ids = append(ids, JobID(id))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "listing jobs")
+	}
+
+	jobs := make([]Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := s.GetJob(inst, id)
+		if err == ErrNoSuchJob {
+			// It's been cleaned up since it was listed
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		job.Instance, job.ID = inst, id
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
 // PutJobIgnoringDuplicates schedules a job to run. Key field and any
 // duplicates are ignored.
 func (s *DatabaseStore) PutJobIgnoringDuplicates(inst flux.InstanceID, job Job) (JobID, error) {
@@ -330,6 +376,13 @@ func (s *DatabaseStore) scanParams(method string, params []byte) (interface{}, e
 		}
 		err := json.Unmarshal(params, &p)
 		return p, err
+	case RollbackJob:
+		var p RollbackJobParams
+		if params == nil {
+			return p, nil
+		}
+		err := json.Unmarshal(params, &p)
+		return p, err
 	case AutomatedInstanceJob:
 		var p AutomatedInstanceJobParams
 		if params == nil {
@@ -337,6 +390,34 @@ func (s *DatabaseStore) scanParams(method string, params []byte) (interface{}, e
 		}
 		err := json.Unmarshal(params, &p)
 		return p, err
+	case SyncJob:
+		var p SyncJobParams
+		if params == nil {
+			return p, nil
+		}
+		err := json.Unmarshal(params, &p)
+		return p, err
+	case NotifyJob:
+		var p NotifyJobParams
+		if params == nil {
+			return p, nil
+		}
+		err := json.Unmarshal(params, &p)
+		return p, err
+	case UnlockJob:
+		var p UnlockJobParams
+		if params == nil {
+			return p, nil
+		}
+		err := json.Unmarshal(params, &p)
+		return p, err
+	case MergeJob:
+		var p MergeJobParams
+		if params == nil {
+			return p, nil
+		}
+		err := json.Unmarshal(params, &p)
+		return p, err
 	default:
 		return nil, ErrUnknownJobMethod
 	}
@@ -344,16 +425,23 @@ func (s *DatabaseStore) scanParams(method string, params []byte) (interface{}, e
 
 func (s *DatabaseStore) scanResult(method string, result []byte) (interface{}, error) {
 	switch method {
-	case ReleaseJob:
+	case ReleaseJob, RollbackJob, SyncJob, MergeJob:
 		var r flux.ReleaseResult
 		if result == nil {
 			return r, nil
 		}
 		err := json.Unmarshal(result, &r)
 		return r, err
-	case AutomatedInstanceJob:
+	case AutomatedInstanceJob, UnlockJob:
 		// A result is not expected for these jobs
 		return nil, ErrNoResultExpected
+	case NotifyJob:
+		var r NotifyJobResult
+		if result == nil {
+			return r, nil
+		}
+		err := json.Unmarshal(result, &r)
+		return r, err
 	default:
 		return nil, ErrUnknownJobMethod
 	}
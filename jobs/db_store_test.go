@@ -239,6 +239,44 @@ func TestDatabaseStore(t *testing.T) {
 	}
 }
 
+func TestDatabaseStoreListJobs(t *testing.T) {
+	instance := flux.InstanceID("instance")
+	db := Setup(t)
+	defer Cleanup(t, db)
+
+	now := time.Now().UTC()
+	var ids []JobID
+	for i, submitted := range []time.Time{now.Add(-time.Minute), now} {
+		db.now = func(_ dbProxy) (time.Time, error) {
+			return submitted, nil
+		}
+		id, err := db.PutJob(instance, Job{
+			Method: NotifyJob,
+			Params: NotifyJobParams{URL: "https://example.com/hook", Attempt: i},
+		})
+		bailIfErr(t, err)
+		ids = append(ids, id)
+	}
+	// Jobs of other methods, and for other instances, aren't listed
+	_, err := db.PutJob(instance, Job{Method: ReleaseJob, Params: ReleaseJobParams{}})
+	bailIfErr(t, err)
+	_, err = db.PutJob(flux.InstanceID("instance2"), Job{Method: NotifyJob, Params: NotifyJobParams{}})
+	bailIfErr(t, err)
+
+	jobs, err := db.ListJobs(instance, NotifyJob)
+	bailIfErr(t, err)
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	// - Most recent first
+	if jobs[0].ID != ids[1] || jobs[1].ID != ids[0] {
+		t.Errorf("expected jobs %v, most recent first, got %s and %s", ids, jobs[0].ID, jobs[1].ID)
+	}
+	if params, ok := jobs[1].Params.(NotifyJobParams); !ok || params.URL != "https://example.com/hook" {
+		t.Errorf("expected notify params to be decoded, got %#v", jobs[1].Params)
+	}
+}
+
 func TestDatabaseStoreScheduledJobs(t *testing.T) {
 	instance := flux.InstanceID("instance")
 	now := time.Now()
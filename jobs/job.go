@@ -16,9 +16,28 @@ const (
 	// ReleaseJob is the method for a release job
 	ReleaseJob = "release"
 
+	// RollbackJob is the method for a job that rolls back a release
+	RollbackJob = "rollback"
+
 	// AutomatedInstanceJob is the method for a check automated instance job
 	AutomatedInstanceJob = "automated_instance"
 
+	// SyncJob is the method for a job that applies the definitions
+	// in the repo to the cluster
+	SyncJob = "sync"
+
+	// NotifyJob is the method for a job that delivers an event to a
+	// notifier, e.g., a webhook
+	NotifyJob = "notify"
+
+	// UnlockJob is the method for a job that unlocks services whose
+	// locks have expired
+	UnlockJob = "unlock"
+
+	// MergeJob is the method for a job that waits for the pull
+	// request proposing a release to be merged, then applies it
+	MergeJob = "merge"
+
 	// PriorityBackground is priority for background jobs
 	PriorityBackground = 100
 
@@ -50,6 +69,7 @@ type JobStore interface {
 
 type JobReadPusher interface {
 	GetJob(flux.InstanceID, JobID) (Job, error)
+	ListJobs(flux.InstanceID, string) ([]Job, error)
 	PutJob(flux.InstanceID, Job) (JobID, error)
 	PutJobIgnoringDuplicates(flux.InstanceID, Job) (JobID, error)
 }
@@ -131,6 +151,51 @@ func (j *Job) UnmarshalJSON(data []byte) error {
 			}
 		}
 		j.Result = r
+	case RollbackJob:
+		var p RollbackJobParams
+		if wireJob.Params != nil {
+			if err := json.Unmarshal(wireJob.Params, &p); err != nil {
+				return err
+			}
+		}
+		j.Params = p
+		var r flux.ReleaseResult
+		if wireJob.Result != nil {
+			if err := json.Unmarshal(wireJob.Result, &r); err != nil {
+				return err
+			}
+		}
+		j.Result = r
+	case MergeJob:
+		var p MergeJobParams
+		if wireJob.Params != nil {
+			if err := json.Unmarshal(wireJob.Params, &p); err != nil {
+				return err
+			}
+		}
+		j.Params = p
+		var r flux.ReleaseResult
+		if wireJob.Result != nil {
+			if err := json.Unmarshal(wireJob.Result, &r); err != nil {
+				return err
+			}
+		}
+		j.Result = r
+	case NotifyJob:
+		var p NotifyJobParams
+		if wireJob.Params != nil {
+			if err := json.Unmarshal(wireJob.Params, &p); err != nil {
+				return err
+			}
+		}
+		j.Params = p
+		var r NotifyJobResult
+		if wireJob.Result != nil {
+			if err := json.Unmarshal(wireJob.Result, &r); err != nil {
+				return err
+			}
+		}
+		j.Result = r
 	}
 	return nil
 }
@@ -142,7 +207,66 @@ func (params ReleaseJobParams) Spec() flux.ReleaseSpec {
 	return flux.ReleaseSpec(params)
 }
 
+// RollbackJobParams are the params for a rollback job; the release
+// to roll back, and whether to only plan the rollback, or to execute
+// it.
+type RollbackJobParams struct {
+	ReleaseID flux.ReleaseID
+	Kind      flux.ReleaseKind
+	Cause     flux.Cause
+	// Force rolls back services even if they're in a freeze window.
+	Force bool `json:",omitempty"`
+}
+
 // AutomatedInstanceJobParams are the params for an automated_instance job
 type AutomatedInstanceJobParams struct {
 	InstanceID flux.InstanceID
 }
+
+// SyncJobParams are the params for a sync job
+type SyncJobParams struct {
+	InstanceID flux.InstanceID
+}
+
+// UnlockJobParams are the params for an unlock job
+type UnlockJobParams struct {
+	InstanceID flux.InstanceID
+}
+
+// MergeJobParams are the params for a merge job; the release proposed
+// as a pull request.
+type MergeJobParams struct {
+	ReleaseID flux.ReleaseID
+}
+
+// NotifyJobParams are the params for a notify job; the notifier to
+// deliver the event to, and how many times delivery has been tried
+// before.
+type NotifyJobParams struct {
+	// Type is the type of notifier; jobs queued before it was
+	// recorded are all for webhooks, so empty means a webhook.
+	Type string `json:",omitempty"`
+	// URL is the address of the notifier, i.e., its hookURL, or the
+	// addresses emailed by an SMTP notifier.
+	URL     string
+	Event   flux.Event
+	Attempt int
+	// Delivery identifies the delivery across attempts; it's the ID
+	// of the job for the first attempt, so it's empty in that job.
+	Delivery string `json:",omitempty"`
+}
+
+// NotifierType gives the type of notifier the event is for.
+func (params NotifyJobParams) NotifierType() string {
+	if params.Type == "" {
+		return flux.NotifierTypeWebhook
+	}
+	return params.Type
+}
+
+// NotifyJobResult says how delivery went, beyond succeeding or
+// failing. A delivery is dead-lettered when it's failed too many
+// times to try again; it's not retried unless it's queued afresh.
+type NotifyJobResult struct {
+	DeadLetter bool `json:"deadLetter,omitempty"`
+}
@@ -109,6 +109,74 @@ func TestJobEncodingDecoding(t *testing.T) {
 	}
 }
 
+func TestRollbackJobEncodingDecoding(t *testing.T) {
+	now := time.Now().UTC()
+	expected := Job{
+		Instance: flux.InstanceID("instance"),
+		ID:       NewJobID(),
+		Queue:    ReleaseJob,
+		Method:   RollbackJob,
+		Params: RollbackJobParams{
+			ReleaseID: flux.ReleaseID("46ce39e6-711c-e2d2-6a60-51306f111040"),
+			Kind:      flux.ReleaseKindPlan,
+		},
+		ScheduledAt: now,
+		Priority:    PriorityInteractive,
+		Submitted:   now,
+		Result: flux.ReleaseResult{
+			flux.ServiceID("hippo/birdy"): flux.ServiceResult{
+				Status: flux.ReleaseStatusPending,
+			},
+		},
+	}
+	b, err := json.Marshal(expected)
+	bailIfErr(t, err)
+	var got Job
+	bailIfErr(t, json.Unmarshal(b, &got))
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v\nexpected %+v", got, expected)
+	}
+}
+
+func TestNotifyJobEncodingDecoding(t *testing.T) {
+	now := time.Now().UTC()
+	expected := Job{
+		Instance: flux.InstanceID("instance"),
+		ID:       NewJobID(),
+		Queue:    NotifyJob,
+		Method:   NotifyJob,
+		Params: NotifyJobParams{
+			Type: flux.NotifierTypeSlack,
+			URL:  "https://hooks.slack.com/services/XYZ",
+			Event: flux.Event{
+				ServiceIDs: []flux.ServiceID{flux.ServiceID("hippo/birdy")},
+				Type:       flux.EventRelease,
+				StartedAt:  now,
+				EndedAt:    now,
+				LogLevel:   flux.LogLevelError,
+				Metadata: flux.ReleaseEventMetadata{
+					Release: flux.Release{ID: flux.ReleaseID("release1"), Status: flux.ReleaseStatusFailed},
+					Error:   "timed out",
+				},
+			},
+			Attempt: 2,
+		},
+		ScheduledAt: now,
+		Priority:    PriorityBackground,
+		Submitted:   now,
+		Result:      NotifyJobResult{DeadLetter: true},
+	}
+	b, err := json.Marshal(expected)
+	bailIfErr(t, err)
+	var got Job
+	bailIfErr(t, json.Unmarshal(b, &got))
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v\nexpected %+v", got, expected)
+	}
+}
+
 func TestJobEncodingDecodingWithMissingFields(t *testing.T) {
 	now := time.Now().UTC()
 	input := Job{
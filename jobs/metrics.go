@@ -48,6 +48,16 @@ func (i *instrumentedJobStore) GetJob(inst flux.InstanceID, jobID JobID) (j Job,
 	return i.js.GetJob(inst, jobID)
 }
 
+func (i *instrumentedJobStore) ListJobs(inst flux.InstanceID, method string) (js []Job, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ListJobs",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.js.ListJobs(inst, method)
+}
+
 func (i *instrumentedJobStore) PutJob(inst flux.InstanceID, j Job) (jobID JobID, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
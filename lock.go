@@ -0,0 +1,48 @@
+package flux
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LockTimeFormat is how the time a lock expires is shown.
+const LockTimeFormat = "2006-01-02 15:04 MST"
+
+// LockInfo says why a service was locked, who locked it, and when
+// the lock expires, if it does.
+type LockInfo struct {
+	Reason  string     `json:"reason,omitempty"`
+	User    string     `json:"user,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Expired says whether the lock has run out by the time given. A lock
+// without an expiry time never runs out.
+func (l LockInfo) Expired(now time.Time) bool {
+	return l.Expires != nil && !now.Before(*l.Expires)
+}
+
+func (l LockInfo) String() string {
+	var parts []string
+	if l.Reason != "" {
+		parts = append(parts, l.Reason)
+	}
+	if l.User != "" {
+		parts = append(parts, "by "+l.User)
+	}
+	if l.Expires != nil {
+		parts = append(parts, "until "+l.Expires.UTC().Format(LockTimeFormat))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Describe gives a short description of the lock, e.g., for saying
+// why a service was skipped: "locked", followed by any details in
+// brackets.
+func (l LockInfo) Describe() string {
+	if s := l.String(); s != "" {
+		return fmt.Sprintf("locked (%s)", s)
+	}
+	return "locked"
+}
@@ -0,0 +1,40 @@
+package flux
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockInfoExpired(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	for _, c := range []struct {
+		lock    LockInfo
+		expired bool
+	}{
+		{LockInfo{}, false},
+		{LockInfo{Expires: &past}, true},
+		{LockInfo{Expires: &now}, true},
+		{LockInfo{Expires: &future}, false},
+	} {
+		if got := c.lock.Expired(now); got != c.expired {
+			t.Errorf("expected Expired() = %v for lock %#v, got %v", c.expired, c.lock, got)
+		}
+	}
+}
+
+func TestLockInfoDescribe(t *testing.T) {
+	expires := time.Date(2017, 6, 1, 14, 30, 0, 0, time.UTC)
+	for _, c := range []struct {
+		lock     LockInfo
+		expected string
+	}{
+		{LockInfo{}, "locked"},
+		{LockInfo{User: "alice"}, "locked (by alice)"},
+		{LockInfo{Reason: "incident 42", User: "alice", Expires: &expires}, "locked (incident 42, by alice, until 2017-06-01 14:30 UTC)"},
+	} {
+		if got := c.lock.Describe(); got != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, got)
+		}
+	}
+}
@@ -9,9 +9,14 @@ const (
 	LabelMethod  = "method"
 	LabelSuccess = "success"
 
+	LabelInstanceID = "instance_id"
+
 	// Labels for release metrics
 	LabelAction      = "action"
 	LabelReleaseType = "release_type"
 	LabelReleaseKind = "release_kind"
 	LabelStage       = "stage"
+
+	// Labels for notification metrics
+	LabelNotifierType = "notifier_type"
 )
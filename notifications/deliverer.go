@@ -0,0 +1,138 @@
+package notifications
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/jobs"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+const (
+	// MaxDeliveryAttempts is how many times delivery of an event to
+	// a notifier is tried before it's dead-lettered.
+	MaxDeliveryAttempts = 8
+	initialBackoff      = 30 * time.Second
+	maxBackoff          = time.Hour
+)
+
+// Deliverer handles notify jobs, delivering the event in each to its
+// notifier: webhooks are POSTed the event, and the other kinds of
+// notifier are told of the release in it. If delivery fails it's
+// tried again later, backing off each time, until it's been tried
+// MaxDeliveryAttempts times; then it's dead-lettered.
+type Deliverer struct {
+	db     instance.DB
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewDeliverer(db instance.DB) *Deliverer {
+	return &Deliverer{
+		db:     db,
+		client: httpClient,
+		now:    time.Now,
+	}
+}
+
+func (d *Deliverer) Handle(job *jobs.Job, _ jobs.JobUpdater) ([]jobs.Job, error) {
+	params := job.Params.(jobs.NotifyJobParams)
+	notifierType := params.NotifierType()
+
+	config, err := d.db.GetConfig(job.Instance)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting instance config")
+	}
+	notifier, found := findNotifier(flux.InstanceConfig(config.Settings).MigrateSlack().Notifiers, notifierType, params.URL)
+	if !found {
+		// It's been taken out of the config since the event was
+		// logged, so there's no one to tell any more.
+		job.Log = append(job.Log, "Notifier is no longer in the config; not delivering.")
+		return nil, nil
+	}
+	if _, ok := params.Event.Metadata.(flux.ReleaseEventMetadata); !ok && notifierType != flux.NotifierTypeWebhook {
+		// There's no point trying again
+		return nil, fmt.Errorf("%s notifiers can only be told of releases and rollbacks, not %s events", notifierType, params.Event.Type)
+	}
+
+	delivery := string(job.ID)
+	if params.Delivery != "" {
+		delivery = params.Delivery
+	}
+	begin := time.Now()
+	err = d.deliver(notifier, job.Instance, delivery, params.Event)
+	deliveryDuration.With(
+		fluxmetrics.LabelNotifierType, notifierType,
+		fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+	).Observe(time.Since(begin).Seconds())
+	if err == nil {
+		return nil, nil
+	}
+
+	attempt := params.Attempt + 1
+	if attempt >= MaxDeliveryAttempts {
+		deadLetters.With(fluxmetrics.LabelNotifierType, notifierType).Add(1)
+		job.Result = jobs.NotifyJobResult{DeadLetter: true}
+		return nil, errors.Wrapf(err, "dead-lettered after %d attempts", attempt)
+	}
+	retriedDeliveries.With(fluxmetrics.LabelNotifierType, notifierType).Add(1)
+	backoff := deliveryBackoff(attempt)
+	retry := jobs.Job{
+		Queue:       job.Queue,
+		Method:      jobs.NotifyJob,
+		Priority:    job.Priority,
+		ScheduledAt: d.now().Add(backoff),
+		Params: jobs.NotifyJobParams{
+			Type:     params.Type,
+			URL:      params.URL,
+			Event:    params.Event,
+			Attempt:  attempt,
+			Delivery: delivery,
+		},
+	}
+	return []jobs.Job{retry}, errors.Wrapf(err, "will try again in %s", backoff)
+}
+
+func (d *Deliverer) deliver(notifier flux.NotifierConfig, inst flux.InstanceID, delivery string, event flux.Event) error {
+	if notifier.NotifierType() == flux.NotifierTypeWebhook {
+		return postWebhook(d.client, notifier, inst, delivery, event)
+	}
+
+	metadata := event.Metadata.(flux.ReleaseEventMetadata)
+	var releaseError error
+	if metadata.Error != "" {
+		releaseError = errors.New(metadata.Error)
+	}
+	n, err := New(notifier)
+	if err != nil {
+		return err
+	}
+	return n.Release(metadata.Release, releaseError)
+}
+
+func findNotifier(notifiers []flux.NotifierConfig, notifierType, address string) (flux.NotifierConfig, bool) {
+	for _, notifier := range notifiers {
+		if notifier.NotifierType() == notifierType && notifier.Address() == address {
+			return notifier, true
+		}
+	}
+	return flux.NotifierConfig{}, false
+}
+
+// deliveryBackoff gives how long to wait before trying again, for the
+// attempt given (the first retry being attempt 1), doubling each time.
+func deliveryBackoff(attempt int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
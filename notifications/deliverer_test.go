@@ -0,0 +1,404 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/db"
+	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/jobs"
+)
+
+// configDB gives the same config for every instance.
+type configDB struct {
+	instance.DB
+	config instance.Config
+}
+
+func (db configDB) GetConfig(_ flux.InstanceID) (instance.Config, error) {
+	return db.config, nil
+}
+
+// notifyJob is a delivery of a lock event, as queued for webhooks
+// before the type of notifier was recorded.
+func notifyJob(url string, attempt int) *jobs.Job {
+	return &jobs.Job{
+		Instance: flux.InstanceID("instance"),
+		ID:       jobs.JobID("job1"),
+		Queue:    jobs.NotifyJob,
+		Method:   jobs.NotifyJob,
+		Params: jobs.NotifyJobParams{
+			URL: url,
+			Event: flux.Event{
+				ServiceIDs: []flux.ServiceID{"default/helloworld"},
+				Type:       flux.EventLock,
+				LogLevel:   flux.LogLevelInfo,
+				Metadata:   flux.PolicyEventMetadata{Cause: flux.Cause{User: "alice", Message: "incident 42"}},
+			},
+			Attempt: attempt,
+		},
+	}
+}
+
+// releaseJob is a delivery of a failed release to a Slack notifier.
+func releaseJob(t *testing.T, url string) *jobs.Job {
+	return &jobs.Job{
+		Instance: flux.InstanceID("instance"),
+		ID:       jobs.JobID("job1"),
+		Queue:    jobs.NotifyJob,
+		Method:   jobs.NotifyJob,
+		Params: jobs.NotifyJobParams{
+			Type: flux.NotifierTypeSlack,
+			URL:  url,
+			Event: flux.Event{
+				ServiceIDs: []flux.ServiceID{"default/helloworld"},
+				Type:       flux.EventRelease,
+				LogLevel:   flux.LogLevelError,
+				Metadata: flux.ReleaseEventMetadata{
+					Release: exampleRelease(t),
+					Error:   "test-error",
+				},
+			},
+		},
+	}
+}
+
+// jobQueue keeps the jobs put, as they'd be read back.
+type jobQueue struct {
+	jobs.JobReadPusher
+	jobs []*jobs.Job
+}
+
+func (q *jobQueue) PutJob(inst flux.InstanceID, job jobs.Job) (jobs.JobID, error) {
+	job.Instance = inst
+	job.ID = jobs.NewJobID()
+	q.jobs = append(q.jobs, &job)
+	return job.ID, nil
+}
+
+type nopEventWriter struct{}
+
+func (nopEventWriter) LogEvent(flux.Event) error {
+	return nil
+}
+
+// queueDeliveries logs the event, as an instance does, giving the
+// deliveries queued for it.
+func queueDeliveries(t *testing.T, config instance.Config, event flux.Event) []*jobs.Job {
+	queue := &jobQueue{}
+	notifiers := flux.InstanceConfig(config.Settings).MigrateSlack().Notifiers
+	if err := instance.NewNotifyingEventWriter("instance", nopEventWriter{}, queue, notifiers).LogEvent(event); err != nil {
+		t.Fatal(err)
+	}
+	return queue.jobs
+}
+
+func releaseEvent(t *testing.T) flux.Event {
+	return flux.Event{
+		ServiceIDs: []flux.ServiceID{"default/helloworld"},
+		Type:       flux.EventRelease,
+		LogLevel:   flux.LogLevelError,
+		Metadata:   flux.ReleaseEventMetadata{Release: exampleRelease(t)},
+	}
+}
+
+func TestDeliverRelease(t *testing.T) {
+	var body bytes.Buffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(&body, r.Body)
+	}))
+	defer server.Close()
+
+	// The slack field still works, alongside the list
+	d := NewDeliverer(configDB{config: instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			Slack: flux.NotifierConfig{HookURL: server.URL},
+		},
+	}})
+	followUps, err := d.Handle(releaseJob(t, server.URL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followUps) != 0 {
+		t.Errorf("expected no retries, got %#v", followUps)
+	}
+	var message map[string]string
+	if err := json.NewDecoder(&body).Decode(&message); err != nil {
+		t.Fatal(err)
+	}
+	if text := message["text"]; text != "Release all latest to default/helloworld. test-error. failed" {
+		t.Errorf("expected the release, and its error, to be posted to Slack, got %q", text)
+	}
+}
+
+func TestDeliverOnlyReleasesToSlack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected nothing to be posted to Slack")
+	}))
+	defer server.Close()
+
+	d := NewDeliverer(configDB{config: instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			Notifiers: []flux.NotifierConfig{{Type: flux.NotifierTypeSlack, HookURL: server.URL}},
+		},
+	}})
+	job := notifyJob(server.URL, 0)
+	params := job.Params.(jobs.NotifyJobParams)
+	params.Type = flux.NotifierTypeSlack
+	job.Params = params
+	followUps, err := d.Handle(job, nil)
+	if err == nil || len(followUps) != 0 {
+		t.Errorf("expected to fail without trying again, got %#v (err %v)", followUps, err)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	var deliveries int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	now := time.Now().UTC()
+	d := NewDeliverer(configDB{config: instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			Notifiers: []flux.NotifierConfig{{Type: flux.NotifierTypeSlack, HookURL: server.URL}},
+		},
+	}})
+	d.now = func() time.Time { return now }
+
+	// A failed delivery is tried again later
+	followUps, err := d.Handle(releaseJob(t, server.URL), nil)
+	if err == nil {
+		t.Fatal("expected an error from the failed delivery")
+	}
+	if len(followUps) != 1 {
+		t.Fatalf("expected a retry, got %#v", followUps)
+	}
+	retry := followUps[0]
+	params := retry.Params.(jobs.NotifyJobParams)
+	if params.Attempt != 1 || params.Delivery != "job1" || params.Type != flux.NotifierTypeSlack || params.URL != server.URL {
+		t.Errorf("unexpected retry params %#v", params)
+	}
+	if retry.Method != jobs.NotifyJob || !retry.ScheduledAt.Equal(now.Add(initialBackoff)) {
+		t.Errorf("expected retry to be a notify job after %s, got %#v", initialBackoff, retry)
+	}
+
+	// ... backing off more each time
+	job := releaseJob(t, server.URL)
+	params = job.Params.(jobs.NotifyJobParams)
+	params.Attempt = 3
+	job.Params = params
+	followUps, _ = d.Handle(job, nil)
+	if len(followUps) != 1 || !followUps[0].ScheduledAt.Equal(now.Add(8*initialBackoff)) {
+		t.Errorf("expected retry after %s, got %#v", 8*initialBackoff, followUps)
+	}
+
+	// ... until it's been tried enough times, when it's dead-lettered
+	params.Attempt = MaxDeliveryAttempts - 1
+	job.Params = params
+	followUps, err = d.Handle(job, nil)
+	if err == nil || !strings.Contains(err.Error(), "dead-lettered") || len(followUps) != 0 {
+		t.Errorf("expected to give up, with an error, got %#v (err %v)", followUps, err)
+	}
+	if result, ok := job.Result.(jobs.NotifyJobResult); !ok || !result.DeadLetter {
+		t.Errorf("expected the job to be marked as dead-lettered, got %#v", job.Result)
+	}
+	if deliveries != 3 {
+		t.Errorf("expected 3 deliveries, got %d", deliveries)
+	}
+}
+
+func TestDeadLetterSaved(t *testing.T) {
+	var deliveries int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "flux-notifications-testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	dbURL := "file://" + f.Name()
+	if _, err := db.Migrate(dbURL, "../db/migrations"); err != nil {
+		t.Fatal(err)
+	}
+	store, err := jobs.NewDatabaseStore(db.DriverForScheme("file"), dbURL, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDeliverer(configDB{config: instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			Notifiers: []flux.NotifierConfig{{Type: flux.NotifierTypeSlack, HookURL: server.URL}},
+		},
+	}})
+	// So that each retry is due straight away
+	d.now = func() time.Time { return time.Now().Add(-2 * maxBackoff) }
+	worker := jobs.NewWorker(store, log.NewNopLogger(), []string{jobs.NotifyJob})
+	worker.Register(jobs.NotifyJob, d)
+	go worker.Work()
+
+	inst := flux.InstanceID("instance")
+	first, err := store.PutJob(inst, *releaseJob(t, server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the worker to try every time, and give up
+	var deadLetter *jobs.Job
+	for deadline := time.Now().Add(10 * time.Second); deadLetter == nil && time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		queued, err := store.ListJobs(inst, jobs.NotifyJob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, job := range queued {
+			if result, ok := job.Result.(jobs.NotifyJobResult); ok && result.DeadLetter {
+				deadLetter = &queued[i]
+			}
+		}
+	}
+	if err := worker.Stop(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if deadLetter == nil {
+		t.Fatal("expected a dead-lettered job to be saved")
+	}
+	if deliveries != MaxDeliveryAttempts {
+		t.Errorf("expected %d deliveries, got %d", MaxDeliveryAttempts, deliveries)
+	}
+
+	job, err := store.GetJob(inst, deadLetter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, ok := job.Result.(jobs.NotifyJobResult); !ok || !result.DeadLetter {
+		t.Errorf("expected the job read back to be dead-lettered, got %#v", job.Result)
+	}
+	if !job.Done || job.Success || !strings.Contains(job.Status, "dead-lettered") {
+		t.Errorf("expected the job to have failed, got status %q", job.Status)
+	}
+	params := job.Params.(jobs.NotifyJobParams)
+	if params.Attempt != MaxDeliveryAttempts-1 || params.Delivery != string(first) {
+		t.Errorf("expected the last attempt of the delivery, got %#v", params)
+	}
+}
+
+func TestDeliveriesFilteredByEvents(t *testing.T) {
+	rollbacksToSlack := flux.NotifierConfig{Type: flux.NotifierTypeSlack, HookURL: "https://hooks.slack.com/services/rollbacks", Events: []string{flux.EventRollback}}
+	releasesByEmail := smtpConfig("smtp.example.com:587")
+	releasesByEmail.Events = []string{flux.EventRelease}
+	config := instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			// The slack field still works, alongside the list
+			Slack:     flux.NotifierConfig{HookURL: "https://hooks.slack.com/services/all"},
+			Notifiers: []flux.NotifierConfig{rollbacksToSlack, releasesByEmail},
+		},
+	}
+	addresses := func(deliveries []*jobs.Job) []string {
+		var addrs []string
+		for _, job := range deliveries {
+			params := job.Params.(jobs.NotifyJobParams)
+			addrs = append(addrs, params.Type+" "+params.URL)
+		}
+		return addrs
+	}
+
+	release := releaseEvent(t)
+	expected := []string{"slack https://hooks.slack.com/services/all", "smtp " + releasesByEmail.Address()}
+	if got := addresses(queueDeliveries(t, config, release)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected a release to be delivered to %q, got %q", expected, got)
+	}
+
+	rollback := release
+	rollback.Type = flux.EventRollback
+	expected = []string{"slack https://hooks.slack.com/services/all", "slack https://hooks.slack.com/services/rollbacks"}
+	if got := addresses(queueDeliveries(t, config, rollback)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected a rollback to be delivered to %q, got %q", expected, got)
+	}
+}
+
+func TestFailingNotifierDoesNotBlockOthers(t *testing.T) {
+	var notified bool
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified = true
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	smtpServer := newFakeSMTPServer(t, "127.0.0.1:0")
+	defer smtpServer.Close()
+
+	config := instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			Notifiers: []flux.NotifierConfig{
+				{Type: flux.NotifierTypeSlack, HookURL: failing.URL},
+				{Type: "carrier-pigeon", HookURL: "coop"},
+				{Type: flux.NotifierTypeSlack, HookURL: ok.URL},
+				smtpConfig(smtpServer.listener.Addr().String()),
+			},
+		},
+	}
+	deliveries := queueDeliveries(t, config, releaseEvent(t))
+	if len(deliveries) != 4 {
+		t.Fatalf("expected a delivery to each notifier, got %#v", deliveries)
+	}
+
+	d := NewDeliverer(configDB{config: config})
+	var failed []string
+	for _, job := range deliveries {
+		followUps, err := d.Handle(job, nil)
+		if err == nil {
+			continue
+		}
+		failed = append(failed, job.Params.(jobs.NotifyJobParams).Type)
+		if len(followUps) != 1 {
+			t.Errorf("expected failed delivery to be tried again, got %#v", followUps)
+		}
+	}
+	if expected := []string{flux.NotifierTypeSlack, "carrier-pigeon"}; !reflect.DeepEqual(failed, expected) {
+		t.Errorf("expected deliveries to %q to fail, got %q", expected, failed)
+	}
+	if !notified {
+		t.Error("expected the notifiers after the failing ones to be told anyway")
+	}
+	smtpServer.Close()
+	if len(smtpServer.data) == 0 {
+		t.Error("expected the email to be sent anyway")
+	}
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	for attempt, expected := range []time.Duration{
+		1:  initialBackoff,
+		2:  2 * initialBackoff,
+		3:  4 * initialBackoff,
+		10: maxBackoff,
+	} {
+		if expected == 0 {
+			continue
+		}
+		if got := deliveryBackoff(attempt); got != expected {
+			t.Errorf("expected backoff of %s for attempt %d, got %s", expected, attempt, got)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package notifications
+
+import (
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+var (
+	deliveryDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "flux",
+		Subsystem: "notifications",
+		Name:      "delivery_duration_seconds",
+		Help:      "Duration in seconds of each attempt to deliver an event to a notifier.",
+		Buckets:   stdprometheus.DefBuckets,
+	}, []string{fluxmetrics.LabelNotifierType, fluxmetrics.LabelSuccess})
+	retriedDeliveries = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "notifications",
+		Name:      "retried_deliveries_total",
+		Help:      "Number of failed deliveries that have been scheduled to be tried again.",
+	}, []string{fluxmetrics.LabelNotifierType})
+	deadLetters = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "notifications",
+		Name:      "dead_letters_total",
+		Help:      "Number of deliveries given up on after failing too many times.",
+	}, []string{fluxmetrics.LabelNotifierType})
+)
@@ -1,13 +1,10 @@
 package notifications
 
 import (
-	"net/http"
-	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/weaveworks/flux"
-	"github.com/weaveworks/flux/instance"
 )
 
 // Generate an example release
@@ -46,23 +43,3 @@ func exampleRelease(t *testing.T) flux.Release {
 		},
 	}
 }
-
-func TestRelease_DryRun(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t.Error("Expected no http request to have been made")
-	}))
-	defer server.Close()
-
-	// It should send releases to slack
-	r := exampleRelease(t)
-	r.Spec.Kind = flux.ReleaseKindPlan
-	if err := Release(instance.Config{
-		Settings: flux.UnsafeInstanceConfig{
-			Slack: flux.NotifierConfig{
-				HookURL: server.URL,
-			},
-		},
-	}, r, nil); err != nil {
-		t.Fatal(err)
-	}
-}
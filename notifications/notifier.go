@@ -0,0 +1,40 @@
+package notifications
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+)
+
+// Notifier tells people about releases (and rollbacks), over some
+// channel, e.g., Slack or email.
+type Notifier interface {
+	Release(release flux.Release, releaseError error) error
+}
+
+// notifierTypes has a constructor for each type of notifier, keyed by
+// the type as given in the config. To add a new type of notifier,
+// add a constructor here.
+var notifierTypes = map[string]func(flux.NotifierConfig) (Notifier, error){
+	flux.NotifierTypeSlack:   newSlackNotifier,
+	flux.NotifierTypeWebhook: newWebhookNotifier,
+	flux.NotifierTypeSMTP:    newSMTPNotifier,
+}
+
+// New makes the notifier described by the config given, returning an
+// error if there's no such type of notifier, or the config doesn't
+// make sense for it.
+func New(config flux.NotifierConfig) (Notifier, error) {
+	typ := config.Type
+	if typ == "" {
+		typ = flux.NotifierTypeSlack
+	}
+	newNotifier, ok := notifierTypes[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier type %q", typ)
+	}
+	n, err := newNotifier(config)
+	return n, errors.Wrapf(err, "setting up %s notifier", typ)
+}
@@ -17,39 +17,74 @@ import (
 )
 
 const (
-	defaultReleaseTemplate = `Release {{trim (print .Spec.ImageSpec) "<>"}} to {{with .Spec.ServiceSpecs}}{{range $index, $spec := .}}{{if not (eq $index 0)}}, {{if last $index $.Spec.ServiceSpecs}}and {{end}}{{end}}{{trim (print .) "<>"}}{{end}}{{end}}. {{with .Error}}{{.}}. failed{{else}}done{{end}}`
+	defaultReleaseTemplate  = `Release {{trim (print .Spec.ImageSpec) "<>"}} to {{with .Spec.ServiceSpecs}}{{range $index, $spec := .}}{{if not (eq $index 0)}}, {{if last $index $.Spec.ServiceSpecs}}and {{end}}{{end}}{{trim (print .) "<>"}}{{end}}{{end}}. {{with .Error}}{{.}}. failed{{else}}done{{end}}`
+	defaultRollbackTemplate = `Roll back release {{.RollbackOf}} for {{with .Spec.ServiceSpecs}}{{range $index, $spec := .}}{{if not (eq $index 0)}}, {{if last $index $.Spec.ServiceSpecs}}and {{end}}{{end}}{{trim (print .) "<>"}}{{end}}{{end}}. {{with .Error}}{{.}}. failed{{else}}done{{end}}`
 )
 
 var (
 	httpClient = &http.Client{Timeout: 5 * time.Second}
 )
 
+type slackNotifier struct {
+	config flux.NotifierConfig
+}
+
+func newSlackNotifier(config flux.NotifierConfig) (Notifier, error) {
+	if config.HookURL == "" {
+		return nil, errors.New("no hookURL given")
+	}
+	if config.ReleaseTemplate != "" {
+		if _, err := template.New("release").Funcs(templateFuncs).Parse(config.ReleaseTemplate); err != nil {
+			return nil, errors.Wrap(err, "parsing releaseTemplate")
+		}
+	}
+	return slackNotifier{config}, nil
+}
+
+func (n slackNotifier) Release(release flux.Release, releaseError error) error {
+	return slackNotifyRelease(n.config, release, releaseError)
+}
+
 func slackNotifyRelease(config flux.NotifierConfig, release flux.Release, releaseError error) error {
 	if release.Spec.Kind == flux.ReleaseKindPlan {
 		return nil
 	}
 
+	text, err := releaseSummary(config, release, releaseError)
+	if err != nil {
+		return err
+	}
+
+	return notify(config, text)
+}
+
+// releaseSummary gives a line saying what the release was and how it
+// went, from the notifier's releaseTemplate if it has one.
+func releaseSummary(config flux.NotifierConfig, release flux.Release, releaseError error) (string, error) {
 	template := defaultReleaseTemplate
+	if release.RollbackOf != "" {
+		template = defaultRollbackTemplate
+	}
 	if config.ReleaseTemplate != "" {
 		template = config.ReleaseTemplate
 	}
+	return instantiateTemplate("release", template, releaseData(release, releaseError))
+}
 
+// releaseData is what release templates are given: the release, and
+// the error from it as a string, if there was one.
+func releaseData(release flux.Release, releaseError error) interface{} {
 	errorMessage := ""
 	if releaseError != nil {
 		errorMessage = releaseError.Error()
 	}
-	text, err := instantiateTemplate("release", template, struct {
+	return struct {
 		flux.Release
 		Error string
 	}{
 		Release: release,
 		Error:   errorMessage,
-	})
-	if err != nil {
-		return err
 	}
-
-	return notify(config, text)
 }
 
 func notify(config flux.NotifierConfig, text string) error {
@@ -52,6 +52,31 @@ func TestSlackNotifier(t *testing.T) {
 	}
 }
 
+func TestSlackNotifierRollback(t *testing.T) {
+	var bodyBuffer bytes.Buffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(&bodyBuffer, r.Body)
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	release := exampleRelease(t)
+	release.RollbackOf = flux.ReleaseID("abc123")
+	release.Spec.ImageSpec = ""
+	if err := slackNotifyRelease(flux.NotifierConfig{HookURL: server.URL}, release, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]string{}
+	if err := json.NewDecoder(&bodyBuffer).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Roll back release abc123 for default/helloworld. done"
+	if body["text"] != expected {
+		t.Errorf("Expected text to have been set to %q, but got: %q", expected, body["text"])
+	}
+}
+
 func TestSlackNotifierDryRun(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("Expected no request to slack to have been made")
@@ -0,0 +1,277 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+)
+
+const (
+	smtpTimeout = 30 * time.Second
+
+	defaultEmailTextTemplate = `{{.Summary}}
+{{with .Error}}
+Error: {{.}}
+{{end}}
+Release: {{.ID}}
+{{- with .Spec.Cause.User}}
+By: {{.}}{{end}}
+{{- with .Spec.Cause.Message}}
+Message: {{.}}{{end}}
+Started: {{iso8601 .StartedAt}}
+Ended: {{iso8601 .EndedAt}}
+{{range $id, $result := .Result}}
+{{$id}}: {{$result.Status}}{{with $result.Error}} ({{.}}){{end}}
+{{- range $result.PerContainer}}
+  {{.Container}}: {{.Current}} -> {{.Target}}{{end}}
+{{end}}`
+
+	defaultEmailHTMLTemplate = `<html>
+<body>
+<p><strong>{{.Summary}}</strong></p>
+{{with .Error}}<p>Error: {{.}}</p>
+{{end -}}
+<table>
+<tr><th align="left">Release</th><td>{{.ID}}</td></tr>
+{{with .Spec.Cause.User}}<tr><th align="left">By</th><td>{{.}}</td></tr>
+{{end -}}
+{{with .Spec.Cause.Message}}<tr><th align="left">Message</th><td>{{.}}</td></tr>
+{{end -}}
+<tr><th align="left">Started</th><td>{{iso8601 .StartedAt}}</td></tr>
+<tr><th align="left">Ended</th><td>{{iso8601 .EndedAt}}</td></tr>
+</table>
+<table>
+<tr><th align="left">Service</th><th align="left">Status</th><th align="left">Container</th><th align="left">From</th><th align="left">To</th></tr>
+{{range $id, $result := .Result -}}
+<tr><td>{{$id}}</td><td>{{$result.Status}}{{with $result.Error}} ({{.}}){{end}}</td><td></td><td></td><td></td></tr>
+{{range $result.PerContainer}}<tr><td></td><td></td><td>{{.Container}}</td><td>{{.Current}}</td><td>{{.Target}}</td></tr>
+{{end}}{{end -}}
+</table>
+</body>
+</html>
+`
+)
+
+var (
+	emailTextTemplate = template.Must(template.New("email").Funcs(templateFuncs).Parse(defaultEmailTextTemplate))
+	emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("email").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(defaultEmailHTMLTemplate))
+)
+
+// smtpNotifier emails a summary of each release to the addresses
+// given, as plain text and as HTML. The subject is the same line that
+// would be posted to Slack, so it can be changed with a
+// releaseTemplate.
+type smtpNotifier struct {
+	config flux.NotifierConfig
+}
+
+func newSMTPNotifier(config flux.NotifierConfig) (Notifier, error) {
+	if config.SMTP == nil {
+		return nil, errors.New("no smtp settings given")
+	}
+	s := config.SMTP
+	host, _, err := net.SplitHostPort(s.Server)
+	if err != nil {
+		return nil, fmt.Errorf("expected server as host:port, got %q", s.Server)
+	}
+	switch s.TLS {
+	case "", flux.SMTPTLSStartTLS, flux.SMTPTLSImplicit, flux.SMTPTLSNone:
+	default:
+		return nil, fmt.Errorf("unknown tls mode %q; expected %q, %q or %q", s.TLS, flux.SMTPTLSStartTLS, flux.SMTPTLSImplicit, flux.SMTPTLSNone)
+	}
+	// net/smtp won't send a password over an unencrypted connection,
+	// unless it's to localhost, so it'd fail every time.
+	if s.TLS == flux.SMTPTLSNone && s.Username != "" && !isLocalhost(host) {
+		return nil, fmt.Errorf("cannot authenticate as %q with tls %q; use %q or %q, or leave out the username", s.Username, s.TLS, flux.SMTPTLSStartTLS, flux.SMTPTLSImplicit)
+	}
+	if _, err := mail.ParseAddress(s.From); err != nil {
+		return nil, errors.Wrapf(err, "parsing from address %q", s.From)
+	}
+	if len(s.To) == 0 {
+		return nil, errors.New("no to addresses given")
+	}
+	for _, addr := range append(append([]string{}, s.To...), s.Cc...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return nil, errors.Wrapf(err, "parsing address %q", addr)
+		}
+	}
+	if config.ReleaseTemplate != "" {
+		if _, err := template.New("release").Funcs(templateFuncs).Parse(config.ReleaseTemplate); err != nil {
+			return nil, errors.Wrap(err, "parsing releaseTemplate")
+		}
+	}
+	return smtpNotifier{config}, nil
+}
+
+// isLocalhost says whether the host is one that net/smtp will send a
+// password to without TLS.
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func (n smtpNotifier) Release(release flux.Release, releaseError error) error {
+	if release.Spec.Kind == flux.ReleaseKindPlan {
+		return nil
+	}
+	msg, err := emailRelease(n.config, release, releaseError, time.Now())
+	if err != nil {
+		return err
+	}
+	return sendMail(*n.config.SMTP, msg)
+}
+
+// emailRelease makes the email, headers and all, for a release.
+func emailRelease(config flux.NotifierConfig, release flux.Release, releaseError error, now time.Time) ([]byte, error) {
+	summary, err := releaseSummary(config, release, releaseError)
+	if err != nil {
+		return nil, err
+	}
+	data := struct {
+		flux.Release
+		Error   string
+		Summary string
+	}{
+		Release: release,
+		Summary: summary,
+	}
+	if releaseError != nil {
+		data.Error = releaseError.Error()
+	}
+
+	var text, html bytes.Buffer
+	if err := emailTextTemplate.Execute(&text, data); err != nil {
+		return nil, errors.Wrap(err, "rendering plain text email")
+	}
+	if err := emailHTMLTemplate.Execute(&html, data); err != nil {
+		return nil, errors.Wrap(err, "rendering HTML email")
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	s := config.SMTP
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	if len(s.Cc) > 0 {
+		fmt.Fprintf(&msg, "Cc: %s\r\n", strings.Join(s.Cc, ", "))
+	}
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", summary))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n", parts.Boundary())
+	fmt.Fprintf(&msg, "\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// sendMail sends the message to each of the to and cc addresses,
+// securing the connection as the config says to.
+func sendMail(s flux.SMTPConfig, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.Server)
+	if err != nil {
+		return err
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	if s.TLS == flux.SMTPTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.Server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.Server)
+	}
+	if err != nil {
+		return errors.Wrap(err, "connecting to SMTP server")
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "connecting to SMTP server")
+	}
+	defer c.Close()
+
+	if s.TLS == "" || s.TLS == flux.SMTPTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return errors.Wrap(err, "starting TLS with SMTP server")
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return errors.Wrap(err, "authenticating with SMTP server")
+		}
+	}
+
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return errors.Wrapf(err, "parsing from address %q", s.From)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return errors.Wrap(err, "sending email")
+	}
+	for _, addr := range append(append([]string{}, s.To...), s.Cc...) {
+		to, err := mail.ParseAddress(addr)
+		if err != nil {
+			return errors.Wrapf(err, "parsing address %q", addr)
+		}
+		if err := c.Rcpt(to.Address); err != nil {
+			return errors.Wrapf(err, "sending email to %s", to.Address)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "sending email")
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "sending email")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "sending email")
+	}
+	return c.Quit()
+}
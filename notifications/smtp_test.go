@@ -0,0 +1,250 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+// fakeSMTPServer accepts mail, recording what it's sent. It speaks
+// just enough SMTP for net/smtp.
+type fakeSMTPServer struct {
+	listener net.Listener
+	done     chan struct{}
+
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+func newFakeSMTPServer(t *testing.T, addr string) *fakeSMTPServer {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{listener: l, done: make(chan struct{})}
+	go s.serve(t)
+	return s
+}
+
+func (s *fakeSMTPServer) serve(t *testing.T) {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 localhost fake SMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			c.PrintfLine("250-localhost")
+			c.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			if len(fields) == 3 {
+				auth, _ := base64.StdEncoding.DecodeString(fields[2])
+				s.auth = string(auth)
+			}
+			c.PrintfLine("235 OK")
+		case "MAIL":
+			s.from = line
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = append(s.to, line)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 Go ahead")
+			s.data, err = c.ReadDotBytes()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) Close() {
+	s.listener.Close()
+	<-s.done
+}
+
+func smtpConfig(server string) flux.NotifierConfig {
+	return flux.NotifierConfig{
+		Type: flux.NotifierTypeSMTP,
+		SMTP: &flux.SMTPConfig{
+			Server: server,
+			TLS:    flux.SMTPTLSNone,
+			From:   "Flux <flux@example.com>",
+			To:     []string{"alice@example.com", "Bob <bob@example.com>"},
+			Cc:     []string{"ops@example.com"},
+		},
+	}
+}
+
+// readEmail gives the headers and the plain text and HTML parts of
+// the email.
+func readEmail(t *testing.T, data []byte) (*mail.Message, string, string) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative email, got %q (err %v)", msg.Header.Get("Content-Type"), err)
+	}
+	parts := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(content)
+	}
+	return msg, parts["text/plain"], parts["text/html"]
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := newFakeSMTPServer(t, "127.0.0.1:0")
+	defer server.Close()
+
+	notifier, err := New(smtpConfig(server.listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := exampleRelease(t)
+	release.Spec.Cause = flux.Cause{User: "alice", Message: "fixing <the> bug"}
+	if err := notifier.Release(release, fmt.Errorf("test-error")); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	if server.from != "MAIL FROM:<flux@example.com>" {
+		t.Errorf("unexpected sender %q", server.from)
+	}
+	if len(server.to) != 3 || server.to[1] != "RCPT TO:<bob@example.com>" || server.to[2] != "RCPT TO:<ops@example.com>" {
+		t.Errorf("expected to and cc addresses as recipients, got %v", server.to)
+	}
+
+	msg, text, html := readEmail(t, server.data)
+	if subject := msg.Header.Get("Subject"); subject != "Release all latest to default/helloworld. test-error. failed" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	if to := msg.Header.Get("To"); to != "alice@example.com, Bob <bob@example.com>" {
+		t.Errorf("unexpected to header %q", to)
+	}
+	for _, expected := range []string{
+		"Error: test-error",
+		"By: alice",
+		"Message: fixing <the> bug",
+		"default/helloworld: failed (overall-release-error)",
+		"container1: img1:a1 -> img1:a2",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected plain text to contain %q, got:\n%s", expected, text)
+		}
+	}
+	for _, expected := range []string{
+		"<p>Error: test-error</p>",
+		"<td>fixing &lt;the&gt; bug</td>",
+		"<td>container1</td><td>img1:a1</td><td>img1:a2</td>",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected HTML to contain %q, got:\n%s", expected, html)
+		}
+	}
+}
+
+func TestSMTPNotifierAuth(t *testing.T) {
+	server := newFakeSMTPServer(t, "localhost:0")
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	config := smtpConfig(net.JoinHostPort("localhost", port))
+	config.SMTP.Username = "flux"
+	config.SMTP.Password = "s3cr3t"
+	notifier, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Release(exampleRelease(t), nil); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	if server.auth != "\x00flux\x00s3cr3t" {
+		t.Errorf("expected to authenticate as flux, got %q", server.auth)
+	}
+}
+
+func TestSMTPNotifierStartTLSRequired(t *testing.T) {
+	server := newFakeSMTPServer(t, "127.0.0.1:0")
+	defer server.Close()
+
+	config := smtpConfig(server.listener.Addr().String())
+	config.SMTP.TLS = ""
+	notifier, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Release(exampleRelease(t), nil); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected error about STARTTLS not being supported, got %v", err)
+	}
+	if server.data != nil {
+		t.Error("expected nothing to be sent in the clear")
+	}
+}
+
+func TestNewSMTPNotifier(t *testing.T) {
+	for _, change := range []func(*flux.SMTPConfig){
+		func(s *flux.SMTPConfig) { s.Server = "smtp.example.com" },
+		func(s *flux.SMTPConfig) { s.TLS = "sometimes" },
+		func(s *flux.SMTPConfig) { s.From = "" },
+		func(s *flux.SMTPConfig) { s.To = nil },
+		func(s *flux.SMTPConfig) { s.Cc = []string{"not an address"} },
+		func(s *flux.SMTPConfig) { s.Username = "flux" },
+	} {
+		config := smtpConfig("smtp.example.com:587")
+		change(config.SMTP)
+		if _, err := New(config); err == nil {
+			t.Errorf("expected an error for %#v", config.SMTP)
+		}
+	}
+	// A password can go unencrypted to a server on localhost
+	config := smtpConfig("localhost:25")
+	config.SMTP.Username = "flux"
+	if _, err := New(config); err != nil {
+		t.Errorf("expected no error authenticating without TLS to localhost, got %v", err)
+	}
+	if _, err := New(flux.NotifierConfig{Type: flux.NotifierTypeSMTP}); err == nil {
+		t.Error("expected an error when there are no smtp settings")
+	}
+}
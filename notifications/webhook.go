@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+)
+
+const (
+	// WebhookPayloadVersion is the version of the payload POSTed to
+	// webhooks. It changes when the shape of the payload does, so
+	// receivers can tell what they've been sent.
+	WebhookPayloadVersion = 1
+
+	// SignatureHeader has the signature of the payload, as
+	// "sha256=<hex HMAC-SHA256 of the body>", keyed with the
+	// notifier's secret.
+	SignatureHeader = "X-Flux-Signature"
+	// EventHeader has the type of the event sent.
+	EventHeader = "X-Flux-Event"
+	// DeliveryHeader has the ID of the delivery, which is the same
+	// each time delivery of the event is tried.
+	DeliveryHeader = "X-Flux-Delivery"
+)
+
+// WebhookPayload is what's POSTed to webhooks, as JSON.
+type WebhookPayload struct {
+	Version  int             `json:"version"`
+	Instance flux.InstanceID `json:"instanceID"`
+	Delivery string          `json:"delivery"`
+	Event    flux.Event      `json:"event"`
+}
+
+// webhookNotifier only checks its config. Webhooks are sent every
+// event they want, not just releases, by the Deliverer.
+type webhookNotifier struct{}
+
+func newWebhookNotifier(config flux.NotifierConfig) (Notifier, error) {
+	if config.HookURL == "" {
+		return nil, errors.New("no hookURL given")
+	}
+	if u, err := url.Parse(config.HookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("expected an http or https hookURL, got %q", config.HookURL)
+	}
+	if config.Secret == "" {
+		return nil, errors.New("no secret given to sign requests with")
+	}
+	return webhookNotifier{}, nil
+}
+
+func (webhookNotifier) Release(flux.Release, error) error {
+	return nil
+}
+
+// Sign gives the signature of a webhook payload, as sent in the
+// SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postWebhook POSTs the event to the webhook, signed with its secret.
+func postWebhook(client *http.Client, webhook flux.NotifierConfig, inst flux.InstanceID, delivery string, event flux.Event) error {
+	body, err := json.Marshal(WebhookPayload{
+		Version:  WebhookPayloadVersion,
+		Instance: inst,
+		Delivery: delivery,
+		Event:    event,
+	})
+	if err != nil {
+		return errors.Wrap(err, "encoding webhook payload")
+	}
+
+	req, err := http.NewRequest("POST", webhook.HookURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "constructing webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, delivery)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending webhook request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s from webhook (%s)", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
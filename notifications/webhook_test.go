@@ -0,0 +1,72 @@
+package notifications
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+)
+
+func webhookConfig(url string) instance.Config {
+	return instance.Config{
+		Settings: flux.UnsafeInstanceConfig{
+			Notifiers: []flux.NotifierConfig{
+				{Type: flux.NotifierTypeWebhook, HookURL: url, Secret: "s3cr3t"},
+			},
+		},
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	var payload WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, expected := r.Header.Get(SignatureHeader), Sign("s3cr3t", body); got != expected {
+			t.Errorf("expected signature %q, got %q", expected, got)
+		}
+		if got := r.Header.Get(EventHeader); got != flux.EventLock {
+			t.Errorf("expected event header %q, got %q", flux.EventLock, got)
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer server.Close()
+
+	d := NewDeliverer(configDB{config: webhookConfig(server.URL)})
+	followUps, err := d.Handle(notifyJob(server.URL, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followUps) != 0 {
+		t.Errorf("expected no retries, got %#v", followUps)
+	}
+	if payload.Version != WebhookPayloadVersion || payload.Instance != "instance" || payload.Delivery != "job1" {
+		t.Errorf("unexpected payload %#v", payload)
+	}
+	if payload.Event.Type != flux.EventLock || len(payload.Event.ServiceIDs) != 1 || payload.Event.ServiceIDs[0] != "default/helloworld" {
+		t.Errorf("expected the event in the payload, got %#v", payload.Event)
+	}
+}
+
+func TestNewWebhookNotifier(t *testing.T) {
+	for _, config := range []flux.NotifierConfig{
+		{Type: flux.NotifierTypeWebhook, Secret: "s3cr3t"},
+		{Type: flux.NotifierTypeWebhook, HookURL: "ftp://example.com/hook", Secret: "s3cr3t"},
+		{Type: flux.NotifierTypeWebhook, HookURL: "https://example.com/hook"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("expected an error for %#v", config)
+		}
+	}
+	if _, err := New(flux.NotifierConfig{Type: flux.NotifierTypeWebhook, HookURL: "https://example.com/hook", Secret: "s3cr3t"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,215 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	api "k8s.io/client-go/1.5/pkg/api"
+	k8serrors "k8s.io/client-go/1.5/pkg/api/errors"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	apiapps "k8s.io/client-go/1.5/pkg/apis/apps/v1alpha1"
+	apibatch "k8s.io/client-go/1.5/pkg/apis/batch/v1"
+	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/util/strategicpatch"
+	k8syaml "k8s.io/client-go/1.5/pkg/util/yaml"
+)
+
+// The annotation in which the definition last applied is kept. This
+// is the annotation `kubectl apply` uses, so that resources can be
+// applied by either without spurious changes.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// A resource is the part of the Kubernetes API for one kind of pod
+// controller; enough to apply a definition to it. Definitions are
+// passed around as JSON.
+type resource struct {
+	// A value of the (versioned) API type, which tells the
+	// strategic merge how to merge lists, e.g., of containers
+	dataStruct interface{}
+	get        func(namespace, name string) ([]byte, error)
+	create     func(namespace string, def []byte) error
+	patch      func(namespace, name string, patch []byte) error
+}
+
+func (c *Cluster) resourceFor(kind string) (*resource, error) {
+	client := c.client
+	switch kind {
+	case "Deployment":
+		return &resource{
+			dataStruct: &apiext.Deployment{},
+			get: func(ns, name string) ([]byte, error) {
+				return marshal(client.Deployments(ns).Get(name))
+			},
+			create: func(ns string, def []byte) error {
+				var obj apiext.Deployment
+				if err := json.Unmarshal(def, &obj); err != nil {
+					return err
+				}
+				_, err := client.Deployments(ns).Create(&obj)
+				return err
+			},
+			patch: func(ns, name string, patch []byte) error {
+				_, err := client.Deployments(ns).Patch(name, api.StrategicMergePatchType, patch)
+				return err
+			},
+		}, nil
+	case "ReplicationController":
+		return &resource{
+			dataStruct: &v1.ReplicationController{},
+			get: func(ns, name string) ([]byte, error) {
+				return marshal(client.ReplicationControllers(ns).Get(name))
+			},
+			create: func(ns string, def []byte) error {
+				var obj v1.ReplicationController
+				if err := json.Unmarshal(def, &obj); err != nil {
+					return err
+				}
+				_, err := client.ReplicationControllers(ns).Create(&obj)
+				return err
+			},
+			patch: func(ns, name string, patch []byte) error {
+				_, err := client.ReplicationControllers(ns).Patch(name, api.StrategicMergePatchType, patch)
+				return err
+			},
+		}, nil
+	case "DaemonSet":
+		return &resource{
+			dataStruct: &apiext.DaemonSet{},
+			get: func(ns, name string) ([]byte, error) {
+				return marshal(client.DaemonSets(ns).Get(name))
+			},
+			create: func(ns string, def []byte) error {
+				var obj apiext.DaemonSet
+				if err := json.Unmarshal(def, &obj); err != nil {
+					return err
+				}
+				_, err := client.DaemonSets(ns).Create(&obj)
+				return err
+			},
+			patch: func(ns, name string, patch []byte) error {
+				_, err := client.DaemonSets(ns).Patch(name, api.StrategicMergePatchType, patch)
+				return err
+			},
+		}, nil
+	case "Job":
+		return &resource{
+			dataStruct: &apibatch.Job{},
+			get: func(ns, name string) ([]byte, error) {
+				return marshal(client.batch.Jobs(ns).Get(name))
+			},
+			create: func(ns string, def []byte) error {
+				var obj apibatch.Job
+				if err := json.Unmarshal(def, &obj); err != nil {
+					return err
+				}
+				_, err := client.batch.Jobs(ns).Create(&obj)
+				return err
+			},
+			patch: func(ns, name string, patch []byte) error {
+				_, err := client.batch.Jobs(ns).Patch(name, api.StrategicMergePatchType, patch)
+				return err
+			},
+		}, nil
+	case "StatefulSet":
+		return &resource{
+			// StatefulSets were PetSets, until they were renamed;
+			// the fields that matter for merging are the same.
+			dataStruct: &apiapps.PetSet{},
+			get:        client.statefulSets.Get,
+			create:     client.statefulSets.Create,
+			patch:      client.statefulSets.Patch,
+		}, nil
+	}
+	return nil, errors.Errorf("unsupported kind %q", kind)
+}
+
+func marshal(obj interface{}, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+// applyDefinition creates or updates the resource defined (in YAML or
+// JSON), as `kubectl apply` does. The definition as applied is kept in
+// an annotation, so that next time, a three-way merge of that, the new
+// definition and the resource as it is in the cluster can tell which
+// fields to change, which to remove, and which to leave alone (e.g.,
+// the number of replicas, if that's left to an autoscaler).
+func (c *Cluster) applyDefinition(res *resource, namespace, name string, def []byte) error {
+	modified, err := withLastApplied(def)
+	if err != nil {
+		return errors.Wrap(err, "reading definition")
+	}
+
+	current, err := res.get(namespace, name)
+	if k8serrors.IsNotFound(err) {
+		return errors.Wrap(res.create(namespace, modified), "creating resource")
+	}
+	if err != nil {
+		return errors.Wrap(err, "getting resource")
+	}
+
+	original, err := lastApplied(current)
+	if err != nil {
+		return errors.Wrap(err, "reading definition as last applied")
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, res.dataStruct, true)
+	if err != nil {
+		return errors.Wrap(err, "calculating patch")
+	}
+	return errors.Wrap(res.patch(namespace, name, patch), "patching resource")
+}
+
+// withLastApplied gives the definition, as JSON, with the annotation
+// recording it as applied.
+func withLastApplied(def []byte) ([]byte, error) {
+	original, err := k8syaml.ToJSON(def)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(original, &obj); err != nil {
+		return nil, err
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if annotations != nil {
+		// Leave out the record of a previous application
+		delete(annotations, lastAppliedAnnotation)
+		if len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+		if original, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+	annotations[lastAppliedAnnotation] = string(original)
+	return json.Marshal(obj)
+}
+
+// lastApplied gives the definition last applied to the resource, or
+// nil if it's never been applied (e.g., if it was created with
+// `kubectl create`).
+func lastApplied(current []byte) ([]byte, error) {
+	var obj struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(current, &obj); err != nil {
+		return nil, err
+	}
+	if last, ok := obj.Metadata.Annotations[lastAppliedAnnotation]; ok {
+		return []byte(last), nil
+	}
+	return nil, nil
+}
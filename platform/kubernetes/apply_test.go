@@ -0,0 +1,136 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/util/strategicpatch"
+	k8stesting "k8s.io/client-go/1.5/testing"
+)
+
+const helloworldDef = `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld
+  namespace: default
+spec:
+  template:
+    metadata:
+      labels:
+        app: helloworld
+    spec:
+      containers:
+      - name: helloworld
+        image: weaveworks/helloworld:2
+`
+
+func testCluster(client *fake.Clientset) *Cluster {
+	return newCluster(client, "test", log.NewNopLogger())
+}
+
+func helloworld(replicas int32, image string) *apiext.Deployment {
+	return &apiext.Deployment{
+		ObjectMeta: meta("helloworld"),
+		Spec: apiext.DeploymentSpec{
+			Replicas: &replicas,
+			Template: template("helloworld", image),
+		},
+	}
+}
+
+func TestWithLastApplied(t *testing.T) {
+	applied, err := withLastApplied([]byte(helloworldDef))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := lastApplied(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(last, &obj); err != nil {
+		t.Fatalf("expected definition as applied to be JSON, got %q", last)
+	}
+	if obj["kind"] != "Deployment" {
+		t.Errorf("expected definition as applied to be the definition, got %q", last)
+	}
+
+	// Applying again doesn't nest the record of the last application
+	reapplied, err := withLastApplied(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relast, _ := lastApplied(reapplied); string(relast) != string(last) {
+		t.Errorf("expected %q as last applied, got %q", last, relast)
+	}
+
+	if last, _ := lastApplied([]byte(`{"metadata": {}}`)); last != nil {
+		t.Errorf("expected nothing as last applied, got %q", last)
+	}
+}
+
+func TestApplyDefinitionCreates(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	c := testCluster(client)
+	defer c.Stop()
+
+	res, _ := c.resourceFor("Deployment")
+	if err := c.applyDefinition(res, "default", "helloworld", []byte(helloworldDef)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := client.Extensions().Deployments("default").Get("helloworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := d.Spec.Template.Spec.Containers[0].Image; image != "weaveworks/helloworld:2" {
+		t.Errorf("expected image from definition, got %q", image)
+	}
+	if _, ok := d.Annotations[lastAppliedAnnotation]; !ok {
+		t.Error("expected created deployment to record the definition as applied")
+	}
+}
+
+func TestApplyDefinitionPatches(t *testing.T) {
+	current := helloworld(3, "weaveworks/helloworld:1")
+	client := fake.NewSimpleClientset(current)
+	var patch []byte
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch = action.(k8stesting.PatchActionImpl).GetPatch()
+		return true, current, nil
+	})
+	c := testCluster(client)
+	defer c.Stop()
+
+	res, _ := c.resourceFor("Deployment")
+	if err := c.applyDefinition(res, "default", "helloworld", []byte(helloworldDef)); err != nil {
+		t.Fatal(err)
+	}
+	if patch == nil {
+		t.Fatal("expected deployment to be patched")
+	}
+
+	currentJSON, _ := json.Marshal(current)
+	patchedJSON, err := strategicpatch.StrategicMergePatch(currentJSON, patch, &apiext.Deployment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patched apiext.Deployment
+	if err := json.Unmarshal(patchedJSON, &patched); err != nil {
+		t.Fatal(err)
+	}
+	if image := patched.Spec.Template.Spec.Containers[0].Image; image != "weaveworks/helloworld:2" {
+		t.Errorf("expected image to be updated, got %q", image)
+	}
+	// The definition doesn't say how many replicas there should be,
+	// so those there are now are left alone.
+	if replicas := *patched.Spec.Replicas; replicas != 3 {
+		t.Errorf("expected replicas to be left alone, got %d", replicas)
+	}
+	if _, ok := patched.Annotations[lastAppliedAnnotation]; !ok {
+		t.Error("expected patched deployment to record the definition as applied")
+	}
+}
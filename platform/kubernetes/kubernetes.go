@@ -5,8 +5,7 @@
 package kubernetes
 
 import (
-	"os"
-	"os/exec"
+	"strings"
 	"sync"
 
 	"github.com/go-kit/kit/log"
@@ -14,10 +13,12 @@ import (
 	"gopkg.in/yaml.v2"
 	discovery "k8s.io/client-go/1.5/discovery"
 	k8sclient "k8s.io/client-go/1.5/kubernetes"
+	v1batch "k8s.io/client-go/1.5/kubernetes/typed/batch/v1"
 	v1core "k8s.io/client-go/1.5/kubernetes/typed/core/v1"
 	v1beta1extensions "k8s.io/client-go/1.5/kubernetes/typed/extensions/v1beta1"
 	api "k8s.io/client-go/1.5/pkg/api"
 	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	apibatch "k8s.io/client-go/1.5/pkg/apis/batch/v1"
 	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
 	rest "k8s.io/client-go/1.5/rest"
 
@@ -29,6 +30,10 @@ type extendedClient struct {
 	discovery.DiscoveryInterface
 	v1core.CoreInterface
 	v1beta1extensions.ExtensionsInterface
+	// Not embedded, since the extensions API has jobs too; we want
+	// the ones from the batch API.
+	batch        v1batch.BatchInterface
+	statefulSets statefulSetsInterface
 }
 
 type apiObject struct {
@@ -41,11 +46,16 @@ type apiObject struct {
 	} `yaml:"metadata"`
 }
 
-type applyExecFunc func(*Cluster, log.Logger) error
+// An applyExecFunc applies a definition, reporting its progress as it
+// goes.
+type applyExecFunc func(c *Cluster, logger log.Logger, progress func(string)) error
 
 type apply struct {
 	exec    applyExecFunc
 	summary string
+	// background is set if the apply carries on after Apply
+	// returns; it's in progress until it's finished.
+	background bool
 }
 
 // --- add-ons
@@ -82,9 +92,7 @@ func isAddon(obj namespacedLabeled) bool {
 // Cluster is a handle to a Kubernetes API server.
 // (Typically, this code is deployed into the same cluster.)
 type Cluster struct {
-	config  *rest.Config
 	client  extendedClient
-	kubectl string
 	status  *statusMap
 	actionc chan func()
 	version string // string response for the version command.
@@ -93,35 +101,30 @@ type Cluster struct {
 
 // NewCluster returns a usable cluster. Host should be of the form
 // "http://hostname:8080".
-func NewCluster(config *rest.Config, kubectl, version string, logger log.Logger) (*Cluster, error) {
+func NewCluster(config *rest.Config, version string, logger log.Logger) (*Cluster, error) {
 	client, err := k8sclient.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
+	return newCluster(client, version, logger), nil
+}
 
-	if kubectl == "" {
-		kubectl, err = exec.LookPath("kubectl")
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		if _, err := os.Stat(kubectl); err != nil {
-			return nil, err
-		}
-	}
-	logger.Log("kubectl", kubectl)
-
+func newCluster(client k8sclient.Interface, version string, logger log.Logger) *Cluster {
 	c := &Cluster{
-		config:  config,
-		client:  extendedClient{client.Discovery(), client.Core(), client.Extensions()},
-		kubectl: kubectl,
+		client: extendedClient{
+			DiscoveryInterface:  client.Discovery(),
+			CoreInterface:       client.Core(),
+			ExtensionsInterface: client.Extensions(),
+			batch:               client.Batch(),
+			statefulSets:        restStatefulSets{client.Core().GetRESTClient()},
+		},
 		status:  newStatusMap(),
 		actionc: make(chan func()),
 		version: version,
 		logger:  logger,
 	}
 	go c.loop()
-	return c, nil
+	return c
 }
 
 // Stop terminates the goroutine that serializes and executes requests against
@@ -140,29 +143,44 @@ func (c *Cluster) loop() {
 
 // SomeServices returns the services named, missing out any that don't
 // exist in the cluster. They do not necessarily have to be returned
-// in the order requested.
+// in the order requested, nor with the IDs requested: a workload
+// asked for by its own ID will be returned under the ID of the service
+// in front of it, if it has one (see frontingService).
 func (c *Cluster) SomeServices(ids []flux.ServiceID) (res []platform.Service, err error) {
-	namespacedServices := map[string][]string{}
+	namespacedServices := map[string][]flux.ServiceID{}
 	for _, id := range ids {
-		ns, name := id.Components()
-		namespacedServices[ns] = append(namespacedServices[ns], name)
+		ns, _ := id.Components()
+		namespacedServices[ns] = append(namespacedServices[ns], id)
 	}
 
-	for ns, names := range namespacedServices {
-		services := c.client.Services(ns)
+	for ns, ids := range namespacedServices {
 		controllers, err := c.podControllersInNamespace(ns)
 		if err != nil {
 			return nil, errors.Wrapf(err, "finding pod controllers for namespace %s", ns)
 		}
-		for _, name := range names {
-			service, err := services.Get(name)
-			if err != nil {
-				continue
-			}
-			if isAddon(service) {
-				continue
+		list, err := c.client.Services(ns).List(api.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting services for namespace %s", ns)
+		}
+		for _, id := range ids {
+			service, controller, err := findController(id, list.Items, controllers)
+			switch {
+			case service != nil:
+				if !isAddon(service) {
+					res = append(res, c.makeService(ns, service, controllers))
+				}
+			case err == nil:
+				if front := frontingService(controller, list.Items, controllers); front != nil {
+					res = append(res, c.makeService(ns, front, controllers))
+				} else {
+					res = append(res, c.makeWorkloadService(ns, controller))
+				}
+			case err == platform.ErrMultipleMatching:
+				res = append(res, platform.Service{
+					ID:         id,
+					Containers: platform.ContainersOrExcuse{Excuse: err.Error()},
+				})
 			}
-			res = append(res, c.makeService(ns, service, controllers))
 		}
 	}
 	return res, nil
@@ -170,7 +188,8 @@ func (c *Cluster) SomeServices(ids []flux.ServiceID) (res []platform.Service, er
 
 // AllServices returns all services matching the criteria; that is, in
 // the namespace (or any namespace if that argument is empty), and not
-// in the `ignore` set given.
+// in the `ignore` set given. As well as the services, this includes
+// the workloads which aren't the one workload behind a service.
 func (c *Cluster) AllServices(namespace string, ignore flux.ServiceIDSet) (res []platform.Service, err error) {
 	namespaces := []string{}
 	if namespace == "" {
@@ -204,6 +223,15 @@ func (c *Cluster) AllServices(namespace string, ignore flux.ServiceIDSet) (res [
 				res = append(res, c.makeService(ns, &service, controllers))
 			}
 		}
+
+		for _, controller := range controllers {
+			if frontingService(controller, list.Items, controllers) != nil {
+				continue // already included, as the service
+			}
+			if !ignore.Contains(controller.workloadID()) {
+				res = append(res, c.makeWorkloadService(ns, controller))
+			}
+		}
 	}
 	return res, nil
 }
@@ -211,15 +239,46 @@ func (c *Cluster) AllServices(namespace string, ignore flux.ServiceIDSet) (res [
 func (c *Cluster) makeService(ns string, service *v1.Service, controllers []podController) platform.Service {
 	id := flux.MakeServiceID(ns, service.Name)
 	status, _ := c.status.getApplyProgress(id)
+	res := platform.Service{
+		ID:       id,
+		IP:       service.Spec.ClusterIP,
+		Metadata: metadataForService(service),
+		Status:   status,
+	}
+	pc, err := matchController(service, controllers)
+	if err != nil {
+		res.Containers = platform.ContainersOrExcuse{Excuse: err.Error()}
+		return res
+	}
+	res.Containers = platform.ContainersOrExcuse{Containers: pc.templateContainers()}
+	res.Spec = pc.spec()
+	return res
+}
+
+// makeWorkloadService makes a service for a pod controller, addressed
+// by its own ID.
+func (c *Cluster) makeWorkloadService(ns string, controller podController) platform.Service {
+	id := controller.workloadID()
+	status, _ := c.status.getApplyProgress(id)
 	return platform.Service{
 		ID:         id,
-		IP:         service.Spec.ClusterIP,
-		Metadata:   metadataForService(service),
-		Containers: containersOrExcuse(service, controllers),
+		Metadata:   metadataForController(controller),
+		Containers: platform.ContainersOrExcuse{Containers: controller.templateContainers()},
+		Spec:       controller.spec(),
 		Status:     status,
 	}
 }
 
+func metadataForController(p podController) map[string]string {
+	meta := p.objectMeta()
+	return map[string]string{
+		"created_at":       meta.CreationTimestamp.String(),
+		"resource_version": meta.ResourceVersion,
+		"uid":              string(meta.UID),
+		"kind":             p.kind(),
+	}
+}
+
 func metadataForService(s *v1.Service) map[string]string {
 	return map[string]string{
 		"created_at":       s.CreationTimestamp.String(),
@@ -250,10 +309,125 @@ func (c *Cluster) podControllersInNamespace(namespace string) (res []podControll
 		}
 	}
 
+	dslist, err := c.client.DaemonSets(namespace).List(api.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "collecting daemon sets")
+	}
+	for i := range dslist.Items {
+		if !isAddon(&dslist.Items[i]) {
+			res = append(res, podController{DaemonSet: &dslist.Items[i]})
+		}
+	}
+
+	sslist, err := c.client.statefulSets.List(namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "collecting stateful sets")
+	}
+	for i := range sslist {
+		if !isAddon(&sslist[i]) {
+			res = append(res, podController{StatefulSet: &sslist[i]})
+		}
+	}
+
+	joblist, err := c.client.batch.Jobs(namespace).List(api.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "collecting jobs")
+	}
+	for i := range joblist.Items {
+		if !isAddon(&joblist.Items[i]) {
+			res = append(res, podController{Job: &joblist.Items[i]})
+		}
+	}
+
 	return res, nil
 }
 
-// Find the pod controller (deployment or replication controller) that matches the service
+func findService(services []v1.Service, name string) *v1.Service {
+	for i := range services {
+		if services[i].Name == name {
+			return &services[i]
+		}
+	}
+	return nil
+}
+
+// A pod controller is known by the ID of the service in front of it,
+// if there's exactly one such service, and that service selects no
+// other pod controller; otherwise, it's known by its own ID
+// (namespace:kind/name). The same rule is used for the definitions in
+// the config repo (see FindDefinedServices), so the two agree.
+//
+// frontingService returns that service, if there is one.
+func frontingService(pc podController, services []v1.Service, controllers []podController) *v1.Service {
+	var front *v1.Service
+	for i := range services {
+		selector := services[i].Spec.Selector
+		if len(selector) == 0 || !pc.matchedBy(selector) {
+			continue
+		}
+		if front != nil {
+			return nil // more than one service
+		}
+		front = &services[i]
+	}
+	if front == nil {
+		return nil
+	}
+	if matched, err := matchController(front, controllers); err != nil || matched.workloadID() != pc.workloadID() {
+		return nil
+	}
+	return front
+}
+
+// findController finds the pod controller addressed by the ID given,
+// and the service it was addressed via, if it was.
+//
+// Workloads are addressed either directly, by namespace, kind and
+// name; or by the service in front of them. For backward
+// compatibility, a pod controller that no service selects can also be
+// addressed as though it were a service of the same name.
+func findController(id flux.ServiceID, services []v1.Service, controllers []podController) (*v1.Service, podController, error) {
+	_, name := id.Components()
+	if kind := id.Kind(); kind != "" {
+		for _, c := range controllers {
+			if c.name() == name && strings.EqualFold(c.kind(), kind) {
+				return nil, c, nil
+			}
+		}
+		return nil, podController{}, platform.ErrNoMatching
+	}
+
+	if service := findService(services, name); service != nil {
+		controller, err := matchController(service, controllers)
+		return service, controller, err
+	}
+
+	var matching []podController
+	for _, c := range controllers {
+		if c.name() == name && !selectedByAny(c, services) {
+			matching = append(matching, c)
+		}
+	}
+	switch len(matching) {
+	case 1:
+		return nil, matching[0], nil
+	case 0:
+		return nil, podController{}, platform.ErrNoMatchingService
+	default:
+		return nil, podController{}, platform.ErrMultipleMatching
+	}
+}
+
+func selectedByAny(pc podController, services []v1.Service) bool {
+	for _, s := range services {
+		if len(s.Spec.Selector) > 0 && pc.matchedBy(s.Spec.Selector) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the pod controller (e.g., deployment or replication controller) that matches the service
 func matchController(service *v1.Service, controllers []podController) (podController, error) {
 	selector := service.Spec.Selector
 	if len(selector) == 0 {
@@ -276,44 +450,75 @@ func matchController(service *v1.Service, controllers []podController) (podContr
 	}
 }
 
-func containersOrExcuse(service *v1.Service, controllers []podController) platform.ContainersOrExcuse {
-	pc, err := matchController(service, controllers)
-	if err != nil {
-		return platform.ContainersOrExcuse{Excuse: err.Error()}
-	}
-	return platform.ContainersOrExcuse{Containers: pc.templateContainers()}
-}
-
-// Either a replication controller, a deployment, or neither (both nils).
+// One of the kinds of pod controller, or none of them (all nils).
 type podController struct {
 	ReplicationController *v1.ReplicationController
 	Deployment            *apiext.Deployment
+	DaemonSet             *apiext.DaemonSet
+	StatefulSet           *statefulSet
+	Job                   *apibatch.Job
 }
 
-func (p podController) name() string {
-	if p.Deployment != nil {
-		return p.Deployment.Name
-	} else if p.ReplicationController != nil {
-		return p.ReplicationController.Name
+func (p podController) objectMeta() *v1.ObjectMeta {
+	switch {
+	case p.Deployment != nil:
+		return &p.Deployment.ObjectMeta
+	case p.ReplicationController != nil:
+		return &p.ReplicationController.ObjectMeta
+	case p.DaemonSet != nil:
+		return &p.DaemonSet.ObjectMeta
+	case p.StatefulSet != nil:
+		return &p.StatefulSet.ObjectMeta
+	case p.Job != nil:
+		return &p.Job.ObjectMeta
 	}
-	return ""
+	return &v1.ObjectMeta{}
+}
+
+func (p podController) name() string {
+	return p.objectMeta().Name
+}
+
+func (p podController) workloadID() flux.ServiceID {
+	return flux.MakeWorkloadID(p.objectMeta().Namespace, p.kind(), p.name())
 }
 
 func (p podController) kind() string {
-	if p.Deployment != nil {
+	switch {
+	case p.Deployment != nil:
 		return "Deployment"
-	} else if p.ReplicationController != nil {
+	case p.ReplicationController != nil:
 		return "ReplicationController"
+	case p.DaemonSet != nil:
+		return "DaemonSet"
+	case p.StatefulSet != nil:
+		return "StatefulSet"
+	case p.Job != nil:
+		return "Job"
 	}
 	return "unknown"
 }
 
+func (p podController) podTemplate() *v1.PodTemplateSpec {
+	switch {
+	case p.Deployment != nil:
+		return &p.Deployment.Spec.Template
+	case p.ReplicationController != nil:
+		return p.ReplicationController.Spec.Template
+	case p.DaemonSet != nil:
+		return &p.DaemonSet.Spec.Template
+	case p.StatefulSet != nil:
+		return &p.StatefulSet.Spec.Template
+	case p.Job != nil:
+		return &p.Job.Spec.Template
+	}
+	return nil
+}
+
 func (p podController) templateContainers() (res []platform.Container) {
 	var apiContainers []v1.Container
-	if p.Deployment != nil {
-		apiContainers = p.Deployment.Spec.Template.Spec.Containers
-	} else if p.ReplicationController != nil {
-		apiContainers = p.ReplicationController.Spec.Template.Spec.Containers
+	if template := p.podTemplate(); template != nil {
+		apiContainers = template.Spec.Containers
 	}
 
 	for _, c := range apiContainers {
@@ -322,11 +527,27 @@ func (p podController) templateContainers() (res []platform.Container) {
 	return res
 }
 
+// spec gives the configuration of the controller, to compare with
+// its definition.
+func (p podController) spec() *platform.ControllerSpec {
+	res := &platform.ControllerSpec{}
+	switch {
+	case p.Deployment != nil:
+		res.Replicas = p.Deployment.Spec.Replicas
+	case p.ReplicationController != nil:
+		res.Replicas = p.ReplicationController.Spec.Replicas
+	case p.StatefulSet != nil:
+		res.Replicas = p.StatefulSet.Spec.Replicas
+	}
+	if template := p.podTemplate(); template != nil {
+		res.Containers = containerSpecs(template.Spec.Containers)
+	}
+	return res
+}
+
 func (p podController) templateLabels() map[string]string {
-	if p.Deployment != nil {
-		return p.Deployment.Spec.Template.Labels
-	} else if p.ReplicationController != nil {
-		return p.ReplicationController.Spec.Template.Labels
+	if template := p.podTemplate(); template != nil {
+		return template.Labels
 	}
 	return nil
 }
@@ -349,11 +570,10 @@ func (p podController) matchedBy(selector map[string]string) bool {
 // of type ApplyError, which can be inspected for more detailed information.
 // Applies are serialized per cluster.
 //
-// Apply assumes there is a one-to-one mapping between services and replication
-// controllers or deployments; this can be improved. Apply blocks until an
-// update is complete; this can be improved. Apply invokes `kubectl
-// rolling-update` or `kubectl apply` in a seperate process, and assumes
-// kubectl is in the PATH; this can be improved.
+// Apply assumes there is a one-to-one mapping between services and pod
+// controllers, where a definition is for a service rather than for a
+// workload addressed directly. Apply blocks until an update is
+// complete (unless it's asynchronous); this can be improved.
 func (c *Cluster) Apply(defs []platform.ServiceDefinition) error {
 	errc := make(chan error)
 	c.actionc <- func() {
@@ -365,8 +585,6 @@ func (c *Cluster) Apply(defs []platform.ServiceDefinition) error {
 
 		applyErr := platform.ApplyError{}
 		for namespace, defs := range namespacedDefs {
-			services := c.client.Services(namespace)
-
 			controllers, err := c.podControllersInNamespace(namespace)
 			if err != nil {
 				err = errors.Wrapf(err, "getting pod controllers for namespace %s", namespace)
@@ -376,6 +594,15 @@ func (c *Cluster) Apply(defs []platform.ServiceDefinition) error {
 				continue
 			}
 
+			services, err := c.client.Services(namespace).List(api.ListOptions{})
+			if err != nil {
+				err = errors.Wrapf(err, "getting services for namespace %s", namespace)
+				for _, def := range defs {
+					applyErr[def.ServiceID] = err
+				}
+				continue
+			}
+
 			for _, def := range defs {
 				newDef, err := definitionObj(def.NewDefinition)
 				if err != nil {
@@ -383,14 +610,7 @@ func (c *Cluster) Apply(defs []platform.ServiceDefinition) error {
 					continue
 				}
 
-				_, serviceName := def.ServiceID.Components()
-				service, err := services.Get(serviceName)
-				if err != nil {
-					applyErr[def.ServiceID] = errors.Wrap(err, "getting service")
-					continue
-				}
-
-				controller, err := matchController(service, controllers)
+				_, controller, err := findController(def.ServiceID, services.Items, controllers)
 				if err != nil {
 					applyErr[def.ServiceID] = errors.Wrap(err, "getting pod controller")
 					continue
@@ -402,11 +622,24 @@ func (c *Cluster) Apply(defs []platform.ServiceDefinition) error {
 					continue
 				}
 
-				c.status.startApply(def.ServiceID, plan)
-				defer c.status.endApply(def.ServiceID)
-
-				logger := log.NewContext(c.logger).With("method", "Apply", "namespace", namespace, "service", serviceName)
-				if err = plan.exec(c, logger); err != nil {
+				id := def.ServiceID
+				c.status.startApply(id, plan)
+				logger := log.NewContext(c.logger).With("method", "Apply", "namespace", namespace, "service", id)
+				progress := func(summary string) {
+					logger.Log("progress", summary)
+					c.status.updateApply(id, summary)
+				}
+				if plan.background {
+					// The exec logs how it went.
+					go func() {
+						defer c.status.endApply(id)
+						plan.exec(c, logger, progress)
+					}()
+					continue
+				}
+				err = plan.exec(c, logger, progress)
+				c.status.endApply(id)
+				if err != nil {
 					applyErr[def.ServiceID] = errors.Wrapf(err, "applying definition to %s", def.ServiceID)
 					continue
 				}
@@ -463,6 +696,14 @@ func (m *statusMap) getApplyProgress(s flux.ServiceID) (string, bool) {
 	return "", false
 }
 
+func (m *statusMap) updateApply(s flux.ServiceID, summary string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if a, ok := m.inProgress[s]; ok {
+		a.summary = summary
+	}
+}
+
 func (m *statusMap) endApply(s flux.ServiceID) {
 	m.mx.Lock()
 	defer m.mx.Unlock()
@@ -0,0 +1,180 @@
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	apibatch "k8s.io/client-go/1.5/pkg/apis/batch/v1"
+	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/platform"
+)
+
+func meta(name string) v1.ObjectMeta {
+	return v1.ObjectMeta{Name: name, Namespace: "default"}
+}
+
+func template(app, image string) v1.PodTemplateSpec {
+	return v1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"app": app}},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: app, Image: image}},
+		},
+	}
+}
+
+func service(name string, selector map[string]string) v1.Service {
+	return v1.Service{ObjectMeta: meta(name), Spec: v1.ServiceSpec{Selector: selector}}
+}
+
+func testControllers() []podController {
+	return []podController{
+		{Deployment: &apiext.Deployment{
+			ObjectMeta: meta("frontend"),
+			Spec:       apiext.DeploymentSpec{Template: template("frontend", "weaveworks/frontend:1")},
+		}},
+		{DaemonSet: &apiext.DaemonSet{
+			ObjectMeta: meta("agent"),
+			Spec:       apiext.DaemonSetSpec{Template: template("agent", "weaveworks/agent:1")},
+		}},
+		{StatefulSet: &statefulSet{
+			ObjectMeta: meta("db"),
+			Spec:       statefulSetSpec{Template: template("db", "weaveworks/db:1")},
+		}},
+		{Job: &apibatch.Job{
+			ObjectMeta: meta("migrate"),
+			Spec:       apibatch.JobSpec{Template: template("migrate", "weaveworks/migrate:1")},
+		}},
+		{Job: &apibatch.Job{
+			ObjectMeta: meta("agent"),
+			Spec:       apibatch.JobSpec{Template: template("agent-setup", "weaveworks/agent:1")},
+		}},
+	}
+}
+
+func TestPodControllerKinds(t *testing.T) {
+	for i, expected := range []struct {
+		name, kind, image string
+	}{
+		{"frontend", "Deployment", "weaveworks/frontend:1"},
+		{"agent", "DaemonSet", "weaveworks/agent:1"},
+		{"db", "StatefulSet", "weaveworks/db:1"},
+		{"migrate", "Job", "weaveworks/migrate:1"},
+	} {
+		pc := testControllers()[i]
+		if pc.name() != expected.name || pc.kind() != expected.kind {
+			t.Errorf("expected %s %s, got %s %s", expected.kind, expected.name, pc.kind(), pc.name())
+		}
+		containers := pc.templateContainers()
+		if len(containers) != 1 || containers[0].Image != expected.image {
+			t.Errorf("%s: expected containers with image %s, got %#v", expected.name, expected.image, containers)
+		}
+	}
+
+	if (podController{}).kind() != "unknown" || (podController{}).name() != "" {
+		t.Error("expected empty pod controller to have no kind or name")
+	}
+}
+
+func TestPodControllerSpec(t *testing.T) {
+	replicas := int32(3)
+	deployment := helloworld(replicas, "weaveworks/helloworld:1")
+	deployment.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "GREETING", Value: "Ahoy"}}
+	spec := podController{Deployment: deployment}.spec()
+	if spec.Replicas == nil || *spec.Replicas != replicas {
+		t.Errorf("expected %d replicas, got %v", replicas, spec.Replicas)
+	}
+	if len(spec.Containers) != 1 || spec.Containers[0].Image != "weaveworks/helloworld:1" || spec.Containers[0].Env["GREETING"] != "Ahoy" {
+		t.Errorf("expected the container as configured, got %#v", spec.Containers)
+	}
+
+	// Daemon sets don't have a number of replicas
+	if spec := testControllers()[1].spec(); spec.Replicas != nil {
+		t.Errorf("expected no replicas for a daemon set, got %d", *spec.Replicas)
+	}
+}
+
+func TestMatchController(t *testing.T) {
+	controllers := testControllers()
+
+	frontend := service("frontend", map[string]string{"app": "frontend"})
+	pc, err := matchController(&frontend, controllers)
+	if err != nil || pc.Deployment == nil {
+		t.Errorf("expected deployment for service, got %#v, %v", pc, err)
+	}
+
+	db := service("database", map[string]string{"app": "db"})
+	pc, err = matchController(&db, controllers)
+	if err != nil || pc.StatefulSet == nil {
+		t.Errorf("expected stateful set for service, got %#v, %v", pc, err)
+	}
+
+	empty := service("empty", nil)
+	if _, err = matchController(&empty, controllers); err != platform.ErrEmptySelector {
+		t.Errorf("expected %v, got %v", platform.ErrEmptySelector, err)
+	}
+}
+
+func testServices() []v1.Service {
+	return []v1.Service{
+		service("frontend", map[string]string{"app": "frontend"}),
+		service("database", map[string]string{"app": "db"}),
+		service("database-replicas", map[string]string{"app": "db"}),
+		service("headless", nil),
+	}
+}
+
+func TestFrontingService(t *testing.T) {
+	controllers := testControllers()
+	services := testServices()
+
+	if front := frontingService(controllers[0], services, controllers); front == nil || front.Name != "frontend" {
+		t.Errorf("expected deployment to be fronted by service frontend, got %#v", front)
+	}
+	// Two services in front
+	if front := frontingService(controllers[2], services, controllers); front != nil {
+		t.Errorf("expected stateful set to have no single service in front, got %#v", front)
+	}
+	// No service in front
+	if front := frontingService(controllers[3], services, controllers); front != nil {
+		t.Errorf("expected job to have no service in front, got %#v", front)
+	}
+}
+
+func TestFindController(t *testing.T) {
+	controllers := testControllers()
+	services := testServices()
+
+	for _, x := range []struct {
+		id      flux.ServiceID
+		service string
+		kind    string
+		err     error
+	}{
+		// By the service in front
+		{"default/frontend", "frontend", "Deployment", nil},
+		// By its own ID, even though there's a service in front
+		{"default:deployment/frontend", "", "Deployment", nil},
+		{"default:statefulset/db", "", "StatefulSet", nil},
+		{"default:job/agent", "", "Job", nil},
+		{"default:daemonset/agent", "", "DaemonSet", nil},
+		{"default:deployment/db", "", "unknown", platform.ErrNoMatching},
+		// By its name, if there's no service in front
+		{"default/migrate", "", "Job", nil},
+		{"default/agent", "", "unknown", platform.ErrMultipleMatching},
+		{"default/db", "", "unknown", platform.ErrNoMatchingService},
+		{"default/headless", "headless", "unknown", platform.ErrEmptySelector},
+	} {
+		service, pc, err := findController(x.id, services, controllers)
+		if err != x.err {
+			t.Errorf("%s: expected error %v, got %v", x.id, x.err, err)
+		}
+		if pc.kind() != x.kind {
+			t.Errorf("%s: expected %s, got %s", x.id, x.kind, pc.kind())
+		}
+		if (service == nil && x.service != "") || (service != nil && service.Name != x.service) {
+			t.Errorf("%s: expected service %q, got %#v", x.id, x.service, service)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	k8syaml "k8s.io/client-go/1.5/pkg/util/yaml"
+
+	"github.com/weaveworks/flux/platform"
+)
+
+// DefinedSpec returns the configuration of the pod controller given
+// in a resource definition (specified in YAML), in the form reported
+// for a running service, so the two can be compared. The number of
+// replicas is nil if the definition doesn't say, since then it's left
+// to whatever's running (e.g., an autoscaler).
+func DefinedSpec(def []byte) (*platform.ControllerSpec, error) {
+	decoder := k8syaml.NewYAMLToJSONDecoder(bytes.NewReader(def))
+	for {
+		var doc struct {
+			Spec struct {
+				Replicas *int32              `json:"replicas"`
+				Template *v1.PodTemplateSpec `json:"template"`
+			} `json:"spec"`
+		}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse definition: %s", err)
+		}
+		if doc.Spec.Template != nil {
+			return &platform.ControllerSpec{
+				Replicas:   doc.Spec.Replicas,
+				Containers: containerSpecs(doc.Spec.Template.Spec.Containers),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("No pod controller found in definition")
+}
+
+func containerSpecs(containers []v1.Container) []platform.ContainerSpec {
+	var res []platform.ContainerSpec
+	for _, c := range containers {
+		res = append(res, platform.ContainerSpec{
+			Name:      c.Name,
+			Image:     c.Image,
+			Env:       envValues(c.Env),
+			Resources: resourceQuantities(c.Resources),
+		})
+	}
+	return res
+}
+
+// envValues gives the value of each environment variable. Those taken
+// from elsewhere, e.g., a secret, are described by where they're from,
+// since that's what's configured.
+func envValues(env []v1.EnvVar) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+	res := map[string]string{}
+	for _, e := range env {
+		value := e.Value
+		if from := e.ValueFrom; from != nil {
+			switch {
+			case from.SecretKeyRef != nil:
+				value = fmt.Sprintf("<secret %s/%s>", from.SecretKeyRef.Name, from.SecretKeyRef.Key)
+			case from.ConfigMapKeyRef != nil:
+				value = fmt.Sprintf("<configmap %s/%s>", from.ConfigMapKeyRef.Name, from.ConfigMapKeyRef.Key)
+			case from.FieldRef != nil:
+				value = fmt.Sprintf("<field %s>", from.FieldRef.FieldPath)
+			case from.ResourceFieldRef != nil:
+				value = fmt.Sprintf("<resource %s>", from.ResourceFieldRef.Resource)
+			}
+		}
+		res[e.Name] = value
+	}
+	return res
+}
+
+// resourceQuantities gives the resource limits and requests, e.g.,
+// "limits.cpu", with their quantities in canonical form so that, say,
+// "0.5" and "500m" compare as equal.
+func resourceQuantities(resources v1.ResourceRequirements) map[string]string {
+	res := map[string]string{}
+	for name, quantity := range resources.Limits {
+		quantity := quantity
+		res["limits."+string(name)] = quantity.String()
+	}
+	for name, quantity := range resources.Requests {
+		quantity := quantity
+		res["requests."+string(name)] = quantity.String()
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
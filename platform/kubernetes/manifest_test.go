@@ -0,0 +1,83 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/flux/platform"
+	"github.com/weaveworks/flux/platform/kubernetes/testdata"
+)
+
+func TestDefinedSpec(t *testing.T) {
+	spec, err := DefinedSpec([]byte(testdata.Files["helloworld-deploy.yaml"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicas := int32(5)
+	expected := &platform.ControllerSpec{
+		Replicas: &replicas,
+		Containers: []platform.ContainerSpec{
+			{Name: "helloworld", Image: "quay.io/weaveworks/helloworld:master-a000001"},
+			{Name: "sidecar", Image: "quay.io/weaveworks/sidecar:master-a000002"},
+		},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected %#v, got %#v", expected, spec)
+	}
+
+	if spec, err = DefinedSpec([]byte(testdata.Files["helloworld-svc.yaml"])); err == nil {
+		t.Errorf("expected an error for a service with no pod controller, got %#v", spec)
+	}
+}
+
+func TestDefinedSpecEnvAndResources(t *testing.T) {
+	const def = `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: helloworld
+---
+apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: helloworld
+spec:
+  template:
+    spec:
+      containers:
+      - name: helloworld
+        image: weaveworks/helloworld:2
+        env:
+        - name: GREETING
+          value: Ahoy
+        - name: PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: helloworld
+              key: password
+        resources:
+          limits:
+            cpu: "0.5"
+          requests:
+            memory: 64Mi
+`
+	spec, err := DefinedSpec([]byte(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Replicas != nil {
+		t.Errorf("expected no replicas, got %d", *spec.Replicas)
+	}
+	if len(spec.Containers) != 1 {
+		t.Fatalf("expected one container, got %#v", spec.Containers)
+	}
+	c := spec.Containers[0]
+	expectedEnv := map[string]string{"GREETING": "Ahoy", "PASSWORD": "<secret helloworld/password>"}
+	if !reflect.DeepEqual(c.Env, expectedEnv) {
+		t.Errorf("expected env %v, got %v", expectedEnv, c.Env)
+	}
+	expectedResources := map[string]string{"limits.cpu": "500m", "requests.memory": "64Mi"}
+	if !reflect.DeepEqual(c.Resources, expectedResources) {
+		t.Errorf("expected resources %v, got %v", expectedResources, c.Resources)
+	}
+}
@@ -1,21 +1,34 @@
 package kubernetes
 
 import (
-	"bytes"
+	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
+	"hash/fnv"
+	"reflect"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
-	api "k8s.io/client-go/1.5/pkg/api/v1"
+	v1core "k8s.io/client-go/1.5/kubernetes/typed/core/v1"
+	api "k8s.io/client-go/1.5/pkg/api"
+	k8serrors "k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	apibatch "k8s.io/client-go/1.5/pkg/apis/batch/v1"
 	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/labels"
+	k8syaml "k8s.io/client-go/1.5/pkg/util/yaml"
 
 	"github.com/weaveworks/flux/platform"
 )
 
+// How long to wait for a pod controller to finish rolling out, before
+// giving up and reporting it as failed.
+var rolloutTimeout = 5 * time.Minute
+
+// How often to check on the progress of a rollout.
+var rolloutPollInterval = time.Second
+
 func (c podController) newApply(newDefinition *apiObject, async bool) (*apply, error) {
 	k := c.kind()
 	if newDefinition.Kind != k {
@@ -23,112 +36,480 @@ func (c podController) newApply(newDefinition *apiObject, async bool) (*apply, e
 	}
 
 	var result apply
-	if c.Deployment != nil {
-		result.exec = deploymentExec(c.Deployment, newDefinition, async)
+	ns := c.objectMeta().Namespace
+	switch {
+	case c.Deployment != nil:
+		result.exec = applyingExec(ns, newDefinition, async, deploymentRollout)
 		result.summary = "Applying deployment"
-	} else if c.ReplicationController != nil {
-		result.exec = rollingUpgradeExec(c.ReplicationController, newDefinition, async)
+	case c.ReplicationController != nil:
+		result.exec = rollingUpdateExec(c.ReplicationController, newDefinition)
 		result.summary = "Rolling upgrade"
-	} else {
+		// It's us doing the rolling update, rather than the
+		// cluster, so to not wait for it, it's done in the
+		// background.
+		result.background = async
+	case c.DaemonSet != nil:
+		result.exec = applyingExec(ns, newDefinition, async, daemonSetRollout)
+		result.summary = "Applying daemon set"
+	case c.StatefulSet != nil:
+		result.exec = applyingExec(ns, newDefinition, async, statefulSetRollout)
+		result.summary = "Applying stateful set"
+	case c.Job != nil:
+		// The pod template of a job can't be changed, so the job
+		// is deleted and created anew.
+		result.exec = replaceJobExec(c.Job, newDefinition, async)
+		result.summary = "Replacing job"
+	default:
 		return nil, platform.ErrNoMatching
 	}
 	return &result, nil
 }
 
-func (c *Cluster) connectArgs() []string {
-	var args []string
-	if c.config.Host != "" {
-		args = append(args, fmt.Sprintf("--server=%s", c.config.Host))
+// A rolloutFunc reports how far a pod controller has got in rolling
+// out, and whether it's done, or an error if it never will be.
+type rolloutFunc func(c *Cluster, namespace, name string) (status string, done bool, err error)
+
+// applyingExec applies the new definition then, unless async, watches
+// the rollout until it's done.
+func applyingExec(namespace string, newDef *apiObject, async bool, rollout rolloutFunc) applyExecFunc {
+	return func(c *Cluster, logger log.Logger, progress func(string)) error {
+		res, err := c.resourceFor(newDef.Kind)
+		if err != nil {
+			return err
+		}
+		name := newDef.Metadata.Name
+		begin := time.Now()
+		err = c.applyDefinition(res, namespace, name, newDef.bytes)
+		logApplied(logger, begin, err)
+		if async || err != nil {
+			return err
+		}
+		return waitForRollout(func() (string, bool, error) {
+			return rollout(c, namespace, name)
+		}, rolloutTimeout, progress)
 	}
-	if c.config.Username != "" {
-		args = append(args, fmt.Sprintf("--username=%s", c.config.Username))
+}
+
+func logApplied(logger log.Logger, begin time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = err.Error()
 	}
-	if c.config.Password != "" {
-		args = append(args, fmt.Sprintf("--password=%s", c.config.Password))
+	logger.Log("result", result, "took", time.Since(begin).String())
+}
+
+// waitForRollout polls until the rollout is done, fails, or the
+// timeout is up, passing on each change in its status as progress.
+func waitForRollout(rollout func() (string, bool, error), timeout time.Duration, progress func(string)) error {
+	deadline := time.After(timeout)
+	var last string
+	for {
+		status, done, err := rollout()
+		if err != nil || done {
+			return err
+		}
+		if status != last {
+			progress(status)
+			last = status
+		}
+		select {
+		case <-deadline:
+			return errors.Errorf("timed out after %s waiting for rollout to complete (%s)", timeout, status)
+		case <-time.After(rolloutPollInterval):
+		}
+	}
+}
+
+func deploymentRollout(c *Cluster, namespace, name string) (string, bool, error) {
+	d, err := c.client.Deployments(namespace).Get(name)
+	if err != nil {
+		return "", false, errors.Wrap(err, "getting deployment status")
+	}
+	return deploymentStatus(d)
+}
+
+// A deployment has rolled out when it's seen the latest change to its
+// spec, all the replicas wanted are of the new spec and available,
+// and no old replicas are left.
+func deploymentStatus(d *apiext.Deployment) (string, bool, error) {
+	if d.Status.ObservedGeneration < d.Generation {
+		return "waiting for the deployment to be updated", false, nil
 	}
-	if c.config.TLSClientConfig.CertFile != "" {
-		args = append(args, fmt.Sprintf("--client-certificate=%s", c.config.TLSClientConfig.CertFile))
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
 	}
-	if c.config.TLSClientConfig.CAFile != "" {
-		args = append(args, fmt.Sprintf("--certificate-authority=%s", c.config.TLSClientConfig.CAFile))
+	s := d.Status
+	status := fmt.Sprintf("%d of %d replicas updated, %d available", s.UpdatedReplicas, desired, s.AvailableReplicas)
+	done := s.UpdatedReplicas >= desired &&
+		s.Replicas <= s.UpdatedReplicas &&
+		s.AvailableReplicas >= s.UpdatedReplicas
+	return status, done, nil
+}
+
+// Daemon sets don't report which generation they've seen, nor (before
+// Kubernetes 1.6) do they replace their pods when their pod template
+// changes -- only as the pods are deleted. So a daemon set has rolled
+// out when there's a pod scheduled on every node that should have one,
+// and every pod is running the images in the template.
+func daemonSetRollout(c *Cluster, namespace, name string) (string, bool, error) {
+	ds, err := c.client.DaemonSets(namespace).Get(name)
+	if err != nil {
+		return "", false, errors.Wrap(err, "getting daemon set status")
 	}
-	if c.config.TLSClientConfig.KeyFile != "" {
-		args = append(args, fmt.Sprintf("--client-key=%s", c.config.TLSClientConfig.KeyFile))
+	s := ds.Status
+	status := fmt.Sprintf("%d of %d nodes scheduled", s.CurrentNumberScheduled, s.DesiredNumberScheduled)
+	if s.NumberMisscheduled > 0 || s.CurrentNumberScheduled != s.DesiredNumberScheduled {
+		return status, false, nil
 	}
-	if c.config.BearerToken != "" {
-		args = append(args, fmt.Sprintf("--token=%s", c.config.BearerToken))
+
+	var selector unversioned.LabelSelector
+	if ds.Spec.Selector != nil {
+		if err := apiext.Convert_v1beta1_LabelSelector_To_unversioned_LabelSelector(ds.Spec.Selector, &selector, nil); err != nil {
+			return "", false, errors.Wrap(err, "reading daemon set selector")
+		}
+	} else {
+		selector.MatchLabels = ds.Spec.Template.Labels
 	}
-	return args
+	podSelector, err := unversioned.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		return "", false, errors.Wrap(err, "reading daemon set selector")
+	}
+	pods, err := c.client.Pods(namespace).List(api.ListOptions{LabelSelector: podSelector})
+	if err != nil {
+		return "", false, errors.Wrap(err, "getting daemon set pods")
+	}
+	updated := 0
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil && runsTemplate(pod.Spec, ds.Spec.Template.Spec) {
+			updated++
+		}
+	}
+	status = fmt.Sprintf("%s, %d of %d pods updated (a daemon set's pods may only be replaced as they are deleted)", status, updated, len(pods.Items))
+	return status, updated == len(pods.Items) && int32(updated) >= s.DesiredNumberScheduled, nil
 }
 
-func (c *Cluster) kubectlCommand(args ...string) *exec.Cmd {
-	cmd := exec.Command(c.kubectl, append(c.connectArgs(), args...)...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd
+// runsTemplate says whether the pod given is running the containers
+// of the template given, as far as their images go.
+func runsTemplate(pod, template v1.PodSpec) bool {
+	images := map[string]string{}
+	for _, container := range pod.Containers {
+		images[container.Name] = container.Image
+	}
+	for _, container := range template.Containers {
+		if images[container.Name] != container.Image {
+			return false
+		}
+	}
+	return true
 }
 
-func (c *Cluster) doApplyCommand(logger log.Logger, newDefinition *apiObject, args ...string) error {
-	cmd := c.kubectlCommand(args...)
-	cmd.Stdin = bytes.NewReader(newDefinition.bytes)
-	stderr := &bytes.Buffer{}
-	cmd.Stderr = stderr
-	logger.Log("cmd", strings.Join(args, " "))
+func statefulSetRollout(c *Cluster, namespace, name string) (string, bool, error) {
+	ss, err := decodeStatefulSet(c.client.statefulSets.Get(namespace, name))
+	if err != nil {
+		return "", false, errors.Wrap(err, "getting stateful set status")
+	}
+	status := fmt.Sprintf("%d of %d replicas", ss.Status.Replicas, ss.desiredReplicas())
+	observed := ss.Status.ObservedGeneration
+	return status, observed != nil && *observed >= ss.Generation &&
+		ss.Status.Replicas == ss.desiredReplicas(), nil
+}
 
-	begin := time.Now()
-	err := cmd.Run()
-	result := "success"
+// A job has rolled out when it has run to completion.
+func jobRollout(c *Cluster, namespace, name string) (string, bool, error) {
+	job, err := c.client.batch.Jobs(namespace).Get(name)
 	if err != nil {
-		result = stderr.String()
-		err = errors.Wrap(errors.New(result), "running kubectl")
+		return "", false, errors.Wrap(err, "getting job status")
 	}
-	logger.Log("result", result, "took", time.Since(begin).String())
-	return err
+	status := fmt.Sprintf("%d active, %d succeeded", job.Status.Active, job.Status.Succeeded)
+	done, err := jobFinished(job)
+	return status, done, err
 }
 
-func rollingUpgradeExec(def *api.ReplicationController, newDef *apiObject, async bool) applyExecFunc {
-	return func(c *Cluster, logger log.Logger) error {
-		errc := make(chan error)
-		go func() {
-			errc <- c.doApplyCommand(
-				logger,
-				newDef,
-				"rolling-update",
-				"--update-period", "3s",
-				"--namespace", def.Namespace,
-				def.Name,
-				"-f", "-", // take definition from stdin
-			)
-		}()
-		if async {
-			return nil
-		}
-		return <-errc
-	}
-}
-
-func deploymentExec(def *apiext.Deployment, newDef *apiObject, async bool) applyExecFunc {
-	return func(c *Cluster, logger log.Logger) error {
-		err := c.doApplyCommand(
-			logger,
-			newDef,
-			"apply",
-			"-f", "-", // take definition from stdin
-		)
-		if async {
-			return err
+func jobFinished(job *apibatch.Job) (bool, error) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != v1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case apibatch.JobComplete:
+			return true, nil
+		case apibatch.JobFailed:
+			return false, errors.Errorf("job failed: %s", cond.Message)
 		}
+	}
+	return false, nil
+}
 
+// replaceJobExec deletes the job, waits for it to be gone, then
+// creates it from the new definition.
+func replaceJobExec(def *apibatch.Job, newDef *apiObject, async bool) applyExecFunc {
+	return func(c *Cluster, logger log.Logger, progress func(string)) error {
+		jobs := c.client.batch.Jobs(def.Namespace)
+		begin := time.Now()
+		// Have the garbage collector take the job's pods with it
+		orphan := false
+		err := jobs.Delete(def.Name, &api.DeleteOptions{OrphanDependents: &orphan})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			logApplied(logger, begin, err)
+			return errors.Wrap(err, "deleting job")
+		}
+		err = waitForRollout(func() (string, bool, error) {
+			_, err := jobs.Get(def.Name)
+			if k8serrors.IsNotFound(err) {
+				return "", true, nil
+			}
+			return "waiting for the old job to be deleted", false, err
+		}, rolloutTimeout, progress)
 		if err == nil {
-			args := []string{
-				"rollout", "status",
-				"deployment", newDef.Metadata.Name,
-				"--namespace", newDef.Metadata.Namespace,
+			var res *resource
+			if res, err = c.resourceFor(newDef.Kind); err == nil {
+				err = c.applyDefinition(res, def.Namespace, newDef.Metadata.Name, newDef.bytes)
 			}
-			cmd := c.kubectlCommand(args...)
-			logger.Log("cmd", strings.Join(args, " "))
-			err = cmd.Run()
 		}
+		logApplied(logger, begin, err)
+		if async || err != nil {
+			return err
+		}
+		return waitForRollout(func() (string, bool, error) {
+			return jobRollout(c, def.Namespace, newDef.Metadata.Name)
+		}, rolloutTimeout, progress)
+	}
+}
+
+func rollingUpdateExec(def *v1.ReplicationController, newDef *apiObject) applyExecFunc {
+	return func(c *Cluster, logger log.Logger, progress func(string)) error {
+		begin := time.Now()
+		err := c.rollingUpdate(def, newDef.bytes, progress)
+		logApplied(logger, begin, err)
+		return err
+	}
+}
+
+// The label that `kubectl rolling-update` adds to the selector and
+// pod template of a replication controller, when the new definition
+// has the same selector as the old, so that the old and new
+// replication controllers select different pods. We do the same.
+const rollingUpdateLabel = "deployment"
+
+// rollingUpdate replaces the replication controller given with the
+// one defined, a pod at a time, as `kubectl rolling-update` does: the
+// new replication controller is scaled up by one and, once the new
+// pod is ready, the old one is scaled down by one. If the new
+// definition has the same name as the old, the new replication
+// controller is given a temporary name, and renamed at the end.
+func (c *Cluster) rollingUpdate(old *v1.ReplicationController, def []byte, progress func(string)) error {
+	var next v1.ReplicationController
+	if err := decodeDefinition(def, &next); err != nil {
+		return errors.Wrap(err, "reading definition")
+	}
+	// The selector defaults to the labels of the pod template.
+	selector := next.Spec.Selector
+	if len(selector) == 0 && next.Spec.Template != nil {
+		selector = next.Spec.Template.Labels
+	}
+	if reflect.DeepEqual(selector, old.Spec.Selector) {
+		oldHash, err := hashObject(old)
+		if err != nil {
+			return err
+		}
+		newHash, err := hashObject(&next)
+		if err != nil {
+			return err
+		}
+		if oldHash == newHash {
+			return errors.New("the new definition of the replication controller is the same as the old")
+		}
+		progress("labelling the old pods, to tell them apart from the new")
+		if err := c.labelReplicationController(old, rollingUpdateLabel, oldHash); err != nil {
+			return err
+		}
+		next.Spec.Selector = withLabel(selector, rollingUpdateLabel, newHash)
+		if next.Spec.Template != nil {
+			next.Spec.Template.Labels = withLabel(next.Spec.Template.Labels, rollingUpdateLabel, newHash)
+		}
+	}
+
+	desired := replicasOf(&next)
+	name := next.Name
+	rename := name == old.Name
+	if rename {
+		next.Name = name + "-next"
+	}
+	next.Namespace = old.Namespace
+	zero := int32(0)
+	next.Spec.Replicas = &zero
+
+	rcs := c.client.ReplicationControllers(old.Namespace)
+	if _, err := rcs.Create(&next); err != nil {
+		return errors.Wrap(err, "creating replication controller")
+	}
+
+	for newReplicas, oldReplicas := int32(0), replicasOf(old); newReplicas < desired || oldReplicas > 0; {
+		if newReplicas < desired {
+			newReplicas++
+			if err := scaleReplicationController(rcs, next.Name, newReplicas); err != nil {
+				return err
+			}
+			wanted := newReplicas
+			if err := waitForRollout(func() (string, bool, error) {
+				rc, err := rcs.Get(next.Name)
+				if err != nil {
+					return "", false, errors.Wrap(err, "getting replication controller status")
+				}
+				status := fmt.Sprintf("%d of %d new replicas ready", rc.Status.ReadyReplicas, desired)
+				return status, rc.Status.ReadyReplicas >= wanted, nil
+			}, rolloutTimeout, progress); err != nil {
+				return err
+			}
+		}
+		if oldReplicas > 0 {
+			oldReplicas--
+			if err := scaleReplicationController(rcs, old.Name, oldReplicas); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rcs.Delete(old.Name, nil); err != nil {
+		return errors.Wrap(err, "deleting old replication controller")
+	}
+	if !rename {
+		return nil
+	}
+
+	// Replace the temporarily-named replication controller with one
+	// of the right name, which will adopt its pods.
+	final := next
+	final.ObjectMeta = v1.ObjectMeta{
+		Name:        name,
+		Namespace:   old.Namespace,
+		Labels:      next.Labels,
+		Annotations: next.Annotations,
+	}
+	final.Spec.Replicas = &desired
+	final.Status = v1.ReplicationControllerStatus{}
+	if _, err := rcs.Create(&final); err != nil {
+		return errors.Wrap(err, "renaming replication controller")
+	}
+	orphan := true
+	return errors.Wrap(rcs.Delete(next.Name, &api.DeleteOptions{OrphanDependents: &orphan}), "renaming replication controller")
+}
+
+// labelReplicationController adds the label given to the pod template
+// and the selector of the replication controller, and to the pods it
+// has already, so that it selects only its own pods, as `kubectl
+// rolling-update` does.
+func (c *Cluster) labelReplicationController(rc *v1.ReplicationController, key, value string) error {
+	rcs := c.client.ReplicationControllers(rc.Namespace)
+	pods := c.client.Pods(rc.Namespace)
+	original := labels.SelectorFromSet(labels.Set(rc.Spec.Selector))
+
+	// New pods get the label, then the pods there are already ...
+	if err := updateReplicationController(rcs, rc.Name, func(rc *v1.ReplicationController) {
+		if rc.Spec.Template != nil {
+			rc.Spec.Template.Labels = withLabel(rc.Spec.Template.Labels, key, value)
+		}
+	}); err != nil {
+		return errors.Wrapf(err, "labelling pod template of replication controller %s", rc.Name)
+	}
+	list, err := pods.List(api.ListOptions{LabelSelector: original})
+	if err != nil {
+		return errors.Wrap(err, "getting replication controller pods")
+	}
+	for _, pod := range list.Items {
+		if pod.Labels[key] == value {
+			continue
+		}
+		pod.Labels = withLabel(pod.Labels, key, value)
+		if _, err := pods.Update(&pod); err != nil {
+			return errors.Wrapf(err, "labelling pod %s", pod.Name)
+		}
+	}
+
+	// ... so it's safe to select only those with the label.
+	if err := updateReplicationController(rcs, rc.Name, func(rc *v1.ReplicationController) {
+		rc.Spec.Selector = withLabel(rc.Spec.Selector, key, value)
+	}); err != nil {
+		return errors.Wrapf(err, "labelling selector of replication controller %s", rc.Name)
+	}
+
+	// A pod created between listing the pods and changing the pod
+	// template doesn't have the label, and would be left behind;
+	// delete any such, and the replication controller will replace
+	// them.
+	if list, err = pods.List(api.ListOptions{LabelSelector: original}); err != nil {
+		return errors.Wrap(err, "getting replication controller pods")
+	}
+	for _, pod := range list.Items {
+		if pod.Labels[key] != value {
+			if err := pods.Delete(pod.Name, nil); err != nil && !k8serrors.IsNotFound(err) {
+				return errors.Wrapf(err, "deleting unlabelled pod %s", pod.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// scaleReplicationController sets the number of replicas.
+func scaleReplicationController(rcs v1core.ReplicationControllerInterface, name string, replicas int32) error {
+	return errors.Wrapf(updateReplicationController(rcs, name, func(rc *v1.ReplicationController) {
+		rc.Spec.Replicas = &replicas
+	}), "scaling replication controller %s to %d", name, replicas)
+}
+
+// updateReplicationController makes a change to the replication
+// controller, trying again if it changes under us (as it will, when
+// its status is updated).
+func updateReplicationController(rcs v1core.ReplicationControllerInterface, name string, change func(*v1.ReplicationController)) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var rc *v1.ReplicationController
+		if rc, err = rcs.Get(name); err != nil {
+			break
+		}
+		change(rc)
+		if _, err = rcs.Update(rc); !k8serrors.IsConflict(err) {
+			break
+		}
+	}
+	return err
+}
+
+// hashObject gives a short hash of the object, to label things
+// belonging to it with.
+func hashObject(obj interface{}) (string, error) {
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return "", errors.Wrap(err, "hashing object")
+	}
+	hash := fnv.New32a()
+	hash.Write(bytes)
+	return fmt.Sprintf("%x", hash.Sum32()), nil
+}
+
+// withLabel gives a copy of the labels with the one given added.
+func withLabel(ls map[string]string, key, value string) map[string]string {
+	res := map[string]string{key: value}
+	for k, v := range ls {
+		if k != key {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// The number of replicas defaults to one.
+func replicasOf(rc *v1.ReplicationController) int32 {
+	if rc.Spec.Replicas == nil {
+		return 1
+	}
+	return *rc.Spec.Replicas
+}
+
+func decodeDefinition(def []byte, into interface{}) error {
+	j, err := k8syaml.ToJSON(def)
+	if err != nil {
 		return err
 	}
+	return json.Unmarshal(j, into)
 }
@@ -0,0 +1,266 @@
+package kubernetes
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	apibatch "k8s.io/client-go/1.5/pkg/apis/batch/v1"
+	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	k8stesting "k8s.io/client-go/1.5/testing"
+)
+
+func TestWaitForRollout(t *testing.T) {
+	defer func(interval time.Duration) { rolloutPollInterval = interval }(rolloutPollInterval)
+	rolloutPollInterval = time.Millisecond
+
+	polls := 0
+	var progress []string
+	err := waitForRollout(func() (string, bool, error) {
+		polls++
+		if polls < 3 {
+			return "not yet", false, nil
+		}
+		return "", polls == 4, nil
+	}, time.Second, func(status string) { progress = append(progress, status) })
+	if err != nil {
+		t.Errorf("expected rollout to complete, got %s", err)
+	}
+	if polls != 4 {
+		t.Errorf("expected to poll until ready, polled %d times", polls)
+	}
+	if expected := []string{"not yet", ""}; !reflect.DeepEqual(progress, expected) {
+		t.Errorf("expected each change of status to be reported, got %q", progress)
+	}
+
+	noProgress := func(string) {}
+	failed := errors.New("failed")
+	if err = waitForRollout(func() (string, bool, error) { return "", false, failed }, time.Second, noProgress); err != failed {
+		t.Errorf("expected rollout error to be returned, got %v", err)
+	}
+
+	if err = waitForRollout(func() (string, bool, error) { return "", false, nil }, 10*time.Millisecond, noProgress); err == nil {
+		t.Error("expected rollout to time out")
+	}
+}
+
+func TestDeploymentStatus(t *testing.T) {
+	d := helloworld(2, "weaveworks/helloworld:2")
+	d.Generation = 2
+	for _, c := range []struct {
+		observed                     int64
+		replicas, updated, available int32
+		status                       string
+		done                         bool
+	}{
+		{1, 2, 0, 2, "waiting for the deployment to be updated", false},
+		{2, 3, 1, 2, "1 of 2 replicas updated, 2 available", false},
+		{2, 3, 2, 2, "2 of 2 replicas updated, 2 available", false},
+		{2, 2, 2, 1, "2 of 2 replicas updated, 1 available", false},
+		{2, 2, 2, 2, "2 of 2 replicas updated, 2 available", true},
+	} {
+		d.Status = apiext.DeploymentStatus{
+			ObservedGeneration: c.observed,
+			Replicas:           c.replicas,
+			UpdatedReplicas:    c.updated,
+			AvailableReplicas:  c.available,
+		}
+		status, done, err := deploymentStatus(d)
+		if err != nil || status != c.status || done != c.done {
+			t.Errorf("%+v: got %q, %v, %v", c, status, done, err)
+		}
+	}
+}
+
+func TestApplyingExecWatchesRollout(t *testing.T) {
+	defer func(interval time.Duration) { rolloutPollInterval = interval }(rolloutPollInterval)
+	rolloutPollInterval = time.Millisecond
+
+	current := helloworld(2, "weaveworks/helloworld:1")
+	client := fake.NewSimpleClientset(current)
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, current, nil
+	})
+	// Each time the deployment is looked at, one more replica has
+	// been updated.
+	var updated int32
+	client.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		d := *current
+		d.Status = apiext.DeploymentStatus{
+			Replicas:          2,
+			UpdatedReplicas:   updated,
+			AvailableReplicas: updated,
+		}
+		if updated < 2 {
+			updated++
+		}
+		return true, &d, nil
+	})
+	c := testCluster(client)
+	defer c.Stop()
+
+	def, err := definitionObj([]byte(helloworldDef))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress []string
+	exec := applyingExec("default", def, false, deploymentRollout)
+	if err := exec(c, log.NewNopLogger(), func(status string) { progress = append(progress, status) }); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"1 of 2 replicas updated, 1 available"}
+	if !reflect.DeepEqual(progress, expected) {
+		t.Errorf("expected progress %q, got %q", expected, progress)
+	}
+}
+
+func TestJobFinished(t *testing.T) {
+	job := &apibatch.Job{}
+	if done, err := jobFinished(job); done || err != nil {
+		t.Errorf("expected running job to be neither done nor failed, got %v, %v", done, err)
+	}
+
+	job.Status.Conditions = []apibatch.JobCondition{
+		{Type: apibatch.JobComplete, Status: v1.ConditionTrue},
+	}
+	if done, err := jobFinished(job); !done || err != nil {
+		t.Errorf("expected complete job to be done, got %v, %v", done, err)
+	}
+
+	job.Status.Conditions = []apibatch.JobCondition{
+		{Type: apibatch.JobFailed, Status: v1.ConditionTrue, Message: "deadline exceeded"},
+	}
+	if _, err := jobFinished(job); err == nil {
+		t.Error("expected failed job to return an error")
+	}
+}
+
+func TestDaemonSetRollout(t *testing.T) {
+	ds := &apiext.DaemonSet{
+		ObjectMeta: meta("weave-net"),
+		Spec:       apiext.DaemonSetSpec{Template: template("weave-net", "weaveworks/weave:2")},
+		Status: apiext.DaemonSetStatus{
+			CurrentNumberScheduled: 2,
+			DesiredNumberScheduled: 2,
+		},
+	}
+	pod := func(name, image string) *v1.Pod {
+		t := template("weave-net", image)
+		return &v1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", Labels: t.Labels},
+			Spec:       t.Spec,
+		}
+	}
+
+	// One of the pods is still running the old image, as it will
+	// until it's deleted
+	client := fake.NewSimpleClientset(ds, pod("weave-net-a", "weaveworks/weave:2"), pod("weave-net-b", "weaveworks/weave:1"))
+	c := testCluster(client)
+	defer c.Stop()
+	status, done, err := daemonSetRollout(c, "default", "weave-net")
+	if err != nil || done {
+		t.Errorf("expected daemon set with an old pod not to be rolled out, got %q, %v, %v", status, done, err)
+	}
+
+	client = fake.NewSimpleClientset(ds, pod("weave-net-a", "weaveworks/weave:2"), pod("weave-net-b", "weaveworks/weave:2"))
+	c = testCluster(client)
+	defer c.Stop()
+	if status, done, err = daemonSetRollout(c, "default", "weave-net"); err != nil || !done {
+		t.Errorf("expected daemon set to be rolled out, got %q, %v, %v", status, done, err)
+	}
+}
+
+const helloworldRCDef = `apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: helloworld
+  namespace: default
+spec:
+  replicas: 1
+  selector:
+    app: helloworld
+  template:
+    metadata:
+      labels:
+        app: helloworld
+    spec:
+      containers:
+      - name: helloworld
+        image: weaveworks/helloworld:2
+`
+
+func TestRollingUpdateSameSelector(t *testing.T) {
+	defer func(interval time.Duration) { rolloutPollInterval = interval }(rolloutPollInterval)
+	rolloutPollInterval = time.Millisecond
+
+	one := int32(1)
+	tmpl := template("helloworld", "weaveworks/helloworld:1")
+	old := &v1.ReplicationController{
+		ObjectMeta: meta("helloworld"),
+		Spec: v1.ReplicationControllerSpec{
+			Replicas: &one,
+			Selector: map[string]string{"app": "helloworld"},
+			Template: &tmpl,
+		},
+	}
+	oldPod := &v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "helloworld-a", Namespace: "default", Labels: tmpl.Labels},
+		Spec:       tmpl.Spec,
+	}
+	client := fake.NewSimpleClientset(old, oldPod)
+	// The replicas of replication controllers are ready as soon as
+	// they're asked for.
+	ready := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		var rc *v1.ReplicationController
+		switch a := action.(type) {
+		case k8stesting.CreateAction:
+			rc = a.GetObject().(*v1.ReplicationController)
+		case k8stesting.UpdateAction:
+			rc = a.GetObject().(*v1.ReplicationController)
+		}
+		rc.Status.ReadyReplicas = replicasOf(rc)
+		return false, nil, nil
+	}
+	client.PrependReactor("create", "replicationcontrollers", ready)
+	client.PrependReactor("update", "replicationcontrollers", ready)
+	c := testCluster(client)
+	defer c.Stop()
+
+	if err := c.rollingUpdate(old, []byte(helloworldRCDef), func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The old pod is labelled so the old replication controller no
+	// longer selects the new pods
+	pod, err := client.Core().Pods("default").Get("helloworld-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldLabel := pod.Labels[rollingUpdateLabel]
+	if oldLabel == "" {
+		t.Errorf("expected the old pod to be labelled, got %v", pod.Labels)
+	}
+
+	rc, err := client.Core().ReplicationControllers("default").Get("helloworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newLabel := rc.Spec.Selector[rollingUpdateLabel]
+	if newLabel == "" || newLabel == oldLabel || rc.Spec.Selector["app"] != "helloworld" {
+		t.Errorf("expected a selector telling the new pods apart from the old, got %v", rc.Spec.Selector)
+	}
+	if rc.Spec.Template.Labels[rollingUpdateLabel] != newLabel {
+		t.Errorf("expected the pod template to have the selector's labels, got %v", rc.Spec.Template.Labels)
+	}
+	if rc.Spec.Template.Spec.Containers[0].Image != "weaveworks/helloworld:2" || replicasOf(rc) != 1 {
+		t.Errorf("expected the new definition, got %#v", rc.Spec)
+	}
+	if _, err := client.Core().ReplicationControllers("default").Get("helloworld-next"); err == nil {
+		t.Error("expected the temporary replication controller to be gone")
+	}
+}
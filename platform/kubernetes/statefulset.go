@@ -0,0 +1,112 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	api "k8s.io/client-go/1.5/pkg/api"
+	k8serrors "k8s.io/client-go/1.5/pkg/api/errors"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	rest "k8s.io/client-go/1.5/rest"
+)
+
+// The client library we use predates StatefulSets (it knows only
+// their predecessor, PetSets), so we ask for them by path, and decode
+// just the fields we need.
+
+const statefulSetsPath = "/apis/apps/v1beta1"
+
+type statefulSet struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec          statefulSetSpec   `json:"spec,omitempty"`
+	Status        statefulSetStatus `json:"status,omitempty"`
+}
+
+type statefulSetSpec struct {
+	Replicas *int32             `json:"replicas,omitempty"`
+	Template v1.PodTemplateSpec `json:"template"`
+}
+
+type statefulSetStatus struct {
+	ObservedGeneration *int64 `json:"observedGeneration,omitempty"`
+	Replicas           int32  `json:"replicas"`
+}
+
+type statefulSetList struct {
+	Items []statefulSet `json:"items"`
+}
+
+// The number of replicas defaults to one, as it does for the other
+// controllers.
+func (s *statefulSet) desiredReplicas() int32 {
+	if s.Spec.Replicas == nil {
+		return 1
+	}
+	return *s.Spec.Replicas
+}
+
+func decodeStatefulSet(body []byte, err error) (*statefulSet, error) {
+	if err != nil {
+		return nil, err
+	}
+	var set statefulSet
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, errors.Wrap(err, "decoding stateful set")
+	}
+	return &set, nil
+}
+
+// statefulSetsInterface is what we need of the stateful sets API.
+// Single stateful sets are passed as JSON, so that they can be applied
+// without losing the fields we don't decode.
+type statefulSetsInterface interface {
+	// List returns the stateful sets in the namespace given. A
+	// cluster that doesn't have stateful sets simply has none.
+	List(namespace string) ([]statefulSet, error)
+	Get(namespace, name string) ([]byte, error)
+	Create(namespace string, def []byte) error
+	Patch(namespace, name string, patch []byte) error
+}
+
+type restStatefulSets struct {
+	client *rest.RESTClient
+}
+
+func (s restStatefulSets) List(namespace string) ([]statefulSet, error) {
+	body, err := s.client.Get().
+		AbsPath(statefulSetsPath, "namespaces", namespace, "statefulsets").
+		DoRaw()
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list statefulSetList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, errors.Wrap(err, "decoding stateful sets")
+	}
+	return list.Items, nil
+}
+
+func (s restStatefulSets) Get(namespace, name string) ([]byte, error) {
+	return s.client.Get().
+		AbsPath(statefulSetsPath, "namespaces", namespace, "statefulsets", name).
+		DoRaw()
+}
+
+func (s restStatefulSets) Create(namespace string, def []byte) error {
+	_, err := s.client.Post().
+		AbsPath(statefulSetsPath, "namespaces", namespace, "statefulsets").
+		Body(def).
+		DoRaw()
+	return err
+}
+
+func (s restStatefulSets) Patch(namespace, name string, patch []byte) error {
+	_, err := s.client.Patch(api.StrategicMergePatchType).
+		AbsPath(statefulSetsPath, "namespaces", namespace, "statefulsets", name).
+		Body(patch).
+		DoRaw()
+	return err
+}
@@ -47,7 +47,8 @@ func WriteTestFiles(dir string) error {
 // given in the test data.
 func ServiceMap(dir string) map[flux.ServiceID][]string {
 	return map[flux.ServiceID][]string{
-		flux.ServiceID("default/helloworld"): []string{filepath.Join(dir, "helloworld-deploy.yaml")},
+		flux.ServiceID("default/helloworld"):                 []string{filepath.Join(dir, "helloworld-deploy.yaml")},
+		flux.ServiceID("monitoring:daemonset/node-exporter"): []string{filepath.Join(dir, "node-exporter-ds.yaml")},
 	}
 }
 
@@ -77,6 +78,23 @@ spec:
         - -addr=:8080
         ports:
         - containerPort: 8080
+`,
+	"node-exporter-ds.yaml": `apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: node-exporter
+  namespace: monitoring
+spec:
+  template:
+    metadata:
+      labels:
+        name: node-exporter
+    spec:
+      containers:
+      - name: node-exporter
+        image: prom/node-exporter:v0.13.0
+        ports:
+        - containerPort: 9100
 `,
 	"helloworld-svc.yaml": `apiVersion: v1
 kind: Service
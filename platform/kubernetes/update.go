@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
 
 	"github.com/weaveworks/flux"
 )
@@ -16,145 +20,297 @@ import (
 // returns a new resource definition body where all references to the old image
 // have been replaced with the new one.
 //
-// This function has many additional requirements that are likely in flux. Read
-// the source to learn about them.
+// Only the values that need to change are rewritten; everything else
+// in the definition, including comments and formatting, is left as
+// it was.
 func UpdatePodController(def []byte, newImageID flux.ImageID, trace io.Writer) ([]byte, error) {
 	var buf bytes.Buffer
 	err := tryUpdate(string(def), newImageID, trace, &buf)
 	return buf.Bytes(), err
 }
 
-// Attempt to update an RC or Deployment config. This makes several assumptions
-// that are justified only with the phrase "because that's how we do it",
-// including:
+// Attempt to update the definition of a pod controller (e.g., a
+// Deployment). The definition is parsed as YAML, to find the values
+// to change, then those values are replaced in the original text;
+// this means the structure of the file doesn't matter, but
+// everything around the values is untouched.
 //
-//  * the file is a replication controller or deployment
-//  * the update is from one tag of an image to another tag of the
-//    same image; e.g., "weaveworks/helloworld:a00001" to
-//    "weaveworks/helloworld:a00002"
-//  * the container spec to update is the (first) one that uses the
-//    same image name (e.g., weaveworks/helloworld)
-//  * the name of the controller is updated to reflect the new tag
-//  * there's a label which must be updated in both the pod spec and the selector
-//  * the file uses canonical YAML syntax, that is, one line per item
-//  * ... other assumptions as encoded in the regular expressions used
+// The values changed are:
 //
-// Here's an example of the assumed structure:
+//  * the image of every container (or init container) using the
+//    same image name (e.g., quay.io/weaveworks/helloworld) as the new
+//    image
+//  * a `version` label, in the pod template (and the selector, for
+//    a ReplicationController), if it has the old image tag as its
+//    value
+//  * the name of a ReplicationController, if it ends with the old
+//    image tag
 //
-// ```
-// apiVersion: v1
-// kind: ReplicationController # not presently checked
-// metadata:                         # )
-//   ...                             # ) any number of equally-indented lines
-//   name: helloworld-master-a000001 # ) can precede the name
-// spec:
-//   replicas: 2
-//   selector:                 # )
-//     name: helloworld        # ) this use of labels is assumed
-//     version: master-a000001 # )
-//   template:
-//     metadata:
-//       labels:                   # )
-//         name: helloworld        # ) this structure is assumed, as for the selector
-//         version: master-a000001 # )
-//     spec:
-//       containers:
-//       # extra container specs are allowed here ...
-//       - name: helloworld                                    # )
-//         image: quay.io/weaveworks/helloworld:master-a000001 # ) these must be together
-//         args:
-//         - -msg=Ahoy
-//         ports:
-//         - containerPort: 80
-// ```
+// The last two are the convention for ReplicationControllers, which
+// are replaced by another with a new name and labels when their image
+// is updated.
 func tryUpdate(def string, newImage flux.ImageID, trace io.Writer, out io.Writer) error {
-	nameRE := multilineRE(
-		`metadata:\s*`,
-		`(?:  .*\n)*  name:\s*"?([\w-]+)"?\s*`,
-	)
-	matches := nameRE.FindStringSubmatch(def)
-	if matches == nil || len(matches) < 2 {
-		return fmt.Errorf("Could not find resource name")
-	}
-	oldDefName := matches[1]
-	fmt.Fprintf(trace, "Found resource name %q in fragment:\n\n%s\n\n", oldDefName, matches[0])
-
-	imageRE := multilineRE(
-		`      containers:.*`,
-		`(?:      .*\n)*(?:  ){3,4}- name:\s*"?([\w-]+)"?(?:\s.*)?`,
-		`(?:  ){4,5}image:\s*"?(`+newImage.Repository()+`(:[\w][\w.-]{0,127})?)"?(\s.*)?`,
-	)
-	// tag part of regexp from
-	// https://github.com/docker/distribution/blob/master/reference/regexp.go#L36
-
-	matches = imageRE.FindStringSubmatch(def)
-	if matches == nil || len(matches) < 3 {
+	decoder := yaml.NewDecoder(strings.NewReader(def))
+	var edits []edit
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Could not parse definition: %s", err)
+		}
+		docEdits, err := updateDocument(&doc, newImage, trace)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, docEdits...)
+	}
+	if len(edits) == 0 {
 		return fmt.Errorf("Could not find image name: %s", newImage.Repository())
 	}
-	containerName := matches[1]
-	oldImage, err := flux.ParseImageID(matches[2])
+
+	updated, err := applyEdits(def, edits)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(trace, "Found container %q using image %v in fragment:\n\n%s\n\n", containerName, oldImage, matches[0])
+	fmt.Fprint(out, updated)
+	return nil
+}
 
-	if oldImage.Repository() != newImage.Repository() {
-		return fmt.Errorf(`expected existing image name and new image name to match, but %q != %q`, oldImage.Repository(), newImage.Repository())
-	}
+// updateDocument finds the edits to make to a single YAML document.
+func updateDocument(doc *yaml.Node, newImage flux.ImageID, trace io.Writer) ([]edit, error) {
+	root := resolve(doc)
+	kind := scalarValue(lookup(root, "kind"))
 
-	// Now to replace bits. Specifically,
-	// * the name, with a re-tagged name
-	// * the image for the container
-	// * the version label (in two places)
-	//
-	// Some values (most likely the version) will be interpreted as a
-	// number if unquoted; while, on the other hand, it is apparently
-	// not OK to quote things that don't look like numbers. So: we
-	// extract values *without* quotes, and add them if necessary.
+	var edits []edit
+	var oldImage *flux.ImageID
+	for _, podSpec := range podSpecs(root, kind) {
+		for _, field := range []string{"initContainers", "containers"} {
+			containers := lookup(podSpec, field)
+			if containers == nil || containers.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, container := range containers.Content {
+				container = resolve(container)
+				imageNode := resolve(lookup(container, "image"))
+				if imageNode == nil || imageNode.Kind != yaml.ScalarNode {
+					continue
+				}
+				image, err := flux.ParseImageID(imageNode.Value)
+				if err != nil || image.Repository() != newImage.Repository() {
+					continue
+				}
+				fmt.Fprintf(trace, "Found container %q using image %v at line %d\n", scalarValue(lookup(container, "name")), image, imageNode.Line)
+				if oldImage == nil {
+					oldImage = &image
+				}
+				edits = append(edits, edit{imageNode, newImage.String()})
+			}
+		}
+	}
+	if oldImage == nil {
+		return nil, nil
+	}
 
-	newDefName := oldDefName
+	// Some values (most likely the version) will be interpreted as
+	// a number if unquoted; while, on the other hand, it is
+	// apparently not OK to quote things that don't look like
+	// numbers. So: we compare values *without* quotes, and add them
+	// if necessary.
 	_, _, oldImageTag := oldImage.Components()
 	_, _, newImageTag := newImage.Components()
-	if strings.HasSuffix(oldDefName, oldImageTag) {
-		newDefName = oldDefName[:len(oldDefName)-len(oldImageTag)] + newImageTag
-	}
-
-	newDefName = maybeQuote(newDefName)
-	newTag := maybeQuote(newImageTag)
-
-	fmt.Fprintln(trace, "")
-	fmt.Fprintln(trace, "Replacing ...")
-	fmt.Fprintf(trace, "Resource name: %s -> %s\n", oldDefName, newDefName)
-	fmt.Fprintf(trace, "Version in templates (and selector if present): %s -> %s\n", oldImageTag, newTag)
-	fmt.Fprintf(trace, "Image in templates: %s -> %s\n", oldImage, newImage)
-	fmt.Fprintln(trace, "")
-
-	// The name we want is that under `metadata:`, which will be indented once
-	replaceRCNameRE := regexp.MustCompile(`(?m:^(  name:\s*) (?:"?[\w-]+"?)(\s.*)$)`)
-	withNewDefName := replaceRCNameRE.ReplaceAllString(def, fmt.Sprintf(`$1 %s$2`, newDefName))
-
-	// Replacing labels: these are in two places, the container template and the selector
-	replaceLabelsRE := multilineRE(
-		`((?:  selector|      labels):.*)`,
-		`((?:  ){2,4}name:.*)`,
-		`((?:  ){2,4}version:\s*) (?:"?[-\w]+"?)(\s.*)`,
-	)
-	replaceLabels := fmt.Sprintf("$1\n$2\n$3 %s$4", newTag)
-	withNewLabels := replaceLabelsRE.ReplaceAllString(withNewDefName, replaceLabels)
-
-	replaceImageRE := multilineRE(
-		`((?:  ){3,4}- name:\s*`+containerName+`)`,
-		`((?:  ){4,5}image:\s*) .*`,
-	)
-	replaceImage := fmt.Sprintf("$1\n$2 %s$3", newImage.String())
-	withNewImage := replaceImageRE.ReplaceAllString(withNewLabels, replaceImage)
-
-	fmt.Fprint(out, withNewImage)
+	if newImageTag == "" || oldImageTag == "" {
+		// Pinned by digest alone; there's no tag to put in the
+		// name or labels, so leave them as they are.
+		return edits, nil
+	}
+
+	legacy := kind == "ReplicationController"
+	if legacy {
+		if nameNode := resolve(lookup(root, "metadata", "name")); nameNode != nil && nameNode.Kind == yaml.ScalarNode && strings.HasSuffix(nameNode.Value, oldImageTag) {
+			newName := nameNode.Value[:len(nameNode.Value)-len(oldImageTag)] + newImageTag
+			fmt.Fprintf(trace, "Resource name: %s -> %s\n", nameNode.Value, newName)
+			edits = append(edits, edit{nameNode, newName})
+		}
+	}
+
+	var labelNodes []*yaml.Node
+	selectorVersion := resolve(lookup(root, "spec", "selector", "matchLabels", "version"))
+	if legacy {
+		selectorVersion = resolve(lookup(root, "spec", "selector", "version"))
+		labelNodes = append(labelNodes, selectorVersion)
+	}
+	// Changing the labels in the template, but not those in an
+	// immutable selector, would leave the pods unselected.
+	if legacy || selectorVersion == nil {
+		labelNodes = append(labelNodes, resolve(lookup(root, "spec", "template", "metadata", "labels", "version")))
+	}
+	for _, label := range labelNodes {
+		if label != nil && label.Kind == yaml.ScalarNode && label.Value == oldImageTag {
+			fmt.Fprintf(trace, "Version label at line %d: %s -> %s\n", label.Line, oldImageTag, newImageTag)
+			edits = append(edits, edit{label, newImageTag})
+		}
+	}
+	return edits, nil
+}
+
+// podSpecs finds the pod specs in a resource definition, according
+// to its kind.
+func podSpecs(root *yaml.Node, kind string) []*yaml.Node {
+	var specs []*yaml.Node
+	for _, path := range [][]string{
+		{"spec", "template", "spec"},
+		{"spec", "jobTemplate", "spec", "template", "spec"},
+	} {
+		if spec := resolve(lookup(root, path...)); spec != nil {
+			specs = append(specs, spec)
+		}
+	}
+	if kind == "Pod" {
+		if spec := resolve(lookup(root, "spec")); spec != nil {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// lookup follows the path of mapping keys given from the node, and
+// returns the node found at the end, or nil if there's no such path.
+func lookup(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		node = resolve(node)
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var found *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				found = node.Content[i+1]
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		node = found
+	}
+	return node
+}
+
+// resolve looks through documents and aliases to the node of
+// interest.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch {
+		case node.Kind == yaml.DocumentNode && len(node.Content) > 0:
+			node = node.Content[0]
+		case node.Kind == yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
 	return nil
 }
 
-func multilineRE(lines ...string) *regexp.Regexp {
-	return regexp.MustCompile(`(?m:^` + strings.Join(lines, "\n") + `$)`)
+func scalarValue(node *yaml.Node) string {
+	node = resolve(node)
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// An edit is the replacement of a scalar value in the text of a
+// definition.
+type edit struct {
+	node  *yaml.Node
+	value string
+}
+
+// applyEdits makes the edits to the text given, keeping the quoting
+// style of each value replaced.
+func applyEdits(def string, edits []edit) (string, error) {
+	lineStarts := []int{0}
+	for i, c := range def {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	seen := map[int]bool{}
+	var replacements replacements
+	for _, e := range edits {
+		if e.node.Line < 1 || e.node.Line > len(lineStarts) {
+			return "", fmt.Errorf("value %q is outside the definition", e.node.Value)
+		}
+		// Columns count characters, not bytes
+		start := lineStarts[e.node.Line-1]
+		for col := 1; col < e.node.Column && start < len(def); col++ {
+			_, size := utf8.DecodeRuneInString(def[start:])
+			start += size
+		}
+		if seen[start] {
+			continue // e.g., an anchor used more than once
+		}
+		seen[start] = true
+
+		old, replaced, err := quoteLike(e.node, e.value)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(def[start:], old) {
+			return "", fmt.Errorf("Could not find value %q at line %d, column %d", e.node.Value, e.node.Line, e.node.Column)
+		}
+		replacements = append(replacements, replacement{start, start + len(old), replaced})
+	}
+
+	// Work from the end, so the earlier offsets stay valid
+	sort.Sort(sort.Reverse(replacements))
+	for _, r := range replacements {
+		def = def[:r.start] + r.text + def[r.end:]
+	}
+	return def, nil
+}
+
+type replacement struct {
+	start, end int
+	text       string
+}
+
+type replacements []replacement
+
+func (r replacements) Len() int           { return len(r) }
+func (r replacements) Less(i, j int) bool { return r[i].start < r[j].start }
+func (r replacements) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// quoteLike gives the text of the node's value as it appears in the
+// source, and the new value as it should appear in its place. If the
+// old value was quoted only because it looks like a number, the new
+// value is quoted only if it needs to be.
+func quoteLike(node *yaml.Node, value string) (old, new string, err error) {
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		if strings.ContainsAny(node.Value, `"\`) {
+			return "", "", fmt.Errorf("Could not replace escaped value %q", node.Value)
+		}
+		new = `"` + value + `"`
+		if looksLikeNumber.MatchString(node.Value) {
+			new = maybeQuote(value)
+		}
+		return `"` + node.Value + `"`, new, nil
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		new = `'` + strings.Replace(value, `'`, `''`, -1) + `'`
+		if looksLikeNumber.MatchString(node.Value) {
+			new = maybeQuote(value)
+		}
+		return `'` + strings.Replace(node.Value, `'`, `''`, -1) + `'`, new, nil
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return "", "", fmt.Errorf("Could not replace block value %q", node.Value)
+	}
+	return node.Value, maybeQuote(value), nil
 }
 
 var looksLikeNumber *regexp.Regexp = regexp.MustCompile("^(" + strings.Join([]string{
@@ -35,11 +35,24 @@ func TestUpdates(t *testing.T) {
 		{"name label out of order", case3, case3image, case3out},
 		{"version (tag) with dots", case4, case4image, case4out},
 		{"minimal dockerhub image name", case5, case5image, case5out},
+		{"registry with port, pinned by digest", case6, case6image, case6out},
+		{"flow style, comments and wide indentation", case7, case7image, case7out},
+		{"several containers and init containers using the image", case8, case8image, case8out},
+		{"legacy replication controller", case9, case9image, case9out},
+		{"version label also in deployment selector", case10, case10image, case10out},
 	} {
 		testUpdate(t, c[0], c[1], c[2], c[3])
 	}
 }
 
+func TestUpdateImageNotFound(t *testing.T) {
+	id, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	var trace, out bytes.Buffer
+	if err := tryUpdate(case3, id, &trace, &out); err == nil {
+		t.Errorf("expected an error when no container uses the image, got:\n%s", out.String())
+	}
+}
+
 // Unusual but still valid indentation between containers: and the
 // next line
 const case1 = `---
@@ -368,3 +381,221 @@ spec:
         ports:
         - containerPort: 80
 `
+
+const case6 = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        name: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: localhost:5000/web/nginx:1.10-alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+        ports:
+        - containerPort: 80
+`
+
+const case6image = "localhost:5000/web/nginx:1.11-alpine@sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+const case6out = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        name: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: localhost:5000/web/nginx:1.11-alpine@sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210
+        ports:
+        - containerPort: 80
+`
+
+const case7 = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: {name: helloworld, namespace: default}   # flow style
+spec:
+    replicas: 2
+    template:
+        metadata:
+            labels: {name: helloworld}
+        spec:
+            # the containers
+            containers:
+                -   name: helloworld
+                    # a comment in between
+                    image: "quay.io/weaveworks/helloworld:master-a000001" # pinned
+                    args: [-msg=Ahoy]
+                - {name: sidecar, image: 'quay.io/weaveworks/sidecar:master-a000002'}
+`
+
+const case7image = "quay.io/weaveworks/sidecar:master-a000003"
+
+const case7out = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: {name: helloworld, namespace: default}   # flow style
+spec:
+    replicas: 2
+    template:
+        metadata:
+            labels: {name: helloworld}
+        spec:
+            # the containers
+            containers:
+                -   name: helloworld
+                    # a comment in between
+                    image: "quay.io/weaveworks/helloworld:master-a000001" # pinned
+                    args: [-msg=Ahoy]
+                - {name: sidecar, image: 'quay.io/weaveworks/sidecar:master-a000003'}
+`
+
+const case8 = `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  template:
+    metadata:
+      labels:
+        name: worker
+    spec:
+      initContainers:
+      - name: migrate
+        image: weaveworks/worker:1.0.0
+        args: [migrate]
+      containers:
+      - name: fast
+        image: weaveworks/worker:1.0.0
+      - name: slow
+        image: weaveworks/worker:0.9.0
+      - name: other
+        image: weaveworks/other:1.0.0
+`
+
+const case8image = "weaveworks/worker:1.1.0"
+
+const case8out = `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: worker
+spec:
+  template:
+    metadata:
+      labels:
+        name: worker
+    spec:
+      initContainers:
+      - name: migrate
+        image: weaveworks/worker:1.1.0
+        args: [migrate]
+      containers:
+      - name: fast
+        image: weaveworks/worker:1.1.0
+      - name: slow
+        image: weaveworks/worker:1.1.0
+      - name: other
+        image: weaveworks/other:1.0.0
+`
+
+const case9 = `---
+apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: helloworld-master-a000001
+spec:
+  replicas: 2
+  selector:
+    name: helloworld
+    version: master-a000001
+  template:
+    metadata:
+      labels:
+        name: helloworld
+        version: master-a000001
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000001
+`
+
+const case9image = "quay.io/weaveworks/helloworld:master-a000002"
+
+const case9out = `---
+apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: helloworld-master-a000002
+spec:
+  replicas: 2
+  selector:
+    name: helloworld
+    version: master-a000002
+  template:
+    metadata:
+      labels:
+        name: helloworld
+        version: master-a000002
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000002
+`
+
+// The name and labels are left alone, since this isn't an RC, and the
+// selector can't be changed
+const case10 = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld-master-a000001
+spec:
+  selector:
+    matchLabels:
+      name: helloworld
+      version: master-a000001
+  template:
+    metadata:
+      labels:
+        name: helloworld
+        version: master-a000001
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000001
+`
+
+const case10image = "quay.io/weaveworks/helloworld:master-a000002"
+
+const case10out = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld-master-a000001
+spec:
+  selector:
+    matchLabels:
+      name: helloworld
+      version: master-a000001
+  template:
+    metadata:
+      labels:
+        name: helloworld
+        version: master-a000001
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000002
+`
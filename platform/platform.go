@@ -77,6 +77,29 @@ type Service struct {
 	Status   string            // A status summary for display
 
 	Containers ContainersOrExcuse
+	// Spec is what the service's controller is configured to run, so
+	// it can be compared with its definition. It's nil if the
+	// platform doesn't report it.
+	Spec *ControllerSpec
+}
+
+// A ControllerSpec gives the parts of a pod controller's
+// configuration that can drift from its definition. Replicas is nil
+// for controllers that don't have a number of replicas, e.g., daemon
+// sets.
+type ControllerSpec struct {
+	Replicas   *int32
+	Containers []ContainerSpec
+}
+
+// A ContainerSpec is the configuration of a container in a pod
+// template. Env maps variable names to values, and Resources maps,
+// e.g., "limits.cpu" to a quantity, in canonical form.
+type ContainerSpec struct {
+	Name      string
+	Image     string
+	Env       map[string]string
+	Resources map[string]string
 }
 
 // A Container represents a container specification in a pod. The Name
@@ -114,8 +137,8 @@ var (
 	ErrWrongResourceKind    = errors.New("new definition does not match existing resource")
 	ErrNoMatchingService    = errors.New("no matching service")
 	ErrServiceHasNoSelector = errors.New("service has no selector")
-	ErrNoMatching           = errors.New("no matching replication controllers or deployments")
-	ErrMultipleMatching     = errors.New("multiple matching replication controllers or deployments")
+	ErrNoMatching           = errors.New("no matching pod controllers")
+	ErrMultipleMatching     = errors.New("multiple matching pod controllers")
 	ErrNoMatchingImages     = errors.New("no matching images")
 )
 
@@ -3,7 +3,7 @@ package registry
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/docker/distribution/manifest/schema1"
@@ -34,7 +34,13 @@ func (rc *remote) Tags(repository Repository) (_ []string, err error) {
 }
 
 func (rc *remote) Manifest(repository Repository, tag string) (img flux.Image, err error) {
-	img, err = flux.ParseImage(fmt.Sprintf("%s:%s", repository.String(), tag), nil)
+	// The "tag" may also be a digest, in which case it's
+	// appended differently.
+	sep := ":"
+	if strings.Contains(tag, ":") {
+		sep = "@"
+	}
+	img, err = flux.ParseImage(repository.String()+sep+tag, nil)
 	if err != nil {
 		return
 	}
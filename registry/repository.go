@@ -39,5 +39,6 @@ func (r Repository) String() string {
 func (r Repository) ToImage(tag string) flux.Image {
 	newImage := r.img
 	newImage.Tag = tag
+	newImage.Digest = ""
 	return newImage
 }
@@ -33,6 +33,11 @@ const (
 	ReleaseStatusSkipped ServiceReleaseStatus = "skipped"
 	ReleaseStatusIgnored ServiceReleaseStatus = "ignored"
 	ReleaseStatusUnknown ServiceReleaseStatus = "unknown"
+	// The release failed to apply, and was automatically rolled back
+	ReleaseStatusRolledBack ServiceReleaseStatus = "rolled-back"
+	// The release was proposed as a pull request, and will be
+	// applied once that's merged
+	ReleaseStatusAwaitingMerge ServiceReleaseStatus = "awaiting-merge"
 )
 
 type ServiceReleaseStatus string
@@ -56,6 +61,13 @@ type Release struct {
 
 	Spec   ReleaseSpec   `json:"spec"`
 	Result ReleaseResult `json:"result"`
+
+	// RollbackOf is set if this release undid the image updates of
+	// an earlier release, and identifies that release.
+	RollbackOf ReleaseID `json:"rollbackOf,omitempty"`
+	// PullRequestURL is set if the release was proposed as a pull
+	// request, rather than pushed, and links to the pull request.
+	PullRequestURL string `json:"pullRequestURL,omitempty"`
 }
 
 // NB: these get sent from fluxctl, so we have to maintain the json format of
@@ -65,6 +77,10 @@ type ReleaseSpec struct {
 	ImageSpec    ImageSpec
 	Kind         ReleaseKind
 	Excludes     []ServiceID
+	// Cause says who asked for the release, and why
+	Cause Cause
+	// Force releases services even if they're in a freeze window.
+	Force bool `json:",omitempty"`
 
 	// Backwards Compatibility, remove once no more jobs
 	// TODO: Remove this once there are no more jobs with ServiceSpec, only ServiceSpecs
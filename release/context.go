@@ -1,14 +1,19 @@
 package release
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/integrations/github"
 	"github.com/weaveworks/flux/platform/kubernetes"
 )
 
@@ -38,18 +43,99 @@ func (rc *ReleaseContext) CommitAndPush(msg string) error {
 	return rc.Instance.ConfigRepo().CommitAndPush(rc.WorkingDir, msg)
 }
 
+// HeadRevision gives the commit checked out in the working clone.
+func (rc *ReleaseContext) HeadRevision() (string, error) {
+	return rc.Instance.ConfigRepo().HeadRevision(rc.WorkingDir)
+}
+
+// ResetRepo throws away the changes in the working clone, and brings
+// it up to date with the repo.
+func (rc *ReleaseContext) ResetRepo() error {
+	return rc.Instance.ConfigRepo().ResetToRemote(rc.WorkingDir)
+}
+
 func (rc *ReleaseContext) RepoPath() string {
 	return filepath.Join(rc.WorkingDir, rc.Instance.ConfigRepo().Path)
 }
 
-func (rc *ReleaseContext) PushChanges(updates []*ServiceUpdate, spec *flux.ReleaseSpec) error {
-	err := writeUpdates(updates)
+// PushChanges writes the updates to the clone, then commits and
+// pushes them. In the pull request release mode, they're pushed to a
+// branch of their own instead, and a pull request opened to merge
+// that; its URL is returned, and whether it was open already, having
+// been opened for the same updates before. Otherwise the URL returned
+// is empty.
+func (rc *ReleaseContext) PushChanges(updates []*ServiceUpdate, commitMsg string) (pullRequestURL string, alreadyOpen bool, err error) {
+	if err = writeUpdates(updates); err != nil {
+		return "", false, err
+	}
+
+	config, err := rc.Instance.GetConfig()
 	if err != nil {
-		return err
+		return "", false, errors.Wrap(err, "getting instance config")
 	}
+	switch mode := config.Settings.Git.ReleaseMode; mode {
+	case "", flux.ReleaseModePush:
+		return "", false, rc.CommitAndPush(commitMsg)
+	case flux.ReleaseModePullRequest:
+		return rc.proposeChanges(config.Settings.Github, updates, commitMsg)
+	default:
+		return "", false, fmt.Errorf("unknown release mode %q", mode)
+	}
+}
 
-	commitMsg := commitMessageFromReleaseSpec(spec)
-	return rc.CommitAndPush(commitMsg)
+// proposeChanges pushes the changes in the clone to a branch named
+// for the updates, and opens a pull request to merge it into the
+// branch cloned; unless there's a pull request open for that branch
+// already, in which case it's left as it is.
+func (rc *ReleaseContext) proposeChanges(settings flux.GithubConfig, updates []*ServiceUpdate, commitMsg string) (string, bool, error) {
+	if settings.Token == "" {
+		return "", false, ErrNoGithubToken
+	}
+	repo := rc.Instance.ConfigRepo()
+	owner, name, err := github.ParseRepoURL(repo.URL)
+	if err != nil {
+		return "", false, err
+	}
+	client := github.NewGithubClient(settings.Token)
+	if settings.APIURL != "" {
+		if err := client.SetBaseURL(settings.APIURL); err != nil {
+			return "", false, errors.Wrap(err, "parsing GitHub API URL")
+		}
+	}
+
+	branch := proposalBranch(updates)
+	url, err := client.FindPullRequest(owner, name, branch, repo.Branch)
+	if err != nil {
+		return "", false, errors.Wrap(err, "looking for open pull request")
+	}
+	if url != "" {
+		return url, true, nil
+	}
+	if err := repo.CommitAndPushBranch(rc.WorkingDir, branch, commitMsg); err != nil {
+		return "", false, err
+	}
+	title, body := commitMsg, ""
+	if i := strings.Index(commitMsg, "\n"); i >= 0 {
+		title, body = commitMsg[:i], strings.TrimSpace(commitMsg[i+1:])
+	}
+	url, err = client.CreatePullRequest(owner, name, branch, repo.Branch, title, body)
+	return url, false, errors.Wrap(err, "opening pull request")
+}
+
+// proposalBranch names the branch to propose the updates on. It's
+// named for the images going to each container, so proposing the same
+// updates again (as automation does, every cycle, until the pull
+// request is merged) comes to the same branch.
+func proposalBranch(updates []*ServiceUpdate) string {
+	var targets []string
+	for _, update := range updates {
+		for _, u := range update.Updates {
+			targets = append(targets, fmt.Sprintf("%s %s %s", update.ServiceID, u.Container, u.Target))
+		}
+	}
+	sort.Strings(targets)
+	sum := sha256.Sum256([]byte(strings.Join(targets, "\n")))
+	return fmt.Sprintf("flux-release-%x", sum[:8])
 }
 
 func writeUpdates(updates []*ServiceUpdate) error {
@@ -80,10 +166,11 @@ func (rc *ReleaseContext) Clean() {
 // as the first argument, it will include *only* those services, and
 // treat missing services as *skipped*; otherwise, it will include all
 // services, and *ignore* those that are defined by not
-// running. Services in the locked and excluded sets are omitted (and
-// recorded as so). The return value is a set of potentially
-// updateable services.
-func (rc *ReleaseContext) SelectServices(included []flux.ServiceID, locked flux.ServiceIDSet, excluded flux.ServiceIDSet, results flux.ReleaseResult, logStatus statusFn) ([]*ServiceUpdate, error) {
+// running. Services that are locked or in the excluded set are
+// omitted (and recorded as so, along with the details of the
+// lock). The return value is a set of potentially updateable
+// services.
+func (rc *ReleaseContext) SelectServices(included []flux.ServiceID, locked map[flux.ServiceID]flux.LockInfo, excluded flux.ServiceIDSet, results flux.ReleaseResult, logStatus statusFn) ([]*ServiceUpdate, error) {
 	// Figure out all services that are defined in the repo and should
 	// be selected for upgrading/applying.
 	defined, err := rc.FindDefinedServices()
@@ -113,11 +200,12 @@ func (rc *ReleaseContext) SelectServices(included []flux.ServiceID, locked flux.
 					Status: flux.ReleaseStatusSkipped,
 					Error:  "excluded",
 				}
-			case locked.Contains(s.ServiceID):
-				logStatus("Skipping service %s as it is locked", s.ServiceID)
+			case isLocked(locked, s.ServiceID):
+				lock := locked[s.ServiceID]
+				logStatus("Skipping service %s as it is %s", s.ServiceID, lock.Describe())
 				result = flux.ServiceResult{
 					Status: flux.ReleaseStatusSkipped,
-					Error:  "locked",
+					Error:  lock.Describe(),
 				}
 			default:
 				result = flux.ServiceResult{
@@ -138,8 +226,13 @@ func (rc *ReleaseContext) SelectServices(included []flux.ServiceID, locked flux.
 
 	var updates []*ServiceUpdate
 	for _, service := range services {
+		update, ok := updateMap[service.ID]
+		if !ok {
+			// The platform knows it by another ID; it'll be
+			// reported below as not in the running system.
+			continue
+		}
 		logStatus("Found service %s", service.ID)
-		update := updateMap[service.ID]
 		update.Service = service
 		updates = append(updates, update)
 		delete(updateMap, service.ID)
@@ -177,9 +270,10 @@ func (rc *ReleaseContext) FindDefinedServices() ([]*ServiceUpdate, error) {
 				return nil, err
 			}
 			defined = append(defined, &ServiceUpdate{
-				ServiceID:     id,
-				ManifestPath:  paths[0],
-				ManifestBytes: def,
+				ServiceID:             id,
+				ManifestPath:          paths[0],
+				ManifestBytes:         def,
+				PreviousManifestBytes: def,
 			})
 		default:
 			return nil, fmt.Errorf("multiple resource files found for service %s: %s", id, strings.Join(paths, ", "))
@@ -187,3 +281,8 @@ func (rc *ReleaseContext) FindDefinedServices() ([]*ServiceUpdate, error) {
 	}
 	return defined, nil
 }
+
+func isLocked(locked map[flux.ServiceID]flux.LockInfo, id flux.ServiceID) bool {
+	_, ok := locked[id]
+	return ok
+}
@@ -2,14 +2,17 @@ package release
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/platform/kubernetes"
 	"github.com/weaveworks/flux/platform/kubernetes/testdata"
 )
 
@@ -42,6 +45,111 @@ func TestCloneCommitAndPush(t *testing.T) {
 	}
 }
 
+// pushElsewhere pushes a change to the repo from another clone, as
+// though someone else had pushed. The change is a comment added to the
+// service definition, which isn't otherwise touched.
+func pushElsewhere(t *testing.T, repo git.Repo, comment string) {
+	path, err := repo.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	file := filepath.Join(path, "helloworld-svc.yaml")
+	def, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, append(def, []byte("# "+comment+"\n")...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAndPush(path, "Pushed elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setupPushConflict(t *testing.T) (*ReleaseContext, []*ServiceUpdate, func()) {
+	r, cleanup := setupRepo(t)
+	inst := &instance.Instance{Repo: r, Config: &instance.MockConfigurer{instance.MakeConfig(), nil}}
+	rc := NewReleaseContext(inst)
+	if err := rc.CloneRepo(); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	defined, err := rc.FindDefinedServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []*ServiceUpdate
+	for _, update := range defined {
+		if update.ServiceID != "default/helloworld" {
+			continue
+		}
+		target, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+		update.Updates = []flux.ContainerUpdate{{Container: "helloworld", Target: target}}
+		if update.ManifestBytes, err = kubernetes.UpdatePodController(update.ManifestBytes, target, ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, update)
+	}
+	return rc, updates, func() {
+		rc.Clean()
+		cleanup()
+	}
+}
+
+func TestPushRetriesWhenRejected(t *testing.T) {
+	rc, updates, cleanup := setupPushConflict(t)
+	defer cleanup()
+
+	// Someone pushes between our clone and push
+	pushElsewhere(t, rc.Instance.ConfigRepo(), "hello")
+
+	var log []string
+	logStatus := func(format string, args ...interface{}) {
+		log = append(log, fmt.Sprintf(format, args...))
+	}
+	if _, _, err := pushChanges(rc, updates, "Release", logStatus); err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 || !strings.Contains(log[0], "attempt 1 of 3") {
+		t.Errorf("expected the rejected attempt to be logged, got %q", log)
+	}
+
+	// Both changes end up in the repo
+	path, err := rc.Instance.ConfigRepo().Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	if svc, _ := ioutil.ReadFile(filepath.Join(path, "helloworld-svc.yaml")); !strings.Contains(string(svc), "# hello") {
+		t.Error("expected the change pushed elsewhere to be kept")
+	}
+	def, _ := ioutil.ReadFile(filepath.Join(path, "helloworld-deploy.yaml"))
+	if !strings.Contains(string(def), "helloworld:master-a000002") {
+		t.Errorf("expected the update to be pushed, got\n%s", def)
+	}
+}
+
+func TestPushGivesUpAfterAttempts(t *testing.T) {
+	rc, updates, cleanup := setupPushConflict(t)
+	defer cleanup()
+
+	// Someone pushes before each of our attempts
+	pushElsewhere(t, rc.Instance.ConfigRepo(), "0")
+	var log []string
+	logStatus := func(format string, args ...interface{}) {
+		log = append(log, fmt.Sprintf(format, args...))
+		pushElsewhere(t, rc.Instance.ConfigRepo(), fmt.Sprint(len(log)))
+	}
+	if _, _, err := pushChanges(rc, updates, "Release", logStatus); err == nil {
+		t.Fatal("expected pushing to fail")
+	}
+	if len(log) != pushAttempts {
+		t.Errorf("expected each of %d attempts to be logged, got %q", pushAttempts, log)
+	}
+}
+
 func setupRepo(t *testing.T) (git.Repo, func()) {
 	newDir, cleanup := testdata.TempDir(t)
 
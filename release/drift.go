@@ -0,0 +1,168 @@
+package release
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+	"github.com/weaveworks/flux/platform"
+	"github.com/weaveworks/flux/platform/kubernetes"
+)
+
+const (
+	none          = "<none>"
+	replicasField = "replicas"
+)
+
+// Drift compares each service defined in the (cloned) repo with what's
+// running for it, and reports how they differ. Services that aren't
+// in the running system are left out.
+func Drift(instID flux.InstanceID, rc *ReleaseContext) ([]flux.ServiceDrift, error) {
+	updates, err := rc.SelectServices(nil, nil, flux.ServiceIDSet{}, flux.ReleaseResult{}, func(string, ...interface{}) {})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding services")
+	}
+	var res []flux.ServiceDrift
+	for _, update := range updates {
+		res = append(res, serviceDrift(update))
+	}
+	sort.Sort(driftsByID(res))
+	recordDrift(instID, res)
+	return res, nil
+}
+
+func recordDrift(instID flux.InstanceID, drifts []flux.ServiceDrift) {
+	var drifted int
+	for _, d := range drifts {
+		if d.Drifted() {
+			drifted++
+		}
+	}
+	driftedServices.With(fluxmetrics.LabelInstanceID, string(instID)).Set(float64(drifted))
+}
+
+type driftsByID []flux.ServiceDrift
+
+func (d driftsByID) Len() int           { return len(d) }
+func (d driftsByID) Less(i, j int) bool { return d[i].ID < d[j].ID }
+func (d driftsByID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// serviceDrift compares the definition of a service with what's
+// running. If the platform doesn't report the spec of what's running
+// (e.g., because the daemon predates that), only the images of the
+// containers are compared.
+func serviceDrift(update *ServiceUpdate) flux.ServiceDrift {
+	res := flux.ServiceDrift{ID: update.ServiceID}
+	defined, err := kubernetes.DefinedSpec(update.ManifestBytes)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	running := update.Service.Spec
+	if running == nil {
+		running = &platform.ControllerSpec{}
+		for _, c := range update.Service.ContainersOrNil() {
+			running.Containers = append(running.Containers, platform.ContainerSpec{Name: c.Name, Image: c.Image})
+		}
+		defined = imagesOnly(defined)
+	}
+	res.Differences = diffSpecs(defined, running)
+	return res
+}
+
+// needsSync says whether a service has drifted in a way a sync should
+// put right. A difference in the number of replicas doesn't count,
+// since that's usually an autoscaler at work; applying the definition
+// would undo the scaling every time.
+func needsSync(drift flux.ServiceDrift) bool {
+	for _, d := range drift.Differences {
+		if d.Container != "" || d.Field != replicasField {
+			return true
+		}
+	}
+	return false
+}
+
+func imagesOnly(spec *platform.ControllerSpec) *platform.ControllerSpec {
+	res := &platform.ControllerSpec{}
+	for _, c := range spec.Containers {
+		res.Containers = append(res.Containers, platform.ContainerSpec{Name: c.Name, Image: c.Image})
+	}
+	return res
+}
+
+// diffSpecs lists the differences between a controller as defined
+// and as running. The number of replicas is only compared if the
+// definition gives it; otherwise it's left to, e.g., an autoscaler.
+func diffSpecs(defined, running *platform.ControllerSpec) []flux.Difference {
+	var res []flux.Difference
+	if defined.Replicas != nil {
+		runningReplicas := none
+		if running.Replicas != nil {
+			runningReplicas = fmt.Sprint(*running.Replicas)
+		}
+		if definedReplicas := fmt.Sprint(*defined.Replicas); definedReplicas != runningReplicas {
+			res = append(res, flux.Difference{Field: replicasField, Defined: definedReplicas, Running: runningReplicas})
+		}
+	}
+
+	runningContainers := map[string]platform.ContainerSpec{}
+	for _, c := range running.Containers {
+		runningContainers[c.Name] = c
+	}
+	for _, d := range defined.Containers {
+		r, ok := runningContainers[d.Name]
+		if !ok {
+			res = append(res, flux.Difference{Container: d.Name, Field: "image", Defined: d.Image, Running: none})
+			continue
+		}
+		delete(runningContainers, d.Name)
+		if d.Image != r.Image {
+			res = append(res, flux.Difference{Container: d.Name, Field: "image", Defined: d.Image, Running: r.Image})
+		}
+		res = append(res, diffMaps(d.Name, "env", d.Env, r.Env)...)
+		res = append(res, diffMaps(d.Name, "resources", d.Resources, r.Resources)...)
+	}
+	// Whatever's left is running but not defined; these come in the
+	// order they run in.
+	for _, r := range running.Containers {
+		if _, ok := runningContainers[r.Name]; ok {
+			res = append(res, flux.Difference{Container: r.Name, Field: "image", Defined: none, Running: r.Image})
+		}
+	}
+	return res
+}
+
+func diffMaps(container, field string, defined, running map[string]string) []flux.Difference {
+	keys := map[string]struct{}{}
+	for k := range defined {
+		keys[k] = struct{}{}
+	}
+	for k := range running {
+		keys[k] = struct{}{}
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var res []flux.Difference
+	for _, k := range sorted {
+		d, r := valueOrNone(defined, k), valueOrNone(running, k)
+		if d != r {
+			res = append(res, flux.Difference{Container: container, Field: field + " " + k, Defined: d, Running: r})
+		}
+	}
+	return res
+}
+
+func valueOrNone(m map[string]string, k string) string {
+	if v, ok := m[k]; ok {
+		return v
+	}
+	return none
+}
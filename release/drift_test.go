@@ -0,0 +1,117 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/platform"
+)
+
+func TestDrift(t *testing.T) {
+	serviceID, _ := flux.ParseServiceID("default/helloworld")
+	replicas := int32(2)
+	mockPlatform := &platform.MockPlatform{
+		SomeServicesAnswer: []platform.Service{
+			platform.Service{
+				ID: serviceID,
+				Spec: &platform.ControllerSpec{
+					Replicas: &replicas,
+					Containers: []platform.ContainerSpec{
+						{Name: "helloworld", Image: "quay.io/weaveworks/helloworld:master-a000001"},
+						{Name: "sidecar", Image: "quay.io/weaveworks/sidecar:master-a000002"},
+					},
+				},
+			},
+		},
+	}
+	releaser, cleanup := setup(t, instance.Instance{Platform: mockPlatform})
+	defer cleanup()
+
+	inst, err := releaser.instancer.Get(flux.InstanceID("instance 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := NewReleaseContext(inst)
+	if err := rc.CloneRepo(); err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Clean()
+
+	drifts, err := Drift(flux.InstanceID("instance 3"), rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []flux.ServiceDrift{{
+		ID:          serviceID,
+		Differences: []flux.Difference{{Field: "replicas", Defined: "5", Running: "2"}},
+	}}
+	if !reflect.DeepEqual(drifts, expected) {
+		t.Errorf("expected %#v, got %#v", expected, drifts)
+	}
+}
+
+func TestDiffSpecs(t *testing.T) {
+	one, two := int32(1), int32(2)
+	defined := &platform.ControllerSpec{
+		Replicas: &one,
+		Containers: []platform.ContainerSpec{
+			{Name: "a", Image: "foo:1", Env: map[string]string{"X": "1"}},
+			{Name: "b", Image: "bar:1", Resources: map[string]string{"limits.cpu": "500m"}},
+		},
+	}
+	for i, c := range []struct {
+		running  *platform.ControllerSpec
+		expected []flux.Difference
+	}{
+		{
+			running: &platform.ControllerSpec{
+				Replicas: &one,
+				Containers: []platform.ContainerSpec{
+					{Name: "b", Image: "bar:1", Resources: map[string]string{"limits.cpu": "500m"}},
+					{Name: "a", Image: "foo:1", Env: map[string]string{"X": "1"}},
+				},
+			},
+			expected: nil,
+		},
+		{
+			running: &platform.ControllerSpec{
+				Replicas: &two,
+				Containers: []platform.ContainerSpec{
+					{Name: "a", Image: "foo:2", Env: map[string]string{"X": "2", "Y": "1"}},
+					{Name: "b", Image: "bar:1"},
+				},
+			},
+			expected: []flux.Difference{
+				{Field: "replicas", Defined: "1", Running: "2"},
+				{Container: "a", Field: "image", Defined: "foo:1", Running: "foo:2"},
+				{Container: "a", Field: "env X", Defined: "1", Running: "2"},
+				{Container: "a", Field: "env Y", Defined: "<none>", Running: "1"},
+				{Container: "b", Field: "resources limits.cpu", Defined: "500m", Running: "<none>"},
+			},
+		},
+		{
+			running: &platform.ControllerSpec{
+				Replicas: &one,
+				Containers: []platform.ContainerSpec{
+					{Name: "a", Image: "foo:1", Env: map[string]string{"X": "1"}},
+					{Name: "c", Image: "baz:1"},
+				},
+			},
+			expected: []flux.Difference{
+				{Container: "b", Field: "image", Defined: "bar:1", Running: "<none>"},
+				{Container: "c", Field: "image", Defined: "<none>", Running: "baz:1"},
+			},
+		},
+	} {
+		if diffs := diffSpecs(defined, c.running); !reflect.DeepEqual(diffs, c.expected) {
+			t.Errorf("%d: expected %#v, got %#v", i, c.expected, diffs)
+		}
+	}
+
+	// Replicas aren't compared if the definition leaves them out
+	if diffs := diffSpecs(&platform.ControllerSpec{}, &platform.ControllerSpec{Replicas: &two}); len(diffs) != 0 {
+		t.Errorf("expected no differences, got %#v", diffs)
+	}
+}
@@ -0,0 +1,33 @@
+package release
+
+import (
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+// SkipFrozen takes the updates to services in a namespace that's in a
+// freeze window out of those given, recording them as skipped; unless
+// the release is forced, in which case it notes each freeze it goes
+// through, so that's in the release's log.
+func SkipFrozen(freezes flux.FreezeWindows, force bool, now time.Time, updates []*ServiceUpdate, results flux.ReleaseResult, logStatus statusFn) []*ServiceUpdate {
+	var unfrozen []*ServiceUpdate
+	for _, update := range updates {
+		namespace, _ := update.ServiceID.Components()
+		freeze, frozen := freezes.Frozen(namespace, now)
+		switch {
+		case !frozen:
+			unfrozen = append(unfrozen, update)
+		case force:
+			logStatus("Releasing service %s although it is %s, as the release is forced", update.ServiceID, freeze.Describe())
+			unfrozen = append(unfrozen, update)
+		default:
+			logStatus("Skipping service %s as it is %s", update.ServiceID, freeze.Describe())
+			results[update.ServiceID] = flux.ServiceResult{
+				Status: flux.ReleaseStatusSkipped,
+				Error:  freeze.Describe(),
+			}
+		}
+	}
+	return unfrozen
+}
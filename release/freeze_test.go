@@ -0,0 +1,125 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/jobs"
+	"github.com/weaveworks/flux/platform"
+	"github.com/weaveworks/flux/registry"
+)
+
+func setupFrozen(t *testing.T, freezes flux.FreezeWindows) (*Releaser, *eventLog, func()) {
+	serviceID, _ := flux.ParseServiceID("default/helloworld")
+	imageID, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	now := time.Now()
+
+	config := instance.MakeConfig()
+	config.Settings.Freezes = freezes
+	events := &eventLog{}
+	releaser, cleanup := setup(t, instance.Instance{
+		Platform: &platform.MockPlatform{
+			SomeServicesAnswer: []platform.Service{
+				platform.Service{
+					ID: serviceID,
+					Containers: platform.ContainersOrExcuse{
+						Containers: []platform.Container{
+							platform.Container{
+								Name:  "helloworld",
+								Image: "quay.io/weaveworks/helloworld:master-a000001",
+							},
+						},
+					},
+				},
+			},
+		},
+		Registry: registry.NewMockRegistry([]flux.Image{
+			flux.Image{ImageID: imageID, CreatedAt: &now},
+		}, nil),
+		Config:      &instance.MockConfigurer{config, nil},
+		EventReader: events,
+		EventWriter: events,
+	})
+	return releaser, events, cleanup
+}
+
+func releaseHelloworld(t *testing.T, releaser *Releaser, force bool) (flux.ServiceResult, []string) {
+	spec := jobs.ReleaseJobParams{
+		ServiceSpecs: []flux.ServiceSpec{"default/helloworld"},
+		ImageSpec:    flux.ImageSpecLatest,
+		Kind:         flux.ReleaseKindExecute,
+		Force:        force,
+	}
+	var results flux.ReleaseResult
+	job := &jobs.Job{Params: spec}
+	logStatus := func(format string, args ...interface{}) {
+		job.Log = append(job.Log, fmt.Sprintf(format, args...))
+	}
+	if _, err := releaser.release("instance", job, logStatus, func(r flux.ReleaseResult) { results = r }); err != nil {
+		t.Fatal(err)
+	}
+	return results["default/helloworld"], job.Log
+}
+
+func TestReleaseFrozen(t *testing.T) {
+	now := time.Now().UTC()
+	freeze := flux.FreezeWindow{
+		Name: "incident 42",
+		From: now.Add(-time.Hour).Format(time.RFC3339),
+		To:   now.Add(time.Hour).Format(time.RFC3339),
+	}
+
+	for _, x := range []struct {
+		window flux.FreezeWindow
+		frozen bool
+	}{
+		{freeze, true},
+		{flux.FreezeWindow{From: freeze.From, To: freeze.To, Namespaces: []string{"default"}}, true},
+		{flux.FreezeWindow{From: freeze.From, To: freeze.To, Namespaces: []string{"monitoring"}}, false},
+		{flux.FreezeWindow{Cron: "* * * * *", Duration: "1m"}, true},
+	} {
+		releaser, events, cleanup := setupFrozen(t, flux.FreezeWindows{x.window})
+		result, _ := releaseHelloworld(t, releaser, false)
+		cleanup()
+		if !x.frozen {
+			if result.Status != flux.ReleaseStatusSuccess {
+				t.Errorf("expected service outside %s to be released, got %#v", x.window, result)
+			}
+			continue
+		}
+		if result.Status != flux.ReleaseStatusSkipped || !strings.HasPrefix(result.Error, "frozen (") {
+			t.Errorf("expected service to be skipped as frozen by %s, got %#v", x.window, result)
+		}
+		if len(events.events) != 0 {
+			t.Errorf("expected nothing to be released, got events %#v", events.events)
+		}
+	}
+}
+
+func TestReleaseFrozenForced(t *testing.T) {
+	now := time.Now().UTC()
+	releaser, events, cleanup := setupFrozen(t, flux.FreezeWindows{{
+		Name: "incident 42",
+		From: now.Add(-time.Hour).Format(time.RFC3339),
+		To:   now.Add(time.Hour).Format(time.RFC3339),
+	}})
+	defer cleanup()
+
+	result, log := releaseHelloworld(t, releaser, true)
+	if result.Status != flux.ReleaseStatusSuccess {
+		t.Errorf("expected forced release to succeed, got %#v", result)
+	}
+	if !strings.Contains(strings.Join(log, "\n"), `although it is frozen (freeze window "incident 42"`) {
+		t.Errorf("expected the freeze being overridden to be logged, got %q", log)
+	}
+	if len(events.events) != 1 {
+		t.Fatalf("expected a release event, got %#v", events.events)
+	}
+	if s := events.events[0].String(); !strings.HasSuffix(s, " (forced)") {
+		t.Errorf("expected release to be recorded as forced, got %q", s)
+	}
+}
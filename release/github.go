@@ -0,0 +1,103 @@
+package release
+
+import (
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/integrations/github"
+)
+
+// GitHub won't take a status description longer than this.
+const maxStatusDescription = 140
+
+// githubDeployment tracks a release's commit as a GitHub deployment,
+// if the instance is configured to record them. Problems talking to
+// GitHub are logged, and don't fail the release; a nil
+// githubDeployment does nothing.
+type githubDeployment struct {
+	deployment *github.Deployment
+	logStatus  statusFn
+}
+
+// startDeployment records that the commit pushed, which is what's
+// checked out in the working clone, is being deployed.
+func startDeployment(rc *ReleaseContext, description string, logStatus statusFn) *githubDeployment {
+	config, err := rc.Instance.GetConfig()
+	if err != nil {
+		logStatus("Not recording GitHub deployment: %s", errors.Wrap(err, "getting instance config"))
+		return nil
+	}
+	settings := config.Settings.Github
+	if !settings.Deployments {
+		return nil
+	}
+	if settings.Token == "" {
+		logStatus("Not recording GitHub deployment: %s", ErrNoGithubToken)
+		return nil
+	}
+
+	d, err := createDeployment(rc, settings, description)
+	if err != nil {
+		logStatus("Could not record GitHub deployment: %s", err)
+		return nil
+	}
+	g := &githubDeployment{deployment: d, logStatus: logStatus}
+	g.setStatus(github.StatePending, "Applying changes.")
+	return g
+}
+
+func createDeployment(rc *ReleaseContext, settings flux.GithubConfig, description string) (*github.Deployment, error) {
+	owner, name, err := github.ParseRepoURL(rc.Instance.ConfigRepo().URL)
+	if err != nil {
+		return nil, err
+	}
+	client := github.NewGithubClient(settings.Token)
+	if settings.APIURL != "" {
+		if err := client.SetBaseURL(settings.APIURL); err != nil {
+			return nil, errors.Wrap(err, "parsing GitHub API URL")
+		}
+	}
+	ref, err := rc.HeadRevision()
+	if err != nil {
+		return nil, errors.Wrap(err, "finding commit pushed")
+	}
+	environment := settings.Environment
+	if environment == "" {
+		environment = flux.DefaultGithubEnvironment
+	}
+	return client.CreateDeployment(owner, name, ref, environment, truncate(description, maxStatusDescription))
+}
+
+// finish sets the final status of the deployment, according to
+// whether the changes were applied.
+func (g *githubDeployment) finish(applyErr error) {
+	if g == nil {
+		return
+	}
+	if applyErr != nil {
+		g.setStatus(github.StateFailure, "Failed: "+applyErr.Error())
+		return
+	}
+	g.setStatus(github.StateSuccess, "Changes applied.")
+}
+
+func (g *githubDeployment) setStatus(state, description string) {
+	if err := g.deployment.SetStatus(state, truncate(description, maxStatusDescription)); err != nil {
+		g.logStatus("Could not update GitHub deployment status to %s: %s", state, err)
+	}
+}
+
+// truncate shortens the string to at most n bytes, ending it with
+// "..." if it's cut; it's cut between runes, so it stays valid UTF-8.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	end := n - 3
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "..."
+}
@@ -0,0 +1,33 @@
+package release
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, x := range []struct {
+		s, expected string
+	}{
+		{"short", "short"},
+		{"exactly10!", "exactly10!"},
+		{"a bit too long", "a bit t..."},
+		// "é" is two bytes, and would be cut in half
+		{"un café noir", "un caf..."},
+		{"日本語のテキスト", "日本..."},
+	} {
+		got := truncate(x.s, 10)
+		if got != x.expected {
+			t.Errorf("expected %q truncated to %q, got %q", x.s, x.expected, got)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("expected valid UTF-8 truncating %q, got %q", x.s, got)
+		}
+	}
+
+	long := strings.Repeat("ü", maxStatusDescription)
+	if got := truncate(long, maxStatusDescription); len(got) > maxStatusDescription || !utf8.ValidString(got) {
+		t.Errorf("expected valid UTF-8 of at most %d bytes, got %q", maxStatusDescription, got)
+	}
+}
@@ -9,16 +9,18 @@ import (
 // Operations on instances (or instance.* types) that we need for
 // releasing
 
-func LockedServices(config instance.Config) flux.ServiceIDSet {
-	ids := []flux.ServiceID{}
+func LockedServices(config instance.Config) map[flux.ServiceID]flux.LockInfo {
+	locked := map[flux.ServiceID]flux.LockInfo{}
 	for id, s := range config.Services {
 		if s.Locked {
-			ids = append(ids, id)
+			var lock flux.LockInfo
+			if s.Lock != nil {
+				lock = *s.Lock
+			}
+			locked[id] = lock
 		}
 	}
-	idSet := flux.ServiceIDSet{}
-	idSet.Add(ids)
-	return idSet
+	return locked
 }
 
 // CollectAvailableImages is a convenient shim to
@@ -70,8 +72,9 @@ func applyChanges(inst *instance.Instance, updates []*ServiceUpdate, results flu
 		case platform.ApplyError:
 			for id, applyErr := range err {
 				results[id] = flux.ServiceResult{
-					Status: flux.ReleaseStatusFailed,
-					Error:  applyErr.Error(),
+					Status:       flux.ReleaseStatusFailed,
+					Error:        applyErr.Error(),
+					PerContainer: results[id].PerContainer,
 				}
 			}
 		default:
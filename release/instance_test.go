@@ -16,6 +16,7 @@ func TestLockedServices(t *testing.T) {
 			flux.ServiceID("service2"): instance.ServiceConfig{
 				Locked:    true,
 				Automated: true,
+				Lock:      &flux.LockInfo{Reason: "incident 42", User: "alice"},
 			},
 			flux.ServiceID("service3"): instance.ServiceConfig{
 				Automated: true,
@@ -24,13 +25,15 @@ func TestLockedServices(t *testing.T) {
 	}
 
 	locked := LockedServices(conf)
-	if !locked.Contains(flux.ServiceID("service1")) {
+	if _, ok := locked[flux.ServiceID("service1")]; !ok {
 		t.Error("service1 locked in config but not reported as locked")
 	}
-	if !locked.Contains(flux.ServiceID("service2")) {
+	if lock, ok := locked[flux.ServiceID("service2")]; !ok {
 		t.Error("service2 locked in config but not reported as locked")
+	} else if lock.Reason != "incident 42" || lock.User != "alice" {
+		t.Errorf("expected service2's lock details to be reported, got %#v", lock)
 	}
-	if locked.Contains(flux.ServiceID("service3")) {
+	if _, ok := locked[flux.ServiceID("service3")]; ok {
 		t.Error("service3 not locked but reported as locked")
 	}
 }
@@ -22,6 +22,19 @@ var (
 		Help:      "Duration in seconds of each stage of a release, including dry-runs.",
 		Buckets:   stdprometheus.DefBuckets,
 	}, []string{fluxmetrics.LabelStage})
+	syncDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "flux",
+		Subsystem: "fluxsvc",
+		Name:      "sync_duration_seconds",
+		Help:      "Duration in seconds of syncing the cluster with the repo.",
+		Buckets:   stdprometheus.DefBuckets,
+	}, []string{fluxmetrics.LabelSuccess})
+	driftedServices = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "flux",
+		Subsystem: "fluxsvc",
+		Name:      "drifted_services_count",
+		Help:      "Number of services running something other than their definition, as of the last check.",
+	}, []string{fluxmetrics.LabelInstanceID})
 )
 
 func NewStageTimer(stage string) *metrics.Timer {
@@ -23,8 +23,8 @@ func TestPrintResults(t *testing.T) {
 					PerContainer: []flux.ContainerUpdate{
 						{
 							Container: "helloworld",
-							Current:   flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000002"},
-							Target:    flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000001"},
+							Current:   flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002"},
+							Target:    flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
 						},
 					},
 				},
@@ -44,8 +44,8 @@ default/helloworld  pending  helloworld: quay.io/weaveworks/helloworld:master-a0
 					PerContainer: []flux.ContainerUpdate{
 						{
 							Container: "helloworld",
-							Current:   flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000002"},
-							Target:    flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000001"},
+							Current:   flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002"},
+							Target:    flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
 						},
 					},
 				},
@@ -57,6 +57,26 @@ default/helloworld  pending  test error
 `,
 		},
 
+		{
+			name: "pinned to a digest",
+			result: flux.ReleaseResult{
+				flux.ServiceID("default/helloworld"): flux.ServiceResult{
+					Status: flux.ReleaseStatusPending,
+					PerContainer: []flux.ContainerUpdate{
+						{
+							Container: "helloworld",
+							Current:   flux.ImageID{Host: "localhost:5000", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
+							Target:    flux.ImageID{Host: "localhost:5000", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002", Digest: "sha256:0123456789abcdef0123456789abcdef"},
+						},
+					},
+				},
+			},
+			expected: `
+SERVICE             STATUS   UPDATES
+default/helloworld  pending  helloworld: localhost:5000/weaveworks/helloworld:master-a000001 -> master-a000002@sha256:0123456789abcdef0123456789abcdef
+`,
+		},
+
 		{
 			name: "Service results should be sorted",
 			result: flux.ReleaseResult{
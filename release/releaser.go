@@ -11,10 +11,12 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
 	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/integrations/github"
 	"github.com/weaveworks/flux/jobs"
 	fluxmetrics "github.com/weaveworks/flux/metrics"
-	"github.com/weaveworks/flux/notifications"
 	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/platform/kubernetes"
 )
@@ -22,6 +24,30 @@ import (
 const FluxServiceName = "fluxsvc"
 const FluxDaemonName = "fluxd"
 
+// How many times to try pushing changes, when the branch keeps moving
+// on before we can push.
+const pushAttempts = 3
+
+// This is a user-facing error
+var ErrReleaseNotFound = flux.Missing{&flux.BaseError{
+	Help: `The release you want to roll back could not be found in the history.
+
+Check the release ID; note that only releases that were executed (not
+just planned) are recorded, and can be rolled back.`,
+	Err: errors.New("release not found in history"),
+}}
+
+// This is a user-facing error
+var ErrNoGithubToken = flux.UserConfigProblem{&flux.BaseError{
+	Help: `No GitHub token in your config
+
+Releases are set to be proposed as pull requests, which needs a GitHub
+token with permission to open pull requests in the config repo. Please
+add one to your config, as "token" under "github", or set "releaseMode"
+under "git" to "push".`,
+	Err: errors.New("no GitHub token in user config"),
+}}
+
 type Releaser struct {
 	instancer instance.Instancer
 }
@@ -40,6 +66,10 @@ type ServiceUpdate struct {
 	ManifestPath  string
 	ManifestBytes []byte
 	Updates       []flux.ContainerUpdate
+
+	// PreviousManifestBytes is the manifest as it was before any
+	// updates, so it can be restored if the release fails.
+	PreviousManifestBytes []byte
 }
 
 // These represent the side-effects that calculating and applying the
@@ -60,11 +90,16 @@ func (r *Releaser) Handle(job *jobs.Job, updater jobs.JobUpdater) ([]jobs.Job, e
 		updater.UpdateJob(*job)
 	}
 
+	switch job.Method {
+	case jobs.RollbackJob:
+		return r.rollback(job.Instance, job, logStatus, updateResult)
+	case jobs.MergeJob:
+		return r.merge(job.Instance, job, logStatus, updateResult)
+	}
+
 	// The job gets handed down through methods just so it can be used
-	// to construct a Release for the (possible) notification, which
-	// is a bit awkward; but we can factor it out once we have a less
-	// coupled way of dealing with release notifications (e.g., as a
-	// job itself).
+	// to construct the Release recorded in the event, which is a bit
+	// awkward.
 	return r.release(job.Instance, job, logStatus, updateResult)
 }
 
@@ -139,54 +174,586 @@ func (r *Releaser) release(instanceID flux.InstanceID, job *jobs.Job, logStatus
 		return nil, nil
 	}
 
+	var commitMsg string
 	if spec.ImageSpec != flux.ImageSpecNone {
+		commitMsg = commitMessageFromReleaseSpec(&spec)
+	}
+	return execute(rc, job, spec, "", updates, results, commitMsg, logStatus, report)
+}
+
+// Roll back a release that was executed earlier, by reverting the
+// image updates it made. Any containers that have since moved on to
+// other images are left alone.
+func (r *Releaser) rollback(instanceID flux.InstanceID, job *jobs.Job, logStatus statusFn, report resultFn) (_ []jobs.Job, err error) {
+	params := job.Params.(jobs.RollbackJobParams)
+	defer func(started time.Time) {
+		releaseDuration.With(
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+			fluxmetrics.LabelReleaseType, "rollback",
+			fluxmetrics.LabelReleaseKind, string(params.Kind),
+		).Observe(time.Since(started).Seconds())
+	}(time.Now())
+
+	inst, err := r.instancer.Get(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	inst.Logger = log.NewContext(inst.Logger).With("release-id", string(job.ID))
+
+	logStatus("Looking up release %s.", params.ReleaseID)
+	original, err := findRelease(inst, params.ReleaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the services that had images updated can be rolled back.
+	var ids []flux.ServiceID
+	for _, id := range original.Result.ServiceIDs() {
+		if len(original.Result[flux.ServiceID(id)].PerContainer) > 0 {
+			ids = append(ids, flux.ServiceID(id))
+		}
+	}
+	if len(ids) == 0 {
+		logStatus("Release %s did not update any images, so there is nothing to roll back.", original.ID)
+		return nil, nil
+	}
+
+	var timer *metrics.Timer
+
+	rc := NewReleaseContext(inst)
+	defer rc.Clean()
+	logStatus("Cloning git repository.")
+	timer = NewStageTimer("clone_repository")
+	if err = rc.CloneRepo(); err != nil {
+		return nil, err
+	}
+	timer.ObserveDuration()
+
+	results := flux.ReleaseResult{}
+
+	logStatus("Finding defined services.")
+	timer = NewStageTimer("select_services")
+	conf, err := inst.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	var updates []*ServiceUpdate
+	updates, err = rc.SelectServices(ids, LockedServices(conf), flux.ServiceIDSet{}, results, logStatus)
+	timer.ObserveDuration()
+	if err != nil {
+		return nil, err
+	}
+	updates = SkipFrozen(conf.Settings.Freezes, params.Force, time.Now(), updates, results, logStatus)
+	logStatus("Found %d services.", len(updates))
+	report(results)
+
+	logStatus("Calculating image rollbacks.")
+	timer = NewStageTimer("calculate_rollback")
+	updates, err = calculateRollbackUpdates(updates, original.Result, results, logStatus)
+	timer.ObserveDuration()
+	if err != nil {
+		return nil, err
+	}
+	report(results)
+
+	if len(updates) == 0 {
+		logStatus("No updates to do, finishing.")
+		return nil, nil
+	}
+
+	if params.Kind == flux.ReleaseKindPlan {
+		return nil, nil
+	}
+
+	spec := flux.ReleaseSpec{
+		Kind:  params.Kind,
+		Cause: params.Cause,
+		Force: params.Force,
+	}
+	for _, update := range updates {
+		spec.ServiceSpecs = append(spec.ServiceSpecs, flux.ServiceSpec(update.ServiceID))
+	}
+	return execute(rc, job, spec, original.ID, updates, results, commitMessageForRollback(original.ID, updates), logStatus, report)
+}
+
+// execute pushes the calculated updates to the repo (if there's a
+// commit message, i.e., something to commit), applies them to the
+// platform, then notifies and records the outcome. It is the common
+// tail end of releases and rollbacks.
+func execute(rc *ReleaseContext, job *jobs.Job, spec flux.ReleaseSpec, rollbackOf flux.ReleaseID, updates []*ServiceUpdate, results flux.ReleaseResult, commitMsg string, logStatus statusFn, report resultFn) ([]jobs.Job, error) {
+	var timer *metrics.Timer
+
+	var deployment *githubDeployment
+	if commitMsg != "" {
+		commitMsg = addTrailers(commitMsg, spec)
 		logStatus("Pushing changes.")
 		timer = NewStageTimer("push_changes")
-		err = rc.PushChanges(updates, &spec)
+		pullRequestURL, alreadyOpen, err := pushChanges(rc, updates, commitMsg, logStatus)
 		timer.ObserveDuration()
 		if err != nil {
 			return nil, err
 		}
+		if pullRequestURL != "" {
+			if alreadyOpen {
+				logStatus("Pull request %s is already open for these changes; they will be applied once it is merged.", pullRequestURL)
+			} else {
+				logStatus("Opened pull request %s; the changes will be applied once it is merged.", pullRequestURL)
+			}
+			return awaitMerge(rc, job, spec, rollbackOf, pullRequestURL, !alreadyOpen, updates, results, report)
+		}
+		deployment = startDeployment(rc, strings.SplitN(commitMsg, "\n", 2)[0], logStatus)
 	}
 
+	return nil, applyAndRecord(rc, job, flux.Release{
+		ID:        flux.ReleaseID(job.ID),
+		CreatedAt: job.Submitted,
+		StartedAt: job.Claimed,
+		Priority:  job.Priority,
+
+		Spec:       spec,
+		RollbackOf: rollbackOf,
+	}, updates, results, deployment, logStatus, report)
+}
+
+// applyAndRecord applies the updates to the platform, rolls back
+// those services that failed and have opted in, then notifies and
+// records the outcome as the release given; the log of the job is
+// added to the release's log.
+func applyAndRecord(rc *ReleaseContext, job *jobs.Job, release flux.Release, updates []*ServiceUpdate, results flux.ReleaseResult, deployment *githubDeployment, logStatus statusFn, report resultFn) error {
+	var timer *metrics.Timer
+
 	logStatus("Applying changes.")
 	timer = NewStageTimer("apply_changes")
 	applyErr := applyChanges(rc.Instance, updates, results)
 	timer.ObserveDuration()
 
+	// Services that have opted in get rolled back if they failed to
+	// apply; but we don't roll back rollbacks.
+	var rolledBack flux.ReleaseResult
+	if applyErr != nil && release.RollbackOf == "" {
+		timer = NewStageTimer("auto_rollback")
+		rolledBack = autoRollback(rc, release.ID, updates, applyErr, results, logStatus)
+		timer.ObserveDuration()
+	}
+	deployment.finish(applyErr)
+
 	status := flux.ReleaseStatusSuccess
 	if applyErr != nil {
 		status = flux.ReleaseStatusFailed
 	}
-	release := flux.Release{
-		ID:        flux.ReleaseID(job.ID),
+	// TODO: fetch the job and look the end up so it matches (which
+	// must be done after completing the job)
+	release.EndedAt = time.Now().UTC()
+	release.Done = true
+	release.Status = status
+	release.Log = append(release.Log, job.Log...)
+	release.Result = results
+
+	// Log the event into the history; this is also what tells the
+	// notifiers about the release, by queueing jobs to do so.
+	timer = NewStageTimer("log_event")
+	err := logEvent(rc.Instance, applyErr, release)
+	if len(rolledBack) > 0 {
+		var services []flux.ServiceSpec
+		for _, id := range rolledBack.ServiceIDs() {
+			services = append(services, flux.ServiceSpec(id))
+		}
+		rollbackErr := logEvent(rc.Instance, nil, flux.Release{
+			ID:        flux.NewReleaseID(),
+			CreatedAt: release.EndedAt,
+			StartedAt: release.EndedAt,
+			EndedAt:   time.Now().UTC(),
+			Done:      true,
+			Priority:  release.Priority,
+			Status:    flux.ReleaseStatusSuccess,
+			Spec: flux.ReleaseSpec{
+				ServiceSpecs: services,
+				Kind:         flux.ReleaseKindExecute,
+				Cause:        flux.Cause{User: flux.UserAutomated},
+			},
+			Result:     rolledBack,
+			RollbackOf: release.ID,
+		})
+		if err == nil {
+			err = rollbackErr
+		}
+	}
+	timer.ObserveDuration()
+
+	report(results)
+
+	return err
+}
+
+// pushChanges pushes the updates to the repo. If the push is rejected
+// because someone else has pushed in the meantime, it catches up with
+// the repo, makes the updates again, and has another go, up to
+// pushAttempts times in all.
+func pushChanges(rc *ReleaseContext, updates []*ServiceUpdate, commitMsg string, logStatus statusFn) (string, bool, error) {
+	for attempt := 1; ; attempt++ {
+		pullRequestURL, alreadyOpen, err := rc.PushChanges(updates, commitMsg)
+		if errors.Cause(err) != git.ErrNonFastForward {
+			return pullRequestURL, alreadyOpen, err
+		}
+		if attempt == pushAttempts {
+			logStatus("Push rejected as the repo has changed (attempt %d of %d); giving up.", attempt, pushAttempts)
+			return "", false, errors.Wrapf(err, "pushing changes, after %d attempts", attempt)
+		}
+		if err := rc.ResetRepo(); err != nil {
+			return "", false, errors.Wrap(err, "fetching repo")
+		}
+		if err := reapplyUpdates(updates); err != nil {
+			return "", false, errors.Wrap(err, "making updates again")
+		}
+		logStatus("Push rejected as the repo has changed (attempt %d of %d); trying again on top of the changes.", attempt, pushAttempts)
+	}
+}
+
+// reapplyUpdates makes the image updates again, to the manifests as
+// they are now, e.g., after catching up with the repo.
+func reapplyUpdates(updates []*ServiceUpdate) error {
+	for _, update := range updates {
+		def, err := ioutil.ReadFile(update.ManifestPath)
+		if err != nil {
+			return err
+		}
+		update.PreviousManifestBytes = def
+		for _, u := range update.Updates {
+			if def, err = kubernetes.UpdatePodController(def, u.Target, ioutil.Discard); err != nil {
+				return errors.Wrapf(err, "updating %s", update.ServiceID)
+			}
+		}
+		update.ManifestBytes = def
+	}
+	return nil
+}
+
+// awaitMerge records a release that's been proposed as a pull
+// request. There's nothing to apply yet; the release that opened the
+// pull request is logged, and followed by a job that waits for the
+// pull request to be merged, then applies it. Proposing the same
+// changes again, while the pull request is open, leaves that to the
+// first release, so it doesn't fill the history.
+func awaitMerge(rc *ReleaseContext, job *jobs.Job, spec flux.ReleaseSpec, rollbackOf flux.ReleaseID, pullRequestURL string, opened bool, updates []*ServiceUpdate, results flux.ReleaseResult, report resultFn) ([]jobs.Job, error) {
+	for _, update := range updates {
+		result := results[update.ServiceID]
+		result.Status = flux.ReleaseStatusAwaitingMerge
+		results[update.ServiceID] = result
+	}
+	report(results)
+	if !opened {
+		return nil, nil
+	}
+
+	timer := NewStageTimer("log_event")
+	defer timer.ObserveDuration()
+	id := flux.ReleaseID(job.ID)
+	err := logEvent(rc.Instance, nil, flux.Release{
+		ID:        id,
 		CreatedAt: job.Submitted,
 		StartedAt: job.Claimed,
-		// TODO: fetch the job and look this up so it matches
-		// (which must be done after completing the job)
-		EndedAt:  time.Now().UTC(),
-		Done:     true,
-		Priority: job.Priority,
-		Status:   status,
-		Log:      job.Log,
+		EndedAt:   time.Now().UTC(),
+		Done:      true,
+		Priority:  job.Priority,
+		Status:    flux.ReleaseStatusAwaitingMerge,
+		Log:       job.Log,
 
-		Spec:   job.Params.(jobs.ReleaseJobParams).Spec(),
-		Result: results,
+		Spec:           spec,
+		Result:         results,
+		RollbackOf:     rollbackOf,
+		PullRequestURL: pullRequestURL,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return []jobs.Job{mergeJob(id, time.Now().Add(mergePollInterval))}, nil
+}
 
-	// Report on success or failure of the application above.
-	timer = NewStageTimer("send_notifications")
-	notifyErr := sendNotifications(rc.Instance, applyErr, release)
-	timer.ObserveDuration()
+// How long to wait between looking at the pull request proposing a
+// release, to see if it's been merged.
+var mergePollInterval = time.Minute
 
-	// Log the event into the history
-	timer = NewStageTimer("log_event")
-	err = logEvent(rc.Instance, notifyErr, release)
-	timer.ObserveDuration()
+func mergeJob(id flux.ReleaseID, at time.Time) jobs.Job {
+	return jobs.Job{
+		// On the release queue, so it doesn't apply things at the
+		// same time as a release or sync.
+		Queue: jobs.ReleaseJob,
+		// Key stops us getting two jobs for the same release
+		Key: strings.Join([]string{
+			jobs.MergeJob,
+			string(id),
+		}, "|"),
+		Method:      jobs.MergeJob,
+		Priority:    jobs.PriorityBackground,
+		ScheduledAt: at,
+		Params: jobs.MergeJobParams{
+			ReleaseID: id,
+		},
+	}
+}
+
+// merge looks at the pull request proposing a release. Once it's
+// merged, the release is applied as it is in the repo then, and the
+// outcome recorded against the release; until then, it's looked at
+// again every mergePollInterval. If it's closed without being merged,
+// the release is recorded as having failed.
+func (r *Releaser) merge(instanceID flux.InstanceID, job *jobs.Job, logStatus statusFn, report resultFn) (_ []jobs.Job, err error) {
+	params := job.Params.(jobs.MergeJobParams)
+	defer func(started time.Time) {
+		releaseDuration.With(
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+			fluxmetrics.LabelReleaseType, "merge",
+			fluxmetrics.LabelReleaseKind, string(flux.ReleaseKindExecute),
+		).Observe(time.Since(started).Seconds())
+	}(time.Now())
+
+	inst, err := r.instancer.Get(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	inst.Logger = log.NewContext(inst.Logger).With("release-id", string(params.ReleaseID))
+
+	proposed, err := findRelease(inst, params.ReleaseID)
+	if err != nil {
+		return nil, err
+	}
+	if proposed.Status != flux.ReleaseStatusAwaitingMerge {
+		logStatus("Release %s is no longer awaiting merge.", proposed.ID)
+		return nil, nil
+	}
+	config, err := inst.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting instance config")
+	}
+
+	logStatus("Looking at pull request %s.", proposed.PullRequestURL)
+	state, err := pullRequestState(inst, config.Settings.Github, proposed.PullRequestURL)
+	if err != nil {
+		// GitHub may be unavailable for a while, so don't give up.
+		return []jobs.Job{mergeJob(proposed.ID, time.Now().Add(mergePollInterval))}, errors.Wrap(err, "looking at pull request")
+	}
+	switch state {
+	case github.PullRequestOpen:
+		logStatus("Pull request %s is still open.", proposed.PullRequestURL)
+		return []jobs.Job{mergeJob(proposed.ID, time.Now().Add(mergePollInterval))}, nil
+	case github.PullRequestClosed:
+		closedErr := errors.Errorf("pull request %s was closed without being merged", proposed.PullRequestURL)
+		logStatus("Not applying release %s: %s.", proposed.ID, closedErr)
+		results := flux.ReleaseResult{}
+		for id, result := range proposed.Result {
+			if result.Status == flux.ReleaseStatusAwaitingMerge {
+				result.Status = flux.ReleaseStatusSkipped
+				result.Error = "pull request closed without being merged"
+			}
+			results[id] = result
+		}
+		report(results)
+		release := proposed
+		release.EndedAt = time.Now().UTC()
+		release.Status = flux.ReleaseStatusFailed
+		release.Log = append(release.Log, job.Log...)
+		release.Result = results
+		return nil, logEvent(inst, closedErr, release)
+	}
+
+	logStatus("Pull request %s has been merged.", proposed.PullRequestURL)
+	rc := NewReleaseContext(inst)
+	defer rc.Clean()
+	logStatus("Cloning git repository.")
+	if err = rc.CloneRepo(); err != nil {
+		return nil, err
+	}
 
+	// The services are applied as they're defined now the pull
+	// request is merged, unless they've since been locked or frozen.
+	var ids []flux.ServiceID
+	for _, id := range proposed.Result.ServiceIDs() {
+		if proposed.Result[flux.ServiceID(id)].Status == flux.ReleaseStatusAwaitingMerge {
+			ids = append(ids, flux.ServiceID(id))
+		}
+	}
+	results := flux.ReleaseResult{}
+	updates, err := rc.SelectServices(ids, LockedServices(config), flux.ServiceIDSet{}, results, logStatus)
+	if err != nil {
+		return nil, err
+	}
+	updates = SkipFrozen(config.Settings.Freezes, proposed.Spec.Force, time.Now(), updates, results, logStatus)
+	for _, update := range updates {
+		update.Updates = proposed.Result[update.ServiceID].PerContainer
+		result := results[update.ServiceID]
+		result.PerContainer = update.Updates
+		results[update.ServiceID] = result
+		// What to restore, should the release be rolled back
+		// automatically.
+		previous := update.ManifestBytes
+		for _, u := range update.Updates {
+			if previous, err = kubernetes.UpdatePodController(previous, u.Current, ioutil.Discard); err != nil {
+				return nil, errors.Wrapf(err, "finding previous definition of %s", update.ServiceID)
+			}
+		}
+		update.PreviousManifestBytes = previous
+	}
 	report(results)
 
-	return nil, err
+	deployment := startDeployment(rc, fmt.Sprintf("Merged pull request %s", proposed.PullRequestURL), logStatus)
+	return nil, applyAndRecord(rc, job, proposed, updates, results, deployment, logStatus, report)
+}
+
+// pullRequestState asks GitHub whether the pull request is open,
+// merged, or closed.
+func pullRequestState(inst *instance.Instance, settings flux.GithubConfig, pullRequestURL string) (string, error) {
+	if settings.Token == "" {
+		return "", ErrNoGithubToken
+	}
+	owner, name, err := github.ParseRepoURL(inst.ConfigRepo().URL)
+	if err != nil {
+		return "", err
+	}
+	client := github.NewGithubClient(settings.Token)
+	if settings.APIURL != "" {
+		if err := client.SetBaseURL(settings.APIURL); err != nil {
+			return "", errors.Wrap(err, "parsing GitHub API URL")
+		}
+	}
+	return client.PullRequestState(owner, name, pullRequestURL)
+}
+
+// autoRollback restores the previous definitions of those services
+// that failed to apply and have auto-rollback enabled, by committing
+// them to the repo then applying them. It updates the results to say
+// what happened, and returns the image updates made by rolling back,
+// for the record.
+func autoRollback(rc *ReleaseContext, releaseID flux.ReleaseID, updates []*ServiceUpdate, applyErr error, results flux.ReleaseResult, logStatus statusFn) flux.ReleaseResult {
+	// If the error isn't specific to services, we can't tell what
+	// failed.
+	failures, ok := applyErr.(platform.ApplyError)
+	if !ok {
+		return nil
+	}
+
+	conf, err := rc.Instance.GetConfig()
+	if err != nil {
+		logStatus("Not rolling back failed services: %s", err)
+		return nil
+	}
+
+	var reverts []*ServiceUpdate
+	for _, update := range updates {
+		failure, failed := failures[update.ServiceID]
+		if !failed || !conf.Services[update.ServiceID].AutoRollback {
+			continue
+		}
+		logStatus("Release of %s failed: %s; rolling back.", update.ServiceID, failure)
+		var inverse []flux.ContainerUpdate
+		for _, u := range update.Updates {
+			inverse = append(inverse, flux.ContainerUpdate{
+				Container: u.Container,
+				Current:   u.Target,
+				Target:    u.Current,
+			})
+		}
+		reverts = append(reverts, &ServiceUpdate{
+			ServiceID:             update.ServiceID,
+			Service:               update.Service,
+			ManifestPath:          update.ManifestPath,
+			ManifestBytes:         update.PreviousManifestBytes,
+			Updates:               inverse,
+			PreviousManifestBytes: update.PreviousManifestBytes,
+		})
+	}
+	if len(reverts) == 0 {
+		return nil
+	}
+
+	rollbackFailed := func(id flux.ServiceID, err error) {
+		logStatus("Rolling back %s failed: %s", id, err)
+		result := results[id]
+		results[id] = flux.ServiceResult{
+			Status:       flux.ReleaseStatusFailed,
+			Error:        fmt.Sprintf("%s; rolling back failed: %s", result.Error, err),
+			PerContainer: result.PerContainer,
+		}
+	}
+
+	if _, _, err := pushChanges(rc, reverts, commitMessageForAutoRollback(releaseID, reverts), logStatus); err != nil {
+		for _, revert := range reverts {
+			rollbackFailed(revert.ServiceID, err)
+		}
+		return nil
+	}
+
+	var defs []platform.ServiceDefinition
+	for _, revert := range reverts {
+		defs = append(defs, platform.ServiceDefinition{
+			ServiceID:     revert.ServiceID,
+			NewDefinition: revert.ManifestBytes,
+		})
+	}
+	revertErr := rc.Instance.PlatformApply(defs)
+
+	rolledBack := flux.ReleaseResult{}
+	for _, revert := range reverts {
+		var err error
+		switch e := revertErr.(type) {
+		case nil:
+		case platform.ApplyError:
+			err = e[revert.ServiceID]
+		default:
+			err = revertErr
+		}
+		if err != nil {
+			rollbackFailed(revert.ServiceID, err)
+			continue
+		}
+		logStatus("Rolled back %s.", revert.ServiceID)
+		result := results[revert.ServiceID]
+		results[revert.ServiceID] = flux.ServiceResult{
+			Status:       flux.ReleaseStatusRolledBack,
+			Error:        result.Error + "; rolled back",
+			PerContainer: result.PerContainer,
+		}
+		rolledBack[revert.ServiceID] = flux.ServiceResult{
+			Status:       flux.ReleaseStatusSuccess,
+			PerContainer: revert.Updates,
+		}
+	}
+	return rolledBack
+}
+
+// findRelease looks through the history for the release given, and
+// returns it as it was recorded when it finished.
+func findRelease(inst *instance.Instance, id flux.ReleaseID) (flux.Release, error) {
+	event, err := inst.ReleaseEvent(id)
+	if err == history.ErrEventNotFound {
+		// Events recorded before they were indexed by release ID
+		// can only be found by looking through them all.
+		return scanForRelease(inst, id)
+	}
+	if err != nil {
+		return flux.Release{}, errors.Wrap(err, "reading history")
+	}
+	metadata, ok := event.Metadata.(flux.ReleaseEventMetadata)
+	if !ok {
+		return flux.Release{}, ErrReleaseNotFound
+	}
+	return metadata.Release, nil
+}
+
+// scanForRelease looks through all the events in the history for the
+// release given. The events come latest first, so it's the latest
+// record of the release that's returned.
+func scanForRelease(inst *instance.Instance, id flux.ReleaseID) (flux.Release, error) {
+	events, err := inst.AllEvents()
+	if err != nil {
+		return flux.Release{}, errors.Wrap(err, "reading history")
+	}
+	for _, event := range events {
+		if metadata, ok := event.Metadata.(flux.ReleaseEventMetadata); ok && metadata.Release.ID == id {
+			return metadata.Release, nil
+		}
+	}
+	return flux.Release{}, ErrReleaseNotFound
 }
 
 // `logEvent` expects the result of applying updates, and records an event in
@@ -205,9 +772,14 @@ func logEvent(inst *instance.Instance, executeErr error, release flux.Release) e
 		serviceIDs = append(serviceIDs, flux.ServiceID(id))
 	}
 
+	eventType := flux.EventRelease
+	if release.RollbackOf != "" {
+		eventType = flux.EventRollback
+	}
+
 	err := inst.LogEvent(flux.Event{
 		ServiceIDs: serviceIDs,
-		Type:       flux.EventRelease,
+		Type:       eventType,
 		StartedAt:  release.StartedAt,
 		EndedAt:    release.EndedAt,
 		LogLevel:   logLevel,
@@ -224,43 +796,34 @@ func logEvent(inst *instance.Instance, executeErr error, release flux.Release) e
 	return executeErr
 }
 
-// `sendNotifications` expects the result of applying updates, and
-// sends notifications indicating success or failure. It returns the
-// origin error if that was non-nil, otherwise the result of the
-// attempted notifications.
-func sendNotifications(inst *instance.Instance, executeErr error, release flux.Release) error {
-	cfg, err := inst.GetConfig()
-	if err != nil {
-		if executeErr == nil {
-			return errors.Wrap(err, "sending notifications")
-		}
-		return executeErr
-	}
-
-	// Filling this from the job is a temporary migration hack. Ideally all
-	// the release info should be stored on the release object in a releases
-	// table, and the job should really just have a pointer to that.
-	err = notifications.Release(cfg, release, executeErr)
-	if err != nil {
-		if executeErr == nil {
-			return errors.Wrap(err, "sending notifications")
-		}
-	}
-	return executeErr
-}
-
 // Take the spec given in the job, and figure out which services are
 // in question based on the running services and those defined in the
-// repo. Fill in the release results along the way.
+// repo, leaving out those that are locked or frozen. Fill in the
+// release results along the way.
 func selectServices(rc *ReleaseContext, spec *flux.ReleaseSpec, results flux.ReleaseResult, logStatus statusFn) ([]*ServiceUpdate, error) {
 	conf, err := rc.Instance.GetConfig()
 	if err != nil {
 		return nil, err
 	}
-	lockedSet := LockedServices(conf)
+	updates, err := selectSpecifiedServices(rc, spec, LockedServices(conf), results, logStatus)
+	if err != nil {
+		return nil, err
+	}
+	return SkipFrozen(conf.Settings.Freezes, spec.Force, time.Now(), updates, results, logStatus), nil
+}
 
+func selectSpecifiedServices(rc *ReleaseContext, spec *flux.ReleaseSpec, lockedSet map[flux.ServiceID]flux.LockInfo, results flux.ReleaseResult, logStatus statusFn) ([]*ServiceUpdate, error) {
+	// Services may be given by their own IDs or via the service in
+	// front of them; use the IDs that the platform, and the
+	// definitions in the repo, go by.
 	excludedSet := flux.ServiceIDSet{}
-	excludedSet.Add(spec.Excludes)
+	for _, id := range spec.Excludes {
+		id, err := rc.Instance.ResolveServiceID(id)
+		if err != nil {
+			return nil, err
+		}
+		excludedSet[id] = struct{}{}
+	}
 
 	// For backwards-compatibility, there's two fields: ServiceSpec
 	// and ServiceSpecs. An entry in ServiceSpec takes precedence.
@@ -275,6 +838,9 @@ func selectServices(rc *ReleaseContext, spec *flux.ReleaseSpec, results flux.Rel
 			if err != nil {
 				return nil, err
 			}
+			if id, err = rc.Instance.ResolveServiceID(id); err != nil {
+				return nil, err
+			}
 			ids = append(ids, id)
 		}
 		return rc.SelectServices(ids, lockedSet, excludedSet, results, logStatus)
@@ -285,6 +851,9 @@ func selectServices(rc *ReleaseContext, spec *flux.ReleaseSpec, results flux.Rel
 		if err != nil {
 			return nil, err
 		}
+		if id, err = rc.Instance.ResolveServiceID(id); err != nil {
+			return nil, err
+		}
 		return rc.SelectServices([]flux.ServiceID{id}, lockedSet, excludedSet, results, logStatus)
 	}
 }
@@ -317,6 +886,20 @@ func calculateImageUpdates(inst *instance.Instance, candidates []*ServiceUpdate,
 		return nil, err
 	}
 
+	// When updating to the latest images, the services may each have
+	// their own idea of which image is the latest, and which images
+	// are eligible at all.
+	conf, err := inst.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	configFor := func(id flux.ServiceID) instance.ServiceConfig {
+		if spec.ImageSpec != flux.ImageSpecLatest {
+			return instance.ServiceConfig{}
+		}
+		return conf.Services[id]
+	}
+
 	// Look through all the services' containers to see which have an
 	// image that could be updated.
 	var updates []*ServiceUpdate
@@ -337,6 +920,7 @@ func calculateImageUpdates(inst *instance.Instance, candidates []*ServiceUpdate,
 		ignoredOrSkipped := flux.ReleaseStatusIgnored
 		var containerUpdates []flux.ContainerUpdate
 
+		serviceConf := configFor(update.ServiceID)
 		for _, container := range containers {
 			currentImageID, err := flux.ParseImageID(container.Image)
 			if err != nil {
@@ -345,12 +929,12 @@ func calculateImageUpdates(inst *instance.Instance, candidates []*ServiceUpdate,
 				return nil, err
 			}
 
-			latestImage := images.LatestImage(currentImageID.Repository())
+			latestImage := images.LatestImage(currentImageID.Repository(), serviceConf.ImageOrder, serviceConf.TagFilter)
 			if latestImage == nil {
 				continue
 			}
 
-			if currentImageID == latestImage.ID {
+			if currentImageID.UpToDateWith(latestImage.ID) {
 				ignoredOrSkipped = flux.ReleaseStatusSkipped
 				continue
 			}
@@ -360,7 +944,7 @@ func calculateImageUpdates(inst *instance.Instance, candidates []*ServiceUpdate,
 				return nil, err
 			}
 
-			logStatus("Will update %s container %s: %s -> %s", update.ServiceID, container.Name, currentImageID, latestImage.ID.Tag)
+			logStatus("Will update %s container %s: %s -> %s", update.ServiceID, container.Name, currentImageID, latestImage.ID.TagOrDigest())
 			containerUpdates = append(containerUpdates, flux.ContainerUpdate{
 				Container: container.Name,
 				Current:   currentImageID,
@@ -394,6 +978,94 @@ func calculateImageUpdates(inst *instance.Instance, candidates []*ServiceUpdate,
 	return updates, nil
 }
 
+// Find the containers that were updated by the release given (as
+// recorded in its result), and revert them to the images they were
+// running before. Fill in the results along the way.
+func calculateRollbackUpdates(candidates []*ServiceUpdate, previous flux.ReleaseResult, results flux.ReleaseResult, logStatus statusFn) ([]*ServiceUpdate, error) {
+	var updates []*ServiceUpdate
+	for _, update := range candidates {
+		containers, err := update.Service.ContainersOrError()
+		if err != nil {
+			logStatus("Failing service %s: %s", update.ServiceID, err.Error())
+			results[update.ServiceID] = flux.ServiceResult{
+				Status: flux.ReleaseStatusFailed,
+				Error:  err.Error(),
+			}
+			continue
+		}
+
+		running := map[string]string{}
+		for _, container := range containers {
+			running[container.Name] = container.Image
+		}
+
+		var containerUpdates []flux.ContainerUpdate
+		for _, released := range previous[update.ServiceID].PerContainer {
+			image, ok := running[released.Container]
+			if !ok {
+				logStatus("Not rolling back %s container %s, as it is no longer running", update.ServiceID, released.Container)
+				continue
+			}
+			currentImageID, err := flux.ParseImageID(image)
+			if err != nil {
+				return nil, err
+			}
+			if currentImageID.UpToDateWith(released.Current) {
+				logStatus("Not rolling back %s container %s, as it is already running %s", update.ServiceID, released.Container, currentImageID)
+				continue
+			}
+			if !currentImageID.UpToDateWith(released.Target) {
+				logStatus("Not rolling back %s container %s, as its image has changed since the release (%s)", update.ServiceID, released.Container, currentImageID)
+				continue
+			}
+
+			update.ManifestBytes, err = kubernetes.UpdatePodController(update.ManifestBytes, released.Current, ioutil.Discard)
+			if err != nil {
+				return nil, err
+			}
+
+			logStatus("Will roll back %s container %s: %s -> %s", update.ServiceID, released.Container, currentImageID, released.Current.TagOrDigest())
+			containerUpdates = append(containerUpdates, flux.ContainerUpdate{
+				Container: released.Container,
+				Current:   currentImageID,
+				Target:    released.Current,
+			})
+		}
+
+		if len(containerUpdates) > 0 {
+			update.Updates = containerUpdates
+			updates = append(updates, update)
+			results[update.ServiceID] = flux.ServiceResult{
+				Status:       flux.ReleaseStatusPending,
+				PerContainer: containerUpdates,
+			}
+		} else {
+			logStatus("Skipping service %s, no images to roll back", update.ServiceID)
+			results[update.ServiceID] = flux.ServiceResult{
+				Status: flux.ReleaseStatusSkipped,
+				Error:  "no images to roll back",
+			}
+		}
+	}
+	return updates, nil
+}
+
+func commitMessageForRollback(id flux.ReleaseID, updates []*ServiceUpdate) string {
+	var services []string
+	for _, update := range updates {
+		services = append(services, update.ServiceID.String())
+	}
+	return fmt.Sprintf("Roll back release %s for %s", id, strings.Join(services, ", "))
+}
+
+func commitMessageForAutoRollback(id flux.ReleaseID, reverts []*ServiceUpdate) string {
+	var services []string
+	for _, revert := range reverts {
+		services = append(services, revert.ServiceID.String())
+	}
+	return fmt.Sprintf("Roll back %s after failed release %s", strings.Join(services, ", "), id)
+}
+
 func commitMessageFromReleaseSpec(spec *flux.ReleaseSpec) string {
 	image := strings.Trim(spec.ImageSpec.String(), "<>")
 	var services []string
@@ -402,3 +1074,17 @@ func commitMessageFromReleaseSpec(spec *flux.ReleaseSpec) string {
 	}
 	return fmt.Sprintf("Release %s to %s", image, strings.Join(services, ", "))
 }
+
+// addTrailers records who asked for a release, and why, in its commit
+// message, so that it's in the history of the repo too. The user goes
+// in a git trailer, so it can be picked out.
+func addTrailers(commitMsg string, spec flux.ReleaseSpec) string {
+	if message := strings.TrimSpace(spec.Cause.Message); message != "" {
+		commitMsg = commitMsg + "\n\n" + message
+	}
+	if spec.Cause.User != "" {
+		user := strings.Join(strings.Fields(spec.Cause.User), " ")
+		commitMsg = commitMsg + "\n\nReleased-by: " + user
+	}
+	return commitMsg
+}
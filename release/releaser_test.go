@@ -1,16 +1,22 @@
 package release
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/history"
 	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/integrations/github"
 	"github.com/weaveworks/flux/jobs"
 	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/registry"
@@ -32,8 +38,10 @@ func setup(t *testing.T, mocks instance.Instance) (*Releaser, func()) {
 		mocks.Config = &instance.MockConfigurer{config, nil}
 	}
 	mocks.Repo = repo
-	events := history.NewMock()
-	mocks.EventReader, mocks.EventWriter = events, events
+	if mocks.EventReader == nil {
+		events := history.NewMock()
+		mocks.EventReader, mocks.EventWriter = events, events
+	}
 	mocks.Logger = log.NewNopLogger()
 
 	instancer := &instance.MockInstancer{&mocks, nil}
@@ -76,6 +84,10 @@ func TestMissingFromPlatform(t *testing.T) {
 			Status: flux.ReleaseStatusIgnored,
 			Error:  "not in running system",
 		},
+		flux.ServiceID("monitoring:daemonset/node-exporter"): flux.ServiceResult{
+			Status: flux.ReleaseStatusIgnored,
+			Error:  "not in running system",
+		},
 	}
 	if !reflect.DeepEqual(expected, results) {
 		t.Errorf("expected %#v, got %#v", expected, results)
@@ -188,3 +200,724 @@ func TestUpdateOne(t *testing.T) {
 	PrintResults(os.Stdout, results, true)
 	println()
 }
+
+// A history that just remembers the events it's given
+type eventLog struct {
+	events []flux.Event
+}
+
+func (l *eventLog) AllEvents() ([]flux.Event, error) {
+	return l.events, nil
+}
+
+func (l *eventLog) EventsForService(_ flux.ServiceID) ([]flux.Event, error) {
+	return l.events, nil
+}
+
+func (l *eventLog) GetEvent(_ flux.EventID) (flux.Event, error) {
+	return flux.Event{}, errors.New("not implemented")
+}
+
+func (l *eventLog) ReleaseEvent(id flux.ReleaseID) (flux.Event, error) {
+	for _, event := range l.events {
+		if metadata, ok := event.Metadata.(flux.ReleaseEventMetadata); ok && metadata.Release.ID == id {
+			return event, nil
+		}
+	}
+	return flux.Event{}, history.ErrEventNotFound
+}
+
+func (l *eventLog) LogEvent(e flux.Event) error {
+	l.events = append([]flux.Event{e}, l.events...)
+	return nil
+}
+
+// A history whose events were recorded before they were indexed by
+// release ID, so they can't be looked up that way
+type unindexedEventLog struct {
+	*eventLog
+}
+
+func (l unindexedEventLog) ReleaseEvent(_ flux.ReleaseID) (flux.Event, error) {
+	return flux.Event{}, history.ErrEventNotFound
+}
+
+func setupRollback(t *testing.T) (*Releaser, *eventLog, func()) {
+	serviceID, _ := flux.ParseServiceID("default/helloworld")
+	before, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000000")
+	after, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000001")
+
+	events := &eventLog{}
+	events.LogEvent(flux.Event{
+		ServiceIDs: []flux.ServiceID{serviceID},
+		Type:       flux.EventRelease,
+		Metadata: flux.ReleaseEventMetadata{
+			Release: flux.Release{
+				ID:     flux.ReleaseID("release-1"),
+				Done:   true,
+				Status: flux.ReleaseStatusSuccess,
+				Result: flux.ReleaseResult{
+					serviceID: flux.ServiceResult{
+						Status: flux.ReleaseStatusSuccess,
+						PerContainer: []flux.ContainerUpdate{
+							{Container: "helloworld", Current: before, Target: after},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	mockPlatform := &platform.MockPlatform{
+		SomeServicesAnswer: []platform.Service{
+			platform.Service{
+				ID: serviceID,
+				Containers: platform.ContainersOrExcuse{
+					Containers: []platform.Container{
+						platform.Container{
+							Name:  "helloworld",
+							Image: after.String(),
+						},
+						platform.Container{
+							Name:  "sidecar",
+							Image: "quay.io/weaveworks/sidecar:master-a000002",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	releaser, cleanup := setup(t, instance.Instance{
+		Platform:    mockPlatform,
+		EventReader: events,
+		EventWriter: events,
+	})
+	return releaser, events, cleanup
+}
+
+func TestRollback(t *testing.T) {
+	releaser, events, cleanup := setupRollback(t)
+	defer cleanup()
+
+	for _, kind := range []flux.ReleaseKind{flux.ReleaseKindPlan, flux.ReleaseKindExecute} {
+		results := flux.ReleaseResult{}
+		_, err := releaser.rollback(flux.InstanceID("instance 3"),
+			&jobs.Job{
+				ID:     jobs.JobID("rollback-" + string(kind)),
+				Method: jobs.RollbackJob,
+				Params: jobs.RollbackJobParams{
+					ReleaseID: flux.ReleaseID("release-1"),
+					Kind:      kind,
+				},
+			}, func(f string, a ...interface{}) {
+				fmt.Printf(f+"\n", a...)
+			}, func(r flux.ReleaseResult) {
+				results = r
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := results[flux.ServiceID("default/helloworld")]
+		expectedStatus := flux.ReleaseStatusPending
+		if kind == flux.ReleaseKindExecute {
+			expectedStatus = flux.ReleaseStatusSuccess
+		}
+		if result.Status != expectedStatus {
+			t.Errorf("%s: expected status %s, got %s", kind, expectedStatus, result.Status)
+		}
+		if len(result.PerContainer) != 1 {
+			t.Fatalf("%s: expected one container update, got %#v", kind, result.PerContainer)
+		}
+		update := result.PerContainer[0]
+		if update.Container != "helloworld" ||
+			update.Current.String() != "quay.io/weaveworks/helloworld:master-a000001" ||
+			update.Target.String() != "quay.io/weaveworks/helloworld:master-a000000" {
+			t.Errorf("%s: unexpected container update %#v", kind, update)
+		}
+	}
+
+	// Only the executed rollback is recorded, and is linked to the
+	// original release.
+	if len(events.events) != 2 {
+		t.Fatalf("expected one more event to be recorded, got %#v", events.events)
+	}
+	event := events.events[0]
+	if event.Type != flux.EventRollback {
+		t.Errorf("expected a rollback event, got %q", event.Type)
+	}
+	metadata := event.Metadata.(flux.ReleaseEventMetadata)
+	if metadata.Release.RollbackOf != flux.ReleaseID("release-1") {
+		t.Errorf("expected the rollback to refer to release-1, got %q", metadata.Release.RollbackOf)
+	}
+	if metadata.Release.ID != flux.ReleaseID("rollback-execute") {
+		t.Errorf("expected the rollback to have its own ID, got %q", metadata.Release.ID)
+	}
+}
+
+func TestRollbackUnknownRelease(t *testing.T) {
+	releaser, _, cleanup := setupRollback(t)
+	defer cleanup()
+
+	_, err := releaser.rollback(flux.InstanceID("instance 3"),
+		&jobs.Job{
+			Method: jobs.RollbackJob,
+			Params: jobs.RollbackJobParams{
+				ReleaseID: flux.ReleaseID("not-a-release"),
+				Kind:      flux.ReleaseKindExecute,
+			},
+		}, func(string, ...interface{}) {}, func(flux.ReleaseResult) {})
+	if err != ErrReleaseNotFound {
+		t.Errorf("expected ErrReleaseNotFound, got %v", err)
+	}
+}
+
+func TestRollbackUnindexedRelease(t *testing.T) {
+	releaser, events, cleanup := setupRollback(t)
+	defer cleanup()
+	inst, _ := releaser.instancer.Get(flux.InstanceID("instance 3"))
+	inst.EventReader = unindexedEventLog{events}
+
+	results := flux.ReleaseResult{}
+	_, err := releaser.rollback(flux.InstanceID("instance 3"),
+		&jobs.Job{
+			ID:     jobs.JobID("rollback-1"),
+			Method: jobs.RollbackJob,
+			Params: jobs.RollbackJobParams{
+				ReleaseID: flux.ReleaseID("release-1"),
+				Kind:      flux.ReleaseKindPlan,
+			},
+		}, func(f string, a ...interface{}) {
+			fmt.Printf(f+"\n", a...)
+		}, func(r flux.ReleaseResult) {
+			results = r
+		})
+	if err != nil {
+		t.Fatalf("expected release without a release ID index to be found, got %s", err)
+	}
+	if result := results[flux.ServiceID("default/helloworld")]; len(result.PerContainer) != 1 {
+		t.Errorf("expected the release to be rolled back, got %#v", result)
+	}
+}
+
+func setupAutoRollback(t *testing.T, autoRollback bool) (*Releaser, *eventLog, *[][]platform.ServiceDefinition, func()) {
+	serviceID, _ := flux.ParseServiceID("default/helloworld")
+
+	var applied [][]platform.ServiceDefinition
+	mockPlatform := &platform.MockPlatform{
+		SomeServicesAnswer: []platform.Service{
+			platform.Service{
+				ID: serviceID,
+				Containers: platform.ContainersOrExcuse{
+					Containers: []platform.Container{
+						platform.Container{
+							Name:  "helloworld",
+							Image: "quay.io/weaveworks/helloworld:master-a000001",
+						},
+					},
+				},
+			},
+		},
+		// The first apply, i.e., the release, fails; any after
+		// that succeed.
+		ApplyArgTest: func(defs []platform.ServiceDefinition) error {
+			applied = append(applied, defs)
+			if len(applied) == 1 {
+				return platform.ApplyError{
+					serviceID: errors.New("timed out waiting for rollout"),
+				}
+			}
+			return nil
+		},
+	}
+
+	imageID, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	now := time.Now()
+	mockRegistry := registry.NewMockRegistry([]flux.Image{
+		flux.Image{
+			ImageID:   imageID,
+			CreatedAt: &now,
+		},
+	}, nil)
+
+	config := instance.MakeConfig()
+	config.Services[serviceID] = instance.ServiceConfig{
+		AutoRollback: autoRollback,
+	}
+
+	events := &eventLog{}
+	releaser, cleanup := setup(t, instance.Instance{
+		Platform:    mockPlatform,
+		Registry:    mockRegistry,
+		Config:      &instance.MockConfigurer{config, nil},
+		EventReader: events,
+		EventWriter: events,
+	})
+	return releaser, events, &applied, cleanup
+}
+
+func releaseLatestToHelloworld(t *testing.T, releaser *Releaser) flux.ReleaseResult {
+	var results flux.ReleaseResult
+	_, err := releaser.release(flux.InstanceID("instance 3"),
+		&jobs.Job{
+			ID: jobs.JobID("release-1"),
+			Params: jobs.ReleaseJobParams{
+				ServiceSpec: flux.ServiceSpec("default/helloworld"),
+				ImageSpec:   flux.ImageSpecLatest,
+				Kind:        flux.ReleaseKindExecute,
+			},
+		}, func(f string, a ...interface{}) {
+			fmt.Printf(f+"\n", a...)
+		}, func(r flux.ReleaseResult) {
+			results = r
+		})
+	if err == nil {
+		t.Fatal("expected the release to report an error")
+	}
+	return results
+}
+
+func TestAutoRollback(t *testing.T) {
+	releaser, events, applied, cleanup := setupAutoRollback(t, true)
+	defer cleanup()
+
+	results := releaseLatestToHelloworld(t, releaser)
+	result := results[flux.ServiceID("default/helloworld")]
+	if result.Status != flux.ReleaseStatusRolledBack {
+		t.Errorf("expected service to be rolled back, but status was %s (%s)", result.Status, result.Error)
+	}
+
+	if len(*applied) != 2 {
+		t.Fatalf("expected the release and the rollback to be applied, got %d applies", len(*applied))
+	}
+	rollbackDefs := (*applied)[1]
+	if len(rollbackDefs) != 1 || !strings.Contains(string(rollbackDefs[0].NewDefinition), "helloworld:master-a000001") {
+		t.Errorf("expected the previous definition to be applied, got %#v", rollbackDefs)
+	}
+
+	if len(events.events) != 2 {
+		t.Fatalf("expected a release and a rollback event, got %#v", events.events)
+	}
+	rollback, release := events.events[0], events.events[1]
+	if release.Type != flux.EventRelease || release.LogLevel != flux.LogLevelError {
+		t.Errorf("expected a failed release event, got %#v", release)
+	}
+	if rollback.Type != flux.EventRollback {
+		t.Errorf("expected a rollback event, got %q", rollback.Type)
+	}
+	metadata := rollback.Metadata.(flux.ReleaseEventMetadata)
+	if metadata.Release.RollbackOf != flux.ReleaseID("release-1") {
+		t.Errorf("expected rollback to refer to release-1, got %q", metadata.Release.RollbackOf)
+	}
+}
+
+func TestNoAutoRollback(t *testing.T) {
+	releaser, events, applied, cleanup := setupAutoRollback(t, false)
+	defer cleanup()
+
+	results := releaseLatestToHelloworld(t, releaser)
+	result := results[flux.ServiceID("default/helloworld")]
+	if result.Status != flux.ReleaseStatusFailed {
+		t.Errorf("expected service release to have failed, but status was %s", result.Status)
+	}
+	if len(*applied) != 1 {
+		t.Errorf("expected only the release to be applied, got %d applies", len(*applied))
+	}
+	if len(events.events) != 1 {
+		t.Errorf("expected just the release event, got %#v", events.events)
+	}
+}
+
+func TestReleaseSkipsServiceLockedByWorkloadID(t *testing.T) {
+	releaser, events, applied, cleanup := setupAutoRollback(t, false)
+	defer cleanup()
+
+	// Lock the service as the server would, given the ID of the
+	// deployment behind it
+	inst, _ := releaser.instancer.Get(flux.InstanceID("instance 3"))
+	id, err := inst.ResolveServiceID(flux.ServiceID("default:deployment/helloworld"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _ := inst.GetConfig()
+	config.Services[id] = instance.ServiceConfig{
+		Locked: true,
+		Lock:   &flux.LockInfo{Reason: "incident 42"},
+	}
+	inst.Config = &instance.MockConfigurer{config, nil}
+
+	var results flux.ReleaseResult
+	_, err = releaser.release(flux.InstanceID("instance 3"),
+		&jobs.Job{
+			ID: jobs.JobID("release-1"),
+			Params: jobs.ReleaseJobParams{
+				ServiceSpec: flux.ServiceSpecAll,
+				ImageSpec:   flux.ImageSpecLatest,
+				Kind:        flux.ReleaseKindExecute,
+			},
+		}, func(f string, a ...interface{}) {
+			fmt.Printf(f+"\n", a...)
+		}, func(r flux.ReleaseResult) {
+			results = r
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := results[flux.ServiceID("default/helloworld")]; result.Status != flux.ReleaseStatusSkipped {
+		t.Errorf("expected locked service to be skipped, got %#v", result)
+	}
+	if len(*applied) != 0 {
+		t.Errorf("expected nothing to be applied, got %#v", *applied)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("expected no release events, got %#v", events.events)
+	}
+}
+
+// A fake GitHub, to open pull requests against, and have them merged
+// or closed
+type fakeGithub struct {
+	pull struct {
+		Head, Base, Title string
+	}
+	opened int
+	// the state of the pull request, once opened: "open", "merged"
+	// or "closed"
+	state string
+}
+
+func (g *fakeGithub) serve(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/pulls/7"):
+			state := g.state
+			if state == github.PullRequestMerged {
+				state = "closed"
+			}
+			fmt.Fprintf(w, `{"number":7,"state":%q,"merged":%v}`, state, g.state == github.PullRequestMerged)
+			return
+		case !strings.HasSuffix(r.URL.Path, "/pulls"):
+		case r.Method == "GET":
+			if g.opened > 0 && g.state == github.PullRequestOpen && strings.HasSuffix(r.URL.Query().Get("head"), ":"+g.pull.Head) {
+				fmt.Fprint(w, `[{"number":7,"html_url":"https://github.com/o/r/pull/7"}]`)
+			} else {
+				fmt.Fprint(w, `[]`)
+			}
+			return
+		case r.Method == "POST":
+			if err := json.NewDecoder(r.Body).Decode(&g.pull); err != nil {
+				t.Error(err)
+			}
+			g.opened++
+			g.state = github.PullRequestOpen
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"number":7,"html_url":"https://github.com/o/r/pull/7"}`)
+			return
+		}
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		http.NotFound(w, r)
+	})
+	return httptest.NewServer(mux)
+}
+
+// setupPullRequest sets up a release, as for setupAutoRollback, to be
+// proposed as a pull request to the fake GitHub returned.
+func setupPullRequest(t *testing.T) (*Releaser, *eventLog, *[][]platform.ServiceDefinition, *fakeGithub, func()) {
+	gh := &fakeGithub{}
+	server := gh.serve(t)
+	releaser, events, applied, cleanup := setupAutoRollback(t, false)
+	inst, _ := releaser.instancer.Get(flux.InstanceID("instance 3"))
+	config, _ := inst.GetConfig()
+	config.Settings.Git.ReleaseMode = flux.ReleaseModePullRequest
+	config.Settings.Github = flux.GithubConfig{Token: "secret", APIURL: server.URL}
+	inst.Config = &instance.MockConfigurer{config, nil}
+	return releaser, events, applied, gh, func() {
+		server.Close()
+		cleanup()
+	}
+}
+
+func releaseToPullRequest(t *testing.T, releaser *Releaser, id jobs.JobID) (flux.ReleaseResult, []jobs.Job) {
+	var results flux.ReleaseResult
+	moreJobs, err := releaser.release(flux.InstanceID("instance 3"),
+		&jobs.Job{
+			ID: id,
+			Params: jobs.ReleaseJobParams{
+				ServiceSpec: flux.ServiceSpec("default/helloworld"),
+				ImageSpec:   flux.ImageSpecLatest,
+				Kind:        flux.ReleaseKindExecute,
+			},
+		}, func(f string, a ...interface{}) {
+			fmt.Printf(f+"\n", a...)
+		}, func(r flux.ReleaseResult) {
+			results = r
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results, moreJobs
+}
+
+func runMergeJob(releaser *Releaser, job jobs.Job) (flux.ReleaseResult, []jobs.Job, error) {
+	var results flux.ReleaseResult
+	job.Instance = flux.InstanceID("instance 3")
+	moreJobs, err := releaser.merge(job.Instance, &job, func(f string, a ...interface{}) {
+		fmt.Printf(f+"\n", a...)
+	}, func(r flux.ReleaseResult) {
+		results = r
+	})
+	return results, moreJobs, err
+}
+
+func TestReleasePullRequest(t *testing.T) {
+	releaser, events, applied, gh, cleanup := setupPullRequest(t)
+	defer cleanup()
+	inst, _ := releaser.instancer.Get(flux.InstanceID("instance 3"))
+
+	results, moreJobs := releaseToPullRequest(t, releaser, jobs.JobID("release-1"))
+
+	if len(*applied) != 0 {
+		t.Errorf("expected nothing to be applied before the pull request is merged, got %#v", *applied)
+	}
+	if result := results[flux.ServiceID("default/helloworld")]; result.Status != flux.ReleaseStatusAwaitingMerge {
+		t.Errorf("expected service to be awaiting merge, got %#v", result)
+	}
+	if gh.pull.Base != "master" || !strings.HasPrefix(gh.pull.Head, "flux-release-") || gh.pull.Title == "" {
+		t.Errorf("unexpected pull request %#v", gh.pull)
+	}
+	if err := execCommand("git", "-C", inst.ConfigRepo().URL, "rev-parse", "--verify", "refs/heads/"+gh.pull.Head); err != nil {
+		t.Errorf("expected branch %q to have been pushed: %s", gh.pull.Head, err)
+	}
+
+	if len(events.events) != 1 {
+		t.Fatalf("expected a release event, got %#v", events.events)
+	}
+	recorded := events.events[0].Metadata.(flux.ReleaseEventMetadata).Release
+	if recorded.Status != flux.ReleaseStatusAwaitingMerge || recorded.PullRequestURL != "https://github.com/o/r/pull/7" {
+		t.Errorf("expected release awaiting merge of the pull request, got %#v", recorded)
+	}
+
+	if len(moreJobs) != 1 || moreJobs[0].Method != jobs.MergeJob {
+		t.Fatalf("expected a job to wait for the pull request to be merged, got %#v", moreJobs)
+	}
+	if params := moreJobs[0].Params.(jobs.MergeJobParams); params.ReleaseID != flux.ReleaseID("release-1") {
+		t.Errorf("expected the merge job to be for release-1, got %q", params.ReleaseID)
+	}
+	if !moreJobs[0].ScheduledAt.After(time.Now()) {
+		t.Errorf("expected the merge job to be scheduled for later, got %s", moreJobs[0].ScheduledAt)
+	}
+
+	// Releasing the same again, as automation does until the pull
+	// request is merged, finds the pull request already open
+	results, moreJobs = releaseToPullRequest(t, releaser, jobs.JobID("release-2"))
+	if gh.opened != 1 {
+		t.Errorf("expected the pull request to be opened only once, got %d", gh.opened)
+	}
+	if result := results[flux.ServiceID("default/helloworld")]; result.Status != flux.ReleaseStatusAwaitingMerge {
+		t.Errorf("expected service to be awaiting merge still, got %#v", result)
+	}
+	if len(events.events) != 1 {
+		t.Errorf("expected no more release events, got %#v", events.events[1:])
+	}
+	if len(moreJobs) != 0 {
+		t.Errorf("expected the first release to be left to wait for the merge, got %#v", moreJobs)
+	}
+}
+
+func TestMergeAppliesRelease(t *testing.T) {
+	releaser, events, applied, gh, cleanup := setupPullRequest(t)
+	defer cleanup()
+	inst, _ := releaser.instancer.Get(flux.InstanceID("instance 3"))
+
+	_, moreJobs := releaseToPullRequest(t, releaser, jobs.JobID("release-1"))
+	if len(moreJobs) != 1 {
+		t.Fatalf("expected a merge job, got %#v", moreJobs)
+	}
+	merge := moreJobs[0]
+
+	// While the pull request is open, nothing is applied, and it's
+	// looked at again later
+	_, moreJobs, err := runMergeJob(releaser, merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moreJobs) != 1 || moreJobs[0].Key != merge.Key {
+		t.Errorf("expected the merge job to be run again, got %#v", moreJobs)
+	}
+	if len(*applied) != 0 || len(events.events) != 1 {
+		t.Errorf("expected nothing to happen while the pull request is open, got %d applies and events %#v", len(*applied), events.events)
+	}
+
+	// Merge the pull request
+	if err := execCommand("git", "-C", inst.ConfigRepo().URL, "update-ref", "refs/heads/master", "refs/heads/"+gh.pull.Head); err != nil {
+		t.Fatal(err)
+	}
+	gh.state = github.PullRequestMerged
+
+	// The merged release fails to apply, as set up, and isn't rolled
+	// back; what matters here is that it's applied, and recorded as
+	// the release that proposed it.
+	results, moreJobs, err := runMergeJob(releaser, merge)
+	if err == nil {
+		t.Error("expected the merged release to fail to apply")
+	}
+	if len(moreJobs) != 0 {
+		t.Errorf("expected no more jobs once the pull request is merged, got %#v", moreJobs)
+	}
+	if len(*applied) != 1 {
+		t.Fatalf("expected the merged release to be applied, got %d applies", len(*applied))
+	}
+	if defs := (*applied)[0]; len(defs) != 1 || !strings.Contains(string(defs[0].NewDefinition), "helloworld:master-a000002") {
+		t.Errorf("expected the merged definition to be applied, got %#v", defs)
+	}
+	if result := results[flux.ServiceID("default/helloworld")]; result.Status != flux.ReleaseStatusFailed {
+		t.Errorf("expected service release to have failed, got %#v", result)
+	}
+	if len(events.events) != 2 {
+		t.Fatalf("expected the outcome to be recorded, got %#v", events.events)
+	}
+	recorded := events.events[0].Metadata.(flux.ReleaseEventMetadata).Release
+	if recorded.ID != flux.ReleaseID("release-1") || recorded.Status != flux.ReleaseStatusFailed || recorded.PullRequestURL == "" {
+		t.Errorf("expected the outcome to be recorded against release-1, got %#v", recorded)
+	}
+
+	// Once the outcome is recorded, there's nothing more to do
+	if _, moreJobs, err = runMergeJob(releaser, merge); err != nil || len(moreJobs) != 0 {
+		t.Errorf("expected the merge job to do nothing once the release is done, got %#v, %v", moreJobs, err)
+	}
+	if len(*applied) != 1 || len(events.events) != 2 {
+		t.Errorf("expected nothing more to be applied or recorded, got %d applies and %d events", len(*applied), len(events.events))
+	}
+}
+
+func TestMergeClosedPullRequest(t *testing.T) {
+	releaser, events, applied, gh, cleanup := setupPullRequest(t)
+	defer cleanup()
+
+	_, moreJobs := releaseToPullRequest(t, releaser, jobs.JobID("release-1"))
+	if len(moreJobs) != 1 {
+		t.Fatalf("expected a merge job, got %#v", moreJobs)
+	}
+	gh.state = github.PullRequestClosed
+
+	results, moreJobs, err := runMergeJob(releaser, moreJobs[0])
+	if err == nil {
+		t.Error("expected the release to fail, as the pull request was closed")
+	}
+	if len(moreJobs) != 0 || len(*applied) != 0 {
+		t.Errorf("expected nothing more to be done, got jobs %#v and %d applies", moreJobs, len(*applied))
+	}
+	if result := results[flux.ServiceID("default/helloworld")]; result.Status != flux.ReleaseStatusSkipped {
+		t.Errorf("expected service to be skipped, got %#v", result)
+	}
+	if len(events.events) != 2 {
+		t.Fatalf("expected the outcome to be recorded, got %#v", events.events)
+	}
+	if event := events.events[0]; event.LogLevel != flux.LogLevelError {
+		t.Errorf("expected the release to be recorded as failed, got %#v", event)
+	}
+	recorded := events.events[0].Metadata.(flux.ReleaseEventMetadata).Release
+	if recorded.ID != flux.ReleaseID("release-1") || recorded.Status != flux.ReleaseStatusFailed {
+		t.Errorf("expected release-1 to be recorded as failed, got %#v", recorded)
+	}
+}
+
+func TestReleaseGithubDeployment(t *testing.T) {
+	// A fake GitHub, to record deployments with
+	var deployment struct {
+		Ref, Environment string
+	}
+	var deploymentStates, commitStates []string
+	var commitStatusPath string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var status struct {
+			Ref, Environment string
+			State, Context   string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		switch path := r.URL.Path; {
+		case strings.HasSuffix(path, "/deployments"):
+			deployment.Ref, deployment.Environment = status.Ref, status.Environment
+			fmt.Fprint(w, `{"id":3}`)
+		case strings.HasSuffix(path, "/deployments/3/statuses"):
+			deploymentStates = append(deploymentStates, status.State)
+			fmt.Fprint(w, `{"id":1}`)
+		case strings.Contains(path, "/statuses/"):
+			if status.Context != "flux/staging" {
+				t.Errorf("expected commit status context flux/staging, got %q", status.Context)
+			}
+			commitStatusPath = path
+			commitStates = append(commitStates, status.State)
+			fmt.Fprint(w, `{"id":1}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The release fails to apply, and isn't rolled back
+	releaser, _, _, cleanup := setupAutoRollback(t, false)
+	defer cleanup()
+	inst, _ := releaser.instancer.Get(flux.InstanceID("instance 3"))
+	config, _ := inst.GetConfig()
+	config.Settings.Github = flux.GithubConfig{Token: "secret", APIURL: server.URL, Deployments: true, Environment: "staging"}
+	inst.Config = &instance.MockConfigurer{config, nil}
+	owner, name, _ := github.ParseRepoURL(inst.ConfigRepo().URL)
+
+	_, err := releaser.release(flux.InstanceID("instance 3"),
+		&jobs.Job{
+			ID: jobs.JobID("release-1"),
+			Params: jobs.ReleaseJobParams{
+				ServiceSpec: flux.ServiceSpec("default/helloworld"),
+				ImageSpec:   flux.ImageSpecLatest,
+				Kind:        flux.ReleaseKindExecute,
+			},
+		}, func(f string, a ...interface{}) {
+			fmt.Printf(f+"\n", a...)
+		}, func(flux.ReleaseResult) {})
+	if err == nil {
+		t.Fatal("expected the release to fail to apply")
+	}
+
+	if deployment.Environment != "staging" {
+		t.Fatalf("expected a deployment to staging, got %#v", deployment)
+	}
+	head, err := exec.Command("git", "-C", inst.ConfigRepo().URL, "rev-parse", "refs/heads/master").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Ref != strings.TrimSpace(string(head)) {
+		t.Errorf("expected the commit pushed, %s, to be deployed, got %s", head, deployment.Ref)
+	}
+	if commitStatusPath != fmt.Sprintf("/repos/%s/%s/statuses/%s", owner, name, deployment.Ref) {
+		t.Errorf("expected commit status for %s, got %s", deployment.Ref, commitStatusPath)
+	}
+	expected := []string{"pending", "failure"}
+	if !reflect.DeepEqual(deploymentStates, expected) || !reflect.DeepEqual(commitStates, expected) {
+		t.Errorf("expected states %v, got %v for the deployment and %v for the commit", expected, deploymentStates, commitStates)
+	}
+}
+
+func TestAddTrailers(t *testing.T) {
+	for _, c := range []struct {
+		cause    flux.Cause
+		expected string
+	}{
+		{flux.Cause{}, "Release latest images to default/helloworld"},
+		{flux.Cause{User: "alice"}, "Release latest images to default/helloworld\n\nReleased-by: alice"},
+		{flux.Cause{User: "alice\nSigned-off-by: mallory"}, "Release latest images to default/helloworld\n\nReleased-by: alice Signed-off-by: mallory"},
+		{flux.Cause{User: "alice", Message: "Fixes the memory leak\n"}, "Release latest images to default/helloworld\n\nFixes the memory leak\n\nReleased-by: alice"},
+	} {
+		msg := addTrailers("Release latest images to default/helloworld", flux.ReleaseSpec{Cause: c.cause})
+		if msg != c.expected {
+			t.Errorf("for cause %#v, expected commit message %q, got %q", c.cause, c.expected, msg)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/jobs"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+const syncCycle = 60 * time.Second
+
+// Syncer keeps the cluster in line with the repo: periodically, it
+// applies the definition of any service that's running something
+// other than what's defined for it, e.g., because the repo was edited
+// directly, or the service was edited in the cluster.
+type Syncer struct {
+	instancer  instance.Instancer
+	instanceDB instance.DB
+	jobs       jobs.JobReadPusher
+	logger     log.Logger
+}
+
+func NewSyncer(
+	instancer instance.Instancer,
+	instanceDB instance.DB,
+	jobs jobs.JobReadPusher,
+	logger log.Logger,
+) *Syncer {
+	return &Syncer{
+		instancer:  instancer,
+		instanceDB: instanceDB,
+		jobs:       jobs,
+		logger:     logger,
+	}
+}
+
+// Start queues a sync job for each instance that has a repo to sync
+// from and isn't paused, every cycle.
+func (s *Syncer) Start() {
+	s.queueAll()
+	tick := time.Tick(syncCycle)
+	for range tick {
+		s.queueAll()
+	}
+}
+
+func (s *Syncer) queueAll() {
+	insts, err := s.instanceDB.All()
+	if err != nil {
+		s.logger.Log("err", err)
+		return
+	}
+	for _, inst := range insts {
+		if inst.Config.SyncPaused || inst.Config.Settings.Git.URL == "" {
+			continue
+		}
+		_, err := s.jobs.PutJob(inst.ID, syncJob(inst.ID))
+		if err != nil && err != jobs.ErrJobAlreadyQueued {
+			s.logger.Log("err", errors.Wrap(err, "queueing sync job"))
+		}
+	}
+}
+
+func syncJob(instanceID flux.InstanceID) jobs.Job {
+	return jobs.Job{
+		// Syncs go on the release queue, which runs one job at a
+		// time for each instance, so that a sync can't see a
+		// release applied after it cloned the repo, and undo it.
+		Queue: jobs.ReleaseJob,
+		// Key stops us getting two jobs for the same instance
+		Key: strings.Join([]string{
+			jobs.SyncJob,
+			string(instanceID),
+		}, "|"),
+		Method:   jobs.SyncJob,
+		Priority: jobs.PriorityBackground,
+		Params: jobs.SyncJobParams{
+			InstanceID: instanceID,
+		},
+	}
+}
+
+func (s *Syncer) Handle(job *jobs.Job, updater jobs.JobUpdater) ([]jobs.Job, error) {
+	logStatus := func(format string, args ...interface{}) {
+		status := fmt.Sprintf(format, args...)
+		job.Status = status
+		job.Log = append(job.Log, status)
+		updater.UpdateJob(*job)
+	}
+	results := flux.ReleaseResult{}
+	err := s.sync(job.Instance, results, logStatus)
+	job.Result = results
+	updater.UpdateJob(*job)
+	return nil, err
+}
+
+func (s *Syncer) sync(instanceID flux.InstanceID, results flux.ReleaseResult, logStatus statusFn) (err error) {
+	defer func(started time.Time) {
+		syncDuration.With(fluxmetrics.LabelSuccess, fmt.Sprint(err == nil)).Observe(time.Since(started).Seconds())
+	}(time.Now())
+
+	inst, err := s.instancer.Get(instanceID)
+	if err != nil {
+		return errors.Wrap(err, "getting job instance")
+	}
+	config, err := inst.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "getting instance config")
+	}
+	// It may have been paused since the job was queued.
+	if config.SyncPaused {
+		logStatus("Sync is paused; nothing to do.")
+		return nil
+	}
+
+	rc := NewReleaseContext(inst)
+	logStatus("Cloning the config repo.")
+	if err = rc.CloneRepo(); err != nil {
+		return errors.Wrap(err, "cloning repo")
+	}
+	defer rc.Clean()
+
+	candidates, err := rc.SelectServices(nil, LockedServices(config), flux.ServiceIDSet{}, results, logStatus)
+	if err != nil {
+		return errors.Wrap(err, "finding services")
+	}
+	updates := outOfSync(candidates, results, logStatus)
+	// A freeze stops changes pushed to the repo during it getting
+	// applied, as well as releases.
+	updates = SkipFrozen(config.Settings.Freezes, false, time.Now(), updates, results, logStatus)
+	if len(updates) == 0 {
+		logStatus("All services are in sync with the repo.")
+		return nil
+	}
+
+	logStatus("Applying %d definition(s).", len(updates))
+	started := time.Now().UTC()
+	applied := flux.ReleaseResult{}
+	applyErr := applyChanges(inst, updates, applied)
+	for id, result := range applied {
+		results[id] = result
+	}
+	return logSyncEvent(inst, applyErr, applied, started)
+}
+
+// outOfSync picks out the services that are running something other
+// than what's defined for them. The others are dropped from the
+// results, since there's nothing to do for them.
+func outOfSync(candidates []*ServiceUpdate, results flux.ReleaseResult, logStatus statusFn) []*ServiceUpdate {
+	var updates []*ServiceUpdate
+	for _, update := range candidates {
+		drift := serviceDrift(update)
+		if drift.Error != "" {
+			logStatus("Skipping service %s as its definition could not be read: %s", update.ServiceID, drift.Error)
+			results[update.ServiceID] = flux.ServiceResult{
+				Status: flux.ReleaseStatusSkipped,
+				Error:  drift.Error,
+			}
+			continue
+		}
+		if !needsSync(drift) {
+			delete(results, update.ServiceID)
+			continue
+		}
+		logStatus("Service %s differs from its definition.", update.ServiceID)
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// logSyncEvent records the services applied in the history. It
+// returns the error from applying, if there was one, otherwise the
+// result of logging.
+func logSyncEvent(inst *instance.Instance, applyErr error, applied flux.ReleaseResult, started time.Time) error {
+	errorMessage := ""
+	logLevel := flux.LogLevelInfo
+	if applyErr != nil {
+		errorMessage = applyErr.Error()
+		logLevel = flux.LogLevelError
+	}
+	var serviceIDs []flux.ServiceID
+	for _, id := range applied.ServiceIDs() {
+		serviceIDs = append(serviceIDs, flux.ServiceID(id))
+	}
+	err := inst.LogEvent(flux.Event{
+		ServiceIDs: serviceIDs,
+		Type:       flux.EventSync,
+		StartedAt:  started,
+		EndedAt:    time.Now().UTC(),
+		LogLevel:   logLevel,
+		Metadata: flux.SyncEventMetadata{
+			Result: applied,
+			Error:  errorMessage,
+		},
+	})
+	if applyErr != nil {
+		return applyErr
+	}
+	return errors.Wrap(err, "logging event")
+}
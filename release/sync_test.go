@@ -0,0 +1,193 @@
+package release
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+	"github.com/weaveworks/flux/jobs"
+	"github.com/weaveworks/flux/platform"
+)
+
+func setupSync(t *testing.T, helloworldImage string, config instance.Config) (*Syncer, *eventLog, *[][]platform.ServiceDefinition, func()) {
+	serviceID, _ := flux.ParseServiceID("default/helloworld")
+
+	var applied [][]platform.ServiceDefinition
+	mockPlatform := &platform.MockPlatform{
+		SomeServicesAnswer: []platform.Service{
+			platform.Service{
+				ID: serviceID,
+				Containers: platform.ContainersOrExcuse{
+					Containers: []platform.Container{
+						{Name: "helloworld", Image: helloworldImage},
+						{Name: "sidecar", Image: "quay.io/weaveworks/sidecar:master-a000002"},
+					},
+				},
+			},
+		},
+		ApplyArgTest: func(defs []platform.ServiceDefinition) error {
+			applied = append(applied, defs)
+			return nil
+		},
+	}
+
+	events := &eventLog{}
+	releaser, cleanup := setup(t, instance.Instance{
+		Platform:    mockPlatform,
+		Config:      &instance.MockConfigurer{config, nil},
+		EventReader: events,
+		EventWriter: events,
+	})
+	syncer := NewSyncer(releaser.instancer, nil, nil, log.NewNopLogger())
+	return syncer, events, &applied, cleanup
+}
+
+func runSync(t *testing.T, syncer *Syncer) flux.ReleaseResult {
+	results := flux.ReleaseResult{}
+	if err := syncer.sync(flux.InstanceID("instance 3"), results, func(string, ...interface{}) {}); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestSyncAppliesChangedServices(t *testing.T) {
+	syncer, events, applied, cleanup := setupSync(t, "quay.io/weaveworks/helloworld:master-a000000", instance.MakeConfig())
+	defer cleanup()
+
+	results := runSync(t, syncer)
+	if len(*applied) != 1 || len((*applied)[0]) != 1 || (*applied)[0][0].ServiceID != "default/helloworld" {
+		t.Fatalf("expected the definition of default/helloworld to be applied, got %#v", *applied)
+	}
+	if result := results["default/helloworld"]; result.Status != flux.ReleaseStatusSuccess {
+		t.Errorf("expected service to be synced, got %#v", result)
+	}
+
+	if len(events.events) != 1 {
+		t.Fatalf("expected a sync event, got %#v", events.events)
+	}
+	event := events.events[0]
+	if event.Type != flux.EventSync || len(event.ServiceIDs) != 1 || event.ServiceIDs[0] != "default/helloworld" {
+		t.Errorf("expected a sync event for default/helloworld, got %#v", event)
+	}
+	if _, ok := event.Metadata.(flux.SyncEventMetadata); !ok {
+		t.Errorf("expected sync event metadata, got %#v", event.Metadata)
+	}
+}
+
+func TestSyncLeavesServicesInSync(t *testing.T) {
+	syncer, events, applied, cleanup := setupSync(t, "quay.io/weaveworks/helloworld:master-a000001", instance.MakeConfig())
+	defer cleanup()
+
+	results := runSync(t, syncer)
+	if len(*applied) != 0 {
+		t.Errorf("expected nothing to be applied, got %#v", *applied)
+	}
+	if _, ok := results["default/helloworld"]; ok {
+		t.Errorf("expected service in sync to be left out of results, got %#v", results)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("expected no events, got %#v", events.events)
+	}
+}
+
+func TestSyncLeavesScaledServices(t *testing.T) {
+	syncer, events, applied, cleanup := setupSync(t, "quay.io/weaveworks/helloworld:master-a000001", instance.MakeConfig())
+	defer cleanup()
+	// The service is running what's defined for it, but has been
+	// scaled, e.g., by an autoscaler
+	inst, _ := syncer.instancer.Get(flux.InstanceID("instance 3"))
+	mockPlatform := inst.Platform.(*platform.MockPlatform)
+	replicas := int32(2)
+	mockPlatform.SomeServicesAnswer[0].Spec = &platform.ControllerSpec{
+		Replicas: &replicas,
+		Containers: []platform.ContainerSpec{
+			{Name: "helloworld", Image: "quay.io/weaveworks/helloworld:master-a000001"},
+			{Name: "sidecar", Image: "quay.io/weaveworks/sidecar:master-a000002"},
+		},
+	}
+
+	results := runSync(t, syncer)
+	if len(*applied) != 0 {
+		t.Errorf("expected a service differing only in replicas not to be applied, got %#v", *applied)
+	}
+	if _, ok := results["default/helloworld"]; ok {
+		t.Errorf("expected scaled service to be left out of results, got %#v", results)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("expected no events, got %#v", events.events)
+	}
+}
+
+func TestSyncSkipsLockedServices(t *testing.T) {
+	config := instance.MakeConfig()
+	config.Services["default/helloworld"] = instance.ServiceConfig{
+		Locked: true,
+		Lock:   &flux.LockInfo{Reason: "incident 42", User: "alice"},
+	}
+	syncer, events, applied, cleanup := setupSync(t, "quay.io/weaveworks/helloworld:master-a000000", config)
+	defer cleanup()
+
+	results := runSync(t, syncer)
+	if len(*applied) != 0 {
+		t.Errorf("expected locked service not to be applied, got %#v", *applied)
+	}
+	if result := results["default/helloworld"]; result.Status != flux.ReleaseStatusSkipped {
+		t.Errorf("expected locked service to be skipped, got %#v", result)
+	} else if result.Error != "locked (incident 42, by alice)" {
+		t.Errorf("expected the lock's details in the result, got %q", result.Error)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("expected no events, got %#v", events.events)
+	}
+}
+
+func TestSyncSkipsFrozenServices(t *testing.T) {
+	now := time.Now().UTC()
+	config := instance.MakeConfig()
+	config.Settings.Freezes = flux.FreezeWindows{{
+		Name: "incident 42",
+		From: now.Add(-time.Hour).Format(time.RFC3339),
+		To:   now.Add(time.Hour).Format(time.RFC3339),
+	}}
+	syncer, events, applied, cleanup := setupSync(t, "quay.io/weaveworks/helloworld:master-a000000", config)
+	defer cleanup()
+
+	results := runSync(t, syncer)
+	if len(*applied) != 0 {
+		t.Errorf("expected frozen service not to be applied, got %#v", *applied)
+	}
+	if result := results["default/helloworld"]; result.Status != flux.ReleaseStatusSkipped || !strings.HasPrefix(result.Error, "frozen (") {
+		t.Errorf("expected frozen service to be skipped, got %#v", result)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("expected no events, got %#v", events.events)
+	}
+}
+
+func TestSyncPaused(t *testing.T) {
+	config := instance.MakeConfig()
+	config.SyncPaused = true
+	syncer, _, applied, cleanup := setupSync(t, "quay.io/weaveworks/helloworld:master-a000000", config)
+	defer cleanup()
+
+	runSync(t, syncer)
+	if len(*applied) != 0 {
+		t.Errorf("expected nothing to be applied while paused, got %#v", *applied)
+	}
+}
+
+func TestSyncJobQueuedWithReleases(t *testing.T) {
+	// So that a sync never runs at the same time as a release to the
+	// same instance
+	job := syncJob(flux.InstanceID("instance 3"))
+	if job.Queue != jobs.ReleaseJob {
+		t.Errorf("expected sync job on the release queue, got %q", job.Queue)
+	}
+	if other := syncJob(flux.InstanceID("instance 4")); job.Key == "" || job.Key == other.Key {
+		t.Errorf("expected sync jobs keyed per instance, got %q and %q", job.Key, other.Key)
+	}
+}
@@ -0,0 +1,248 @@
+package semver
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidRange = errors.New("invalid version range")
+
+// Range is a set of constraints on versions, written in the style of
+// npm or Cargo, for example:
+//
+//	~1.4           >=1.4.0 <1.5.0
+//	^1.4           >=1.4.0 <2.0.0
+//	1.4.x          >=1.4.0 <1.5.0
+//	>=1.2.0 <2     (both must hold)
+//	~1.4 || ~1.6   (either may hold)
+//
+// Versions with a prerelease (e.g., "1.4.0-rc.1") only satisfy a range
+// if one of the constraints mentions a prerelease of the same
+// MAJOR.MINOR.PATCH; otherwise, ranges would tend to let in
+// prereleases unexpectedly.
+type Range struct {
+	alternatives [][]comparator
+}
+
+type operator string
+
+const (
+	opEQ operator = "="
+	opGT operator = ">"
+	opGE operator = ">="
+	opLT operator = "<"
+	opLE operator = "<="
+)
+
+type comparator struct {
+	op      operator
+	version Version
+}
+
+func (c comparator) satisfiedBy(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case opEQ:
+		return cmp == 0
+	case opGT:
+		return cmp > 0
+	case opGE:
+		return cmp >= 0
+	case opLT:
+		return cmp < 0
+	case opLE:
+		return cmp <= 0
+	}
+	return false
+}
+
+// ParseRange parses a version range; see Range for the syntax.
+func ParseRange(s string) (Range, error) {
+	var r Range
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.Fields(alt)
+		if len(fields) == 0 {
+			return Range{}, errors.Wrapf(ErrInvalidRange, "empty constraint in %q", s)
+		}
+		var set []comparator
+		for _, field := range fields {
+			cs, err := parseConstraint(field)
+			if err != nil {
+				return Range{}, errors.Wrapf(err, "parsing %q", s)
+			}
+			set = append(set, cs...)
+		}
+		r.alternatives = append(r.alternatives, set)
+	}
+	return r, nil
+}
+
+// Contains says whether the version given satisfies the range.
+func (r Range) Contains(v Version) bool {
+	for _, set := range r.alternatives {
+		if satisfiesAll(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func satisfiesAll(set []comparator, v Version) bool {
+	for _, c := range set {
+		if !c.satisfiedBy(v) {
+			return false
+		}
+	}
+	if !v.IsPrerelease() {
+		return true
+	}
+	for _, c := range set {
+		if c.version.IsPrerelease() &&
+			c.version.Major == v.Major && c.version.Minor == v.Minor && c.version.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConstraint turns a single constraint (e.g., "~1.4") into the
+// comparators it stands for.
+func parseConstraint(s string) ([]comparator, error) {
+	any := []comparator{{opGE, Version{}}}
+	switch {
+	case strings.HasPrefix(s, "~"):
+		p, err := parsePartial(s[1:])
+		if err != nil {
+			return nil, err
+		}
+		if p.parts == 0 {
+			return any, nil
+		}
+		// ~1 := >=1.0.0 <2.0.0, ~1.4 and ~1.4.2 := <1.5.0
+		upper := Version{Major: p.version.Major + 1}
+		if p.parts > 1 {
+			upper = Version{Major: p.version.Major, Minor: p.version.Minor + 1}
+		}
+		return []comparator{{opGE, p.version}, {opLT, upper}}, nil
+	case strings.HasPrefix(s, "^"):
+		p, err := parsePartial(s[1:])
+		if err != nil {
+			return nil, err
+		}
+		if p.parts == 0 {
+			return any, nil
+		}
+		// Allow changes that don't modify the left-most non-zero part
+		var upper Version
+		switch {
+		case p.version.Major > 0 || p.parts == 1:
+			upper = Version{Major: p.version.Major + 1}
+		case p.version.Minor > 0 || p.parts == 2:
+			upper = Version{Minor: p.version.Minor + 1}
+		default:
+			upper = Version{Patch: p.version.Patch + 1}
+		}
+		return []comparator{{opGE, p.version}, {opLT, upper}}, nil
+	}
+
+	for _, op := range []operator{opGE, opLE, opGT, opLT, opEQ} {
+		if strings.HasPrefix(s, string(op)) {
+			p, err := parsePartial(s[len(op):])
+			if err != nil {
+				return nil, err
+			}
+			switch p.parts {
+			case 3:
+				return []comparator{{op, p.version}}, nil
+			case 0:
+				return nil, errors.Wrapf(ErrInvalidRange, "expected a version after %s in %q", op, s)
+			}
+			// A partial version stands for the range of versions
+			// it prefixes, e.g., <=1.4 is <1.5.0 and >1.4 is >=1.5.0
+			lower, upper := p.bounds()
+			switch op {
+			case opGE:
+				return []comparator{{opGE, lower}}, nil
+			case opGT:
+				return []comparator{{opGE, upper}}, nil
+			case opLE:
+				return []comparator{{opLT, upper}}, nil
+			case opLT:
+				return []comparator{{opLT, lower}}, nil
+			}
+			return []comparator{{opGE, lower}, {opLT, upper}}, nil
+		}
+	}
+
+	// A bare version, possibly partial or with wildcards, e.g., 1.4.x
+	p, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	switch p.parts {
+	case 3:
+		return []comparator{{opEQ, p.version}}, nil
+	case 0:
+		return any, nil
+	}
+	lower, upper := p.bounds()
+	return []comparator{{opGE, lower}, {opLT, upper}}, nil
+}
+
+// partial is a version which may have fewer than three parts, or
+// wildcards ("x", "X" or "*") in place of the trailing parts.
+type partial struct {
+	version Version
+	parts   int // how many of major, minor, patch were given
+}
+
+func (p partial) bounds() (lower, upper Version) {
+	lower = p.version
+	switch p.parts {
+	case 1:
+		upper = Version{Major: p.version.Major + 1}
+	case 2:
+		upper = Version{Major: p.version.Major, Minor: p.version.Minor + 1}
+	}
+	return lower, upper
+}
+
+func parsePartial(s string) (partial, error) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return partial{}, errors.Wrap(ErrInvalidRange, "missing version")
+	}
+	if v, err := Parse(s); err == nil {
+		return partial{version: v, parts: 3}, nil
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return partial{}, errors.Wrapf(ErrInvalidRange, "invalid version %q", s)
+	}
+	var p partial
+	nums := []*uint64{&p.version.Major, &p.version.Minor, &p.version.Patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			// everything after a wildcard is a wildcard too
+			for _, rest := range parts[i+1:] {
+				if rest != "x" && rest != "X" && rest != "*" {
+					return partial{}, errors.Wrapf(ErrInvalidRange, "invalid version %q", s)
+				}
+			}
+			break
+		}
+		n, err := parseNumber(part)
+		if err != nil || part == "" {
+			return partial{}, errors.Wrapf(ErrInvalidRange, "invalid version %q", s)
+		}
+		*nums[i] = n
+		p.parts++
+	}
+	if p.parts == 3 {
+		// all three parts given, so it ought to have parsed as a
+		// version, above
+		return partial{}, errors.Wrapf(ErrInvalidRange, "invalid version %q", s)
+	}
+	return p, nil
+}
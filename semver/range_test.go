@@ -0,0 +1,69 @@
+package semver
+
+import (
+	"testing"
+)
+
+func TestRangeContains(t *testing.T) {
+	for _, x := range []struct {
+		rng     string
+		in, out []string
+	}{
+		{"~1.4", []string{"1.4.0", "v1.4.9"}, []string{"1.3.9", "1.5.0", "2.0.0", "1.4.1-rc.1"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.10"}, []string{"1.4.1", "1.5.0"}},
+		{"~1", []string{"1.0.0", "1.9.9"}, []string{"0.9.0", "2.0.0"}},
+		{"^1.4", []string{"1.4.0", "1.99.0"}, []string{"1.3.0", "2.0.0"}},
+		{"^0.4", []string{"0.4.0", "0.4.7"}, []string{"0.5.0", "1.0.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"1.4.x", []string{"1.4.0", "1.4.3"}, []string{"1.5.0"}},
+		{"1.*", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"*", []string{"0.0.1", "10.0.0"}, []string{"1.0.0-rc.1"}},
+		{"1.2.3", []string{"1.2.3", "v1.2.3"}, []string{"1.2.4"}},
+		{">=1.2.0 <2", []string{"1.2.0", "1.9.0"}, []string{"1.1.9", "2.0.0"}},
+		{">1.4", []string{"1.5.0"}, []string{"1.4.9"}},
+		{"<=1.4", []string{"1.4.9", "1.0.0"}, []string{"1.5.0"}},
+		{"~1.4 || ~1.6", []string{"1.4.1", "1.6.2"}, []string{"1.5.0"}},
+		{">=1.4.0-rc.1 <1.5.0", []string{"1.4.0-rc.2", "1.4.0", "1.4.3"}, []string{"1.4.1-rc.1", "1.4.0-beta"}},
+	} {
+		r, err := ParseRange(x.rng)
+		if err != nil {
+			t.Fatalf("Error parsing range %q: %s", x.rng, err)
+		}
+		for _, s := range x.in {
+			v, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.Contains(v) {
+				t.Errorf("Expected %q to contain %s", x.rng, s)
+			}
+		}
+		for _, s := range x.out {
+			v, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Contains(v) {
+				t.Errorf("Expected %q not to contain %s", x.rng, s)
+			}
+		}
+	}
+}
+
+func TestParseRangeErrorCases(t *testing.T) {
+	for _, test := range []string{
+		"",
+		"~",
+		"^",
+		">=",
+		"master-*",
+		"1.x.3",
+		"1.2.3.4",
+		"~1.4 ||",
+		">x",
+	} {
+		if _, err := ParseRange(test); err == nil {
+			t.Errorf("Expected parse failure for %q", test)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package semver parses and compares image tags as semantic
+// versions (http://semver.org/), so that images can be ordered by
+// version rather than by when they were built.
+package semver
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrNotSemver = errors.New("not a semantic version")
+
+// Version is a parsed semantic version. Tags are often prefixed with
+// a "v" (e.g., "v1.2.3"); this is accepted, and ignored for the
+// purpose of comparison.
+type Version struct {
+	Major, Minor, Patch uint64
+	Pre                 []string
+	Build               string
+}
+
+// Parse parses a string of the form [v]MAJOR.MINOR.PATCH[-PRE][+BUILD].
+func Parse(s string) (Version, error) {
+	var v Version
+	s = strings.TrimPrefix(s, "v")
+	if plus := strings.Index(s, "+"); plus > -1 {
+		v.Build = s[plus+1:]
+		if !validIdentifiers(v.Build, false) {
+			return Version{}, errors.Wrapf(ErrNotSemver, "invalid build metadata in %q", s)
+		}
+		s = s[:plus]
+	}
+	if dash := strings.Index(s, "-"); dash > -1 {
+		pre := s[dash+1:]
+		if !validIdentifiers(pre, true) {
+			return Version{}, errors.Wrapf(ErrNotSemver, "invalid prerelease in %q", s)
+		}
+		v.Pre = strings.Split(pre, ".")
+		s = s[:dash]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Version{}, errors.Wrapf(ErrNotSemver, "expected MAJOR.MINOR.PATCH, got %q", s)
+	}
+	nums := make([]uint64, 3)
+	for i, part := range parts {
+		n, err := parseNumber(part)
+		if err != nil {
+			return Version{}, errors.Wrapf(ErrNotSemver, "invalid version number %q", part)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// parseNumber parses a numeric identifier, which may not have leading
+// zeroes.
+func parseNumber(s string) (uint64, error) {
+	if len(s) > 1 && s[0] == '0' {
+		return 0, ErrNotSemver
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, ErrNotSemver
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func validIdentifiers(s string, numericNoLeadingZero bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, c := range id {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if numeric && numericNoLeadingZero && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPrerelease says whether the version has prerelease identifiers,
+// e.g., "1.0.0-rc.1".
+func (v Version) IsPrerelease() bool {
+	return len(v.Pre) > 0
+}
+
+func (v Version) String() string {
+	s := strconv.FormatUint(v.Major, 10) + "." + strconv.FormatUint(v.Minor, 10) + "." + strconv.FormatUint(v.Patch, 10)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 according to whether v has lower, the
+// same, or higher precedence than other. As per the specification,
+// build metadata is not considered.
+func (v Version) Compare(other Version) int {
+	if c := compareNumbers(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	// A version without prerelease identifiers has higher precedence
+	// than one with.
+	switch {
+	case len(v.Pre) == 0 && len(other.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(other.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(other.Pre); i++ {
+		if c := compareIdentifiers(v.Pre[i], other.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return compareNumbers(uint64(len(v.Pre)), uint64(len(other.Pre)))
+}
+
+// LessThan is shorthand for v.Compare(other) < 0.
+func (v Version) LessThan(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+func compareNumbers(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Numeric identifiers are compared numerically, and have lower
+// precedence than alphanumeric identifiers, which are compared
+// lexically.
+func compareIdentifiers(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareNumbers(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
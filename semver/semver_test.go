@@ -0,0 +1,92 @@
+package semver
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, x := range []struct {
+		test     string
+		expected string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"0.0.0", "0.0.0"},
+		{"1.2.3-rc.1", "1.2.3-rc.1"},
+		{"1.2.3-alpha-1", "1.2.3-alpha-1"},
+		{"1.2.3+build.5", "1.2.3+build.5"},
+		{"v10.20.30-beta.2+exp.sha.5114f85", "10.20.30-beta.2+exp.sha.5114f85"},
+	} {
+		v, err := Parse(x.test)
+		if err != nil {
+			t.Fatalf("Failed parsing %q: %s", x.test, err)
+		}
+		if v.String() != x.expected {
+			t.Errorf("Parsed %q as %q, expected %q", x.test, v.String(), x.expected)
+		}
+	}
+}
+
+func TestParseErrorCases(t *testing.T) {
+	for _, test := range []string{
+		"",
+		"latest",
+		"master-a000001",
+		"1.2",
+		"1.2.3.4",
+		"01.2.3",
+		"1.2.x",
+		"1.2.3-",
+		"1.2.3-rc..1",
+		"1.2.3-01",
+		"1.2.3+",
+		"1.2.3-rc_1",
+	} {
+		if _, err := Parse(test); err == nil {
+			t.Errorf("Expected parse failure for %q", test)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// in ascending order of precedence, from semver.org
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.9",
+		"1.3.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := Parse(ordered[i])
+			b, _ := Parse(ordered[j])
+			var expected int
+			switch {
+			case i < j:
+				expected = -1
+			case i > j:
+				expected = 1
+			}
+			if c := a.Compare(b); c != expected {
+				t.Errorf("Compare(%s, %s): expected %d, got %d", a, b, expected, c)
+			}
+		}
+	}
+}
+
+func TestCompareIgnoresBuildAndPrefix(t *testing.T) {
+	a, _ := Parse("v1.2.3+build.1")
+	b, _ := Parse("1.2.3+build.2")
+	if a.Compare(b) != 0 {
+		t.Errorf("Expected %s and %s to have the same precedence", a, b)
+	}
+}
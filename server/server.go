@@ -2,6 +2,8 @@ package server
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -13,8 +15,10 @@ import (
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/instance"
 	"github.com/weaveworks/flux/jobs"
+	"github.com/weaveworks/flux/notifications"
 	"github.com/weaveworks/flux/platform"
 	"github.com/weaveworks/flux/registry"
+	"github.com/weaveworks/flux/release"
 )
 
 const (
@@ -72,11 +76,14 @@ func (s *Server) Status(inst flux.InstanceID) (res flux.Status, err error) {
 	if err != nil {
 		return res, errors.Wrapf(err, "getting config for %s", inst)
 	}
-	res.Git.Configured = config.Settings.Git.URL != "" && config.Settings.Git.Key != ""
+	res.Git.Configured = config.Settings.Git.URL != "" && (config.Settings.Git.Key != "" || config.Settings.Git.Password != "")
+	res.Sync.Paused = config.SyncPaused
 
-	if _, err := helper.ConfigRepo().Clone(); err != nil {
+	if path, err := helper.ConfigRepo().Clone(); err != nil {
 		// Remove \r, so it prints as a yaml block
 		res.Git.Error = strings.Replace(err.Error(), "\r", "", -1)
+	} else {
+		os.RemoveAll(filepath.Dir(path))
 	}
 
 	res.Fluxsvc = flux.FluxsvcStatus{Version: s.version}
@@ -112,6 +119,9 @@ func (s *Server) ListServices(inst flux.InstanceID, namespace string) (res []flu
 			Status:     service.Status,
 			Automated:  config.Services[service.ID].Automated,
 			Locked:     config.Services[service.ID].Locked,
+			Lock:       config.Services[service.ID].Lock,
+
+			AutoRollback: config.Services[service.ID].AutoRollback,
 		})
 	}
 	return res, nil
@@ -153,11 +163,17 @@ func (s *Server) ListImages(inst flux.InstanceID, spec flux.ServiceSpec) (res []
 		return nil, errors.Wrap(err, "getting images for services")
 	}
 
+	config, err := helper.GetConfig()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting config for %s", inst)
+	}
+
 	for _, service := range services {
 		containers := containersWithAvailable(service, images)
 		res = append(res, flux.ImageStatus{
 			ID:         service.ID,
 			Containers: containers,
+			TagFilter:  config.Services[service.ID].TagFilter,
 		})
 	}
 
@@ -198,6 +214,7 @@ func (s *Server) History(inst flux.InstanceID, spec flux.ServiceSpec) (res []flu
 			return nil, errors.Wrapf(err, "parsing service ID from spec %s", spec)
 		}
 
+		id = resolveServiceID(helper, id)
 		events, err = helper.EventsForService(id)
 		if err != nil {
 			return nil, errors.Wrapf(err, "fetching history events for %s", id)
@@ -211,17 +228,19 @@ func (s *Server) History(inst flux.InstanceID, spec flux.ServiceSpec) (res []flu
 			Type:  "v0",
 			Data:  event.String(),
 			Event: event,
+			Cause: event.Cause(),
 		}
 	}
 
 	return res, nil
 }
 
-func (s *Server) Automate(instID flux.InstanceID, service flux.ServiceID) error {
+func (s *Server) Automate(instID flux.InstanceID, service flux.ServiceID, cause flux.Cause) error {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return err
 	}
+	service = resolveServiceID(inst, service)
 	now := time.Now().UTC()
 	if err := inst.LogEvent(flux.Event{
 		ServiceIDs: []flux.ServiceID{service},
@@ -229,17 +248,19 @@ func (s *Server) Automate(instID flux.InstanceID, service flux.ServiceID) error
 		StartedAt:  now,
 		EndedAt:    now,
 		LogLevel:   flux.LogLevelInfo,
+		Metadata:   flux.PolicyEventMetadata{Cause: cause},
 	}); err != nil {
 		return err
 	}
 	return recordAutomated(inst, service, true)
 }
 
-func (s *Server) Deautomate(instID flux.InstanceID, service flux.ServiceID) error {
+func (s *Server) Deautomate(instID flux.InstanceID, service flux.ServiceID, cause flux.Cause) error {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return err
 	}
+	service = resolveServiceID(inst, service)
 	now := time.Now().UTC()
 	if err := inst.LogEvent(flux.Event{
 		ServiceIDs: []flux.ServiceID{service},
@@ -247,12 +268,26 @@ func (s *Server) Deautomate(instID flux.InstanceID, service flux.ServiceID) erro
 		StartedAt:  now,
 		EndedAt:    now,
 		LogLevel:   flux.LogLevelInfo,
+		Metadata:   flux.PolicyEventMetadata{Cause: cause},
 	}); err != nil {
 		return err
 	}
 	return recordAutomated(inst, service, false)
 }
 
+// resolveServiceID resolves the ID of a service given, for recording
+// in the config or looking up in the history. Neither needs the
+// platform, so if the platform can't be asked (say, because fluxd is
+// disconnected during an incident, when locking a service matters
+// most) the ID is used as given.
+func resolveServiceID(inst *instance.Instance, id flux.ServiceID) flux.ServiceID {
+	resolved, err := inst.ResolveServiceID(id)
+	if err != nil {
+		inst.Log("service", id, "err", err)
+	}
+	return resolved
+}
+
 func recordAutomated(inst *instance.Instance, service flux.ServiceID, automated bool) error {
 	return inst.UpdateConfig(func(conf instance.Config) (instance.Config, error) {
 		if serviceConf, found := conf.Services[service]; found {
@@ -267,29 +302,40 @@ func recordAutomated(inst *instance.Instance, service flux.ServiceID, automated
 	})
 }
 
-func (s *Server) Lock(instID flux.InstanceID, service flux.ServiceID) error {
+func (s *Server) Lock(instID flux.InstanceID, service flux.ServiceID, cause flux.Cause, duration time.Duration) error {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return err
 	}
+	service = resolveServiceID(inst, service)
 	now := time.Now().UTC()
+	lock := &flux.LockInfo{
+		Reason: cause.Message,
+		User:   cause.User,
+	}
+	if duration > 0 {
+		expires := now.Add(duration)
+		lock.Expires = &expires
+	}
 	if err := inst.LogEvent(flux.Event{
 		ServiceIDs: []flux.ServiceID{service},
 		Type:       flux.EventLock,
 		StartedAt:  now,
 		EndedAt:    now,
 		LogLevel:   flux.LogLevelInfo,
+		Metadata:   flux.PolicyEventMetadata{Cause: cause, Expires: lock.Expires},
 	}); err != nil {
 		return err
 	}
-	return recordLock(inst, service, true)
+	return recordLock(inst, service, lock)
 }
 
-func (s *Server) Unlock(instID flux.InstanceID, service flux.ServiceID) error {
+func (s *Server) Unlock(instID flux.InstanceID, service flux.ServiceID, cause flux.Cause) error {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return err
 	}
+	service = resolveServiceID(inst, service)
 	now := time.Now().UTC()
 	if err := inst.LogEvent(flux.Event{
 		ServiceIDs: []flux.ServiceID{service},
@@ -297,20 +343,26 @@ func (s *Server) Unlock(instID flux.InstanceID, service flux.ServiceID) error {
 		StartedAt:  now,
 		EndedAt:    now,
 		LogLevel:   flux.LogLevelInfo,
+		Metadata:   flux.PolicyEventMetadata{Cause: cause},
 	}); err != nil {
 		return err
 	}
-	return recordLock(inst, service, false)
+	return recordLock(inst, service, nil)
 }
 
-func recordLock(inst *instance.Instance, service flux.ServiceID, locked bool) error {
+// recordLock locks the service with the details given, or unlocks it
+// if there are none.
+func recordLock(inst *instance.Instance, service flux.ServiceID, lock *flux.LockInfo) error {
+	locked := lock != nil
 	if err := inst.UpdateConfig(func(conf instance.Config) (instance.Config, error) {
 		if serviceConf, found := conf.Services[service]; found {
 			serviceConf.Locked = locked
+			serviceConf.Lock = lock
 			conf.Services[service] = serviceConf
 		} else if locked {
 			conf.Services[service] = instance.ServiceConfig{
 				Locked: true,
+				Lock:   lock,
 			}
 		}
 		return conf, nil
@@ -320,6 +372,88 @@ func recordLock(inst *instance.Instance, service flux.ServiceID, locked bool) er
 	return nil
 }
 
+func (s *Server) SetImageOrder(instID flux.InstanceID, service flux.ServiceID, order flux.ImageOrder) error {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return err
+	}
+	service = resolveServiceID(inst, service)
+	if order == flux.ImageOrderCreated {
+		order = "" // the default, so don't bother recording it
+	}
+	return inst.UpdateConfig(func(conf instance.Config) (instance.Config, error) {
+		if serviceConf, found := conf.Services[service]; found {
+			serviceConf.ImageOrder = order
+			conf.Services[service] = serviceConf
+		} else if order != "" {
+			conf.Services[service] = instance.ServiceConfig{
+				ImageOrder: order,
+			}
+		}
+		return conf, nil
+	})
+}
+
+func (s *Server) SetTagFilter(instID flux.InstanceID, service flux.ServiceID, filter flux.TagFilter) error {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return err
+	}
+	service = resolveServiceID(inst, service)
+	return inst.UpdateConfig(func(conf instance.Config) (instance.Config, error) {
+		if serviceConf, found := conf.Services[service]; found {
+			serviceConf.TagFilter = filter
+			conf.Services[service] = serviceConf
+		} else if filter != flux.TagFilterAll {
+			conf.Services[service] = instance.ServiceConfig{
+				TagFilter: filter,
+			}
+		}
+		return conf, nil
+	})
+}
+
+func (s *Server) SetAutoRollback(instID flux.InstanceID, service flux.ServiceID, enabled bool) error {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return err
+	}
+	service = resolveServiceID(inst, service)
+	return inst.UpdateConfig(func(conf instance.Config) (instance.Config, error) {
+		if serviceConf, found := conf.Services[service]; found {
+			serviceConf.AutoRollback = enabled
+			conf.Services[service] = serviceConf
+		} else if enabled {
+			conf.Services[service] = instance.ServiceConfig{
+				AutoRollback: true,
+			}
+		}
+		return conf, nil
+	})
+}
+
+func (s *Server) SetSyncPaused(instID flux.InstanceID, paused bool) error {
+	return s.config.UpdateConfig(instID, func(conf instance.Config) (instance.Config, error) {
+		conf.SyncPaused = paused
+		return conf, nil
+	})
+}
+
+// Drift compares the services defined in the config repo with what's
+// running for them.
+func (s *Server) Drift(instID flux.InstanceID) ([]flux.ServiceDrift, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance")
+	}
+	rc := release.NewReleaseContext(inst)
+	if err := rc.CloneRepo(); err != nil {
+		return nil, errors.Wrap(err, "cloning repo")
+	}
+	defer rc.Clean()
+	return release.Drift(instID, rc)
+}
+
 func (s *Server) PostRelease(inst flux.InstanceID, params jobs.ReleaseJobParams) (jobs.JobID, error) {
 	return s.jobs.PutJob(inst, jobs.Job{
 		Queue:    jobs.ReleaseJob,
@@ -334,12 +468,25 @@ func (s *Server) GetRelease(inst flux.InstanceID, id jobs.JobID) (jobs.Job, erro
 	if err != nil {
 		return jobs.Job{}, err
 	}
-	if j.Method != jobs.ReleaseJob {
+	if j.Method != jobs.ReleaseJob && j.Method != jobs.RollbackJob {
 		return jobs.Job{}, fmt.Errorf("job is not a release")
 	}
 	return j, err
 }
 
+func (s *Server) PostRollback(inst flux.InstanceID, params jobs.RollbackJobParams) (jobs.JobID, error) {
+	return s.jobs.PutJob(inst, jobs.Job{
+		Queue:    jobs.ReleaseJob,
+		Method:   jobs.RollbackJob,
+		Priority: jobs.PriorityInteractive,
+		Params:   params,
+	})
+}
+
+func (s *Server) ListDeliveries(inst flux.InstanceID) ([]jobs.Job, error) {
+	return s.jobs.ListJobs(inst, jobs.NotifyJob)
+}
+
 func (s *Server) GetConfig(instID flux.InstanceID) (flux.InstanceConfig, error) {
 	fullConfig, err := s.config.GetConfig(instID)
 	if err != nil {
@@ -354,12 +501,31 @@ func (s *Server) SetConfig(instID flux.InstanceID, updates flux.UnsafeInstanceCo
 	if _, err := registry.CredentialsFromConfig(updates); err != nil {
 		return errors.Wrap(err, "invalid registry credentials")
 	}
+	for _, window := range updates.Freezes {
+		if err := window.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, notifier := range flux.InstanceConfig(updates).MigrateSlack().Notifiers {
+		if _, err := notifications.New(notifier); err != nil {
+			return errors.Wrap(err, "invalid notifier")
+		}
+	}
 	return s.config.UpdateConfig(instID, applyConfigUpdates(updates))
 }
 
 func applyConfigUpdates(updates flux.UnsafeInstanceConfig) instance.UpdateFunc {
 	return func(config instance.Config) (instance.Config, error) {
-		config.Settings = updates
+		settings := flux.UnsafeInstanceConfig(flux.InstanceConfig(updates).MigrateSlack())
+		// The known hosts are usually filled in by Flux, so aren't in
+		// the config uploaded; they're kept unless cleared explicitly.
+		switch settings.Git.KnownHosts {
+		case "":
+			settings.Git.KnownHosts = config.Settings.Git.KnownHosts
+		case flux.KnownHostsClear:
+			settings.Git.KnownHosts = ""
+		}
+		config.Settings = settings
 		return config, nil
 	}
 }
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/instance"
+)
+
+func TestApplyConfigUpdatesKnownHosts(t *testing.T) {
+	existing := instance.MakeConfig()
+	existing.Settings.Git.KnownHosts = "github.com ssh-rsa AAAA"
+
+	for _, c := range []struct {
+		given, expected string
+	}{
+		{"", "github.com ssh-rsa AAAA"},
+		{"github.com ssh-rsa BBBB", "github.com ssh-rsa BBBB"},
+		{flux.KnownHostsClear, ""},
+	} {
+		var updates flux.UnsafeInstanceConfig
+		updates.Git.URL = "git@github.com:weaveworks/flux-example"
+		updates.Git.KnownHosts = c.given
+		config, err := applyConfigUpdates(updates)(existing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Settings.Git.KnownHosts != c.expected {
+			t.Errorf("given known hosts %q, expected %q, got %q", c.given, c.expected, config.Settings.Git.KnownHosts)
+		}
+		if config.Settings.Git.URL != updates.Git.URL {
+			t.Errorf("expected the rest of the config to be updated, got %#v", config.Settings.Git)
+		}
+	}
+}
@@ -17,11 +17,18 @@ const (
 	PolicyNone      = Policy("")
 	PolicyLocked    = Policy("locked")
 	PolicyAutomated = Policy("automated")
+
+	PolicyAutoRollback = Policy("auto-rollback")
+
+	ImageOrderCreated        = ImageOrder("created")
+	ImageOrderSemver         = ImageOrder("semver")
+	ImageOrderSemverReleases = ImageOrder("semver-releases")
 )
 
 var (
 	ErrInvalidServiceID   = errors.New("invalid service ID")
 	ErrInvalidReleaseKind = errors.New("invalid release kind")
+	ErrInvalidImageOrder  = errors.New("invalid image order")
 )
 
 type Token string
@@ -38,7 +45,13 @@ const InstanceIDHeaderKey = "X-Scope-OrgID"
 
 const DefaultInstanceID = "<default-instance-id>"
 
-type ServiceID string // "default/helloworld"
+// A ServiceID identifies a service, in one of two forms: the
+// namespace and name of the Kubernetes service in front of a workload
+// ("default/helloworld"), or the namespace, kind and name of the
+// workload itself ("default:deployment/helloworld"). The second form
+// can address workloads that don't have a service (or that have more
+// than one) in front of them.
+type ServiceID string
 
 func (id ServiceID) String() string {
 	return string(id)
@@ -49,19 +62,45 @@ func ParseServiceID(s string) (ServiceID, error) {
 	if len(toks) != 2 {
 		return "", ErrInvalidServiceID
 	}
-	return ServiceID(s), nil
+	if !strings.Contains(toks[0], ":") {
+		return ServiceID(s), nil
+	}
+	nsKind := strings.SplitN(toks[0], ":", 2)
+	if nsKind[0] == "" || nsKind[1] == "" || toks[1] == "" {
+		return "", ErrInvalidServiceID
+	}
+	return MakeWorkloadID(nsKind[0], nsKind[1], toks[1]), nil
 }
 
 func MakeServiceID(namespace, service string) ServiceID {
 	return ServiceID(namespace + "/" + service)
 }
 
+// MakeWorkloadID makes the ID of a workload of the kind given (e.g.,
+// "Deployment"). Kinds are compared without regard to case, so are
+// kept in lower case.
+func MakeWorkloadID(namespace, kind, name string) ServiceID {
+	return ServiceID(namespace + ":" + strings.ToLower(kind) + "/" + name)
+}
+
+// Components gives the namespace and name of the service or workload
+// identified.
 func (id ServiceID) Components() (namespace, service string) {
 	toks := strings.SplitN(string(id), "/", 2)
 	if len(toks) != 2 {
 		panic("invalid service spec")
 	}
-	return toks[0], toks[1]
+	return strings.SplitN(toks[0], ":", 2)[0], toks[1]
+}
+
+// Kind gives the kind of workload identified, or the empty string if
+// the ID is that of a service.
+func (id ServiceID) Kind() string {
+	toks := strings.SplitN(strings.SplitN(string(id), "/", 2)[0], ":", 2)
+	if len(toks) != 2 {
+		return ""
+	}
+	return toks[1]
 }
 
 type ServiceIDSet map[ServiceID]struct{}
@@ -176,13 +215,17 @@ func ParseImageSpec(s string) (ImageSpec, error) {
 		return ImageSpec(s), nil
 	}
 
-	parts := strings.Split(s, ":")
-	if len(parts) != 2 || parts[1] == "" {
+	id, err := ParseImageID(s)
+	if err != nil {
+		return "", err
+	}
+	// ParseImageID defaults the tag to latest; for a release we
+	// want it stated, unless the image is pinned by digest.
+	name := strings.SplitN(s, "@", 2)[0]
+	if id.Digest == "" && !strings.HasSuffix(name, ":"+id.Tag) {
 		return "", errors.Wrap(ErrInvalidImageID, "blank tag (if you want latest, explicitly state the tag :latest)")
 	}
-
-	id, err := ParseImageID(s)
-	return ImageSpec(id.String()), err
+	return ImageSpec(id.String()), nil
 }
 
 func (s ImageSpec) String() string {
@@ -200,6 +243,10 @@ func ImageSpecFromID(id ImageID) ImageSpec {
 type ImageStatus struct {
 	ID         ServiceID
 	Containers []Container
+	// TagFilter is the service's filter on image tags, if it has
+	// one, so that images which won't be considered for automation
+	// can be marked.
+	TagFilter TagFilter `json:",omitempty"`
 }
 
 // Policy is an string, denoting the current deployment policy of a service,
@@ -218,12 +265,34 @@ func ParsePolicy(s string) Policy {
 	return PolicyNone
 }
 
+// ImageOrder denotes how the images available for a service are
+// ranked, when looking for the latest one to release: by creation
+// time (the default), by semantic version of the tag, or by semantic
+// version ignoring prereleases.
+type ImageOrder string
+
+func ParseImageOrder(s string) (ImageOrder, error) {
+	switch ImageOrder(s) {
+	case "", ImageOrderCreated:
+		return ImageOrderCreated, nil
+	case ImageOrderSemver, ImageOrderSemverReleases:
+		return ImageOrder(s), nil
+	}
+	return "", errors.Wrapf(ErrInvalidImageOrder, "%q (expected one of %s, %s, %s)", s, ImageOrderCreated, ImageOrderSemver, ImageOrderSemverReleases)
+}
+
 type ServiceStatus struct {
 	ID         ServiceID
 	Containers []Container
 	Status     string
 	Automated  bool
 	Locked     bool
+	// Lock gives the details of the lock, if the service is locked
+	// and there are any.
+	Lock *LockInfo `json:",omitempty"`
+	// AutoRollback says whether a failed release of the service is
+	// rolled back automatically.
+	AutoRollback bool `json:",omitempty"`
 }
 
 func (s ServiceStatus) Policies() string {
@@ -234,6 +303,9 @@ func (s ServiceStatus) Policies() string {
 	if s.Locked {
 		ps = append(ps, string(PolicyLocked))
 	}
+	if s.AutoRollback {
+		ps = append(ps, string(PolicyAutoRollback))
+	}
 	sort.Strings(ps)
 	return strings.Join(ps, ",")
 }
@@ -255,6 +327,8 @@ type HistoryEntry struct {
 	Type  string
 	Data  string
 	Event Event
+	// Cause is who made the change, and why, if known
+	Cause Cause
 }
 
 // TODO: How similar should this be to the `get-config` result?
@@ -262,6 +336,7 @@ type Status struct {
 	Fluxsvc FluxsvcStatus `json:"fluxsvc" yaml:"fluxsvc"`
 	Fluxd   FluxdStatus   `json:"fluxd" yaml:"fluxd"`
 	Git     GitStatus     `json:"git" yaml:"git"`
+	Sync    SyncStatus    `json:"sync" yaml:"sync"`
 }
 
 type FluxsvcStatus struct {
@@ -277,3 +352,7 @@ type GitStatus struct {
 	Configured bool   `json:"configured" yaml:"configured"`
 	Error      string `json:"error,omitempty" yaml:"error,omitempty"`
 }
+
+type SyncStatus struct {
+	Paused bool `json:"paused" yaml:"paused"`
+}
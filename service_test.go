@@ -8,6 +8,12 @@ func TestParseImageSpec(t *testing.T) {
 	parseSpec(t, ":tag", true)
 	parseSpec(t, "image:", true)
 	parseSpec(t, "image", true)
+	parseSpec(t, "localhost:5000/image:tag", false)
+	parseSpec(t, "localhost:5000/image", true)
+	parseSpec(t, "registry.example.com/deeply/nested/image:tag", false)
+	parseSpec(t, "image@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false)
+	parseSpec(t, "image:tag@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false)
+	parseSpec(t, "image@notadigest", true)
 	parseSpec(t, string(ImageSpecNone), false)
 	parseSpec(t, string(ImageSpecLatest), false)
 	parseSpec(t, "<invalid spec>", true)
@@ -23,3 +29,56 @@ func parseSpec(t *testing.T, image string, expectError bool) {
 		t.Fatalf("Expected string spec %q but got %q", image, string(spec))
 	}
 }
+
+func TestParseImageOrder(t *testing.T) {
+	for _, x := range []struct {
+		test     string
+		expected ImageOrder
+	}{
+		{"", ImageOrderCreated},
+		{"created", ImageOrderCreated},
+		{"semver", ImageOrderSemver},
+		{"semver-releases", ImageOrderSemverReleases},
+	} {
+		order, err := ParseImageOrder(x.test)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", x.test, err)
+		}
+		if order != x.expected {
+			t.Errorf("Expected %q to parse as %q, got %q", x.test, x.expected, order)
+		}
+	}
+	if _, err := ParseImageOrder("alphabetical"); err == nil {
+		t.Error("Expected error for invalid image order")
+	}
+}
+
+func TestParseServiceID(t *testing.T) {
+	for _, x := range []struct {
+		test                  string
+		expected              ServiceID
+		namespace, kind, name string
+	}{
+		{"default/helloworld", "default/helloworld", "default", "", "helloworld"},
+		{"default:deployment/helloworld", "default:deployment/helloworld", "default", "deployment", "helloworld"},
+		{"monitoring:DaemonSet/node-exporter", "monitoring:daemonset/node-exporter", "monitoring", "daemonset", "node-exporter"},
+	} {
+		id, err := ParseServiceID(x.test)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", x.test, err)
+		}
+		if id != x.expected {
+			t.Errorf("Expected %q to parse as %q, got %q", x.test, x.expected, id)
+		}
+		ns, name := id.Components()
+		if ns != x.namespace || id.Kind() != x.kind || name != x.name {
+			t.Errorf("Expected %q to have components %q, %q, %q; got %q, %q, %q", x.test, x.namespace, x.kind, x.name, ns, id.Kind(), name)
+		}
+	}
+
+	for _, invalid := range []string{"helloworld", ":deployment/helloworld", "default:/helloworld", "default:deployment/"} {
+		if _, err := ParseServiceID(invalid); err == nil {
+			t.Errorf("Expected error for invalid service ID %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package flux
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/semver"
+)
+
+const (
+	TagFilterAll = TagFilter("")
+
+	tagFilterGlob   = "glob:"
+	tagFilterRegexp = "regexp:"
+	tagFilterSemver = "semver:"
+)
+
+var ErrInvalidTagFilter = errors.New("invalid tag filter")
+
+// TagFilter restricts the image tags that are considered when
+// automating a service, or releasing the latest images to it. It is
+// one of
+//
+//	glob:<pattern>    e.g., glob:master-*
+//	regexp:<regexp>   e.g., regexp:^v\d+$ (must match the whole tag)
+//	semver:<range>    e.g., semver:~1.4 (see semver.Range)
+//
+// For convenience, a filter without a prefix is taken as a semver
+// range if it starts with one of ~ ^ < > =, and as a glob otherwise.
+// The empty filter lets all tags through.
+type TagFilter string
+
+// A TagMatcher is a TagFilter parsed and compiled, ready to match
+// tags with. The zero TagMatcher lets all tags through.
+type TagMatcher struct {
+	TagFilter
+	match func(tag string) bool
+}
+
+// ParseTagFilter parses the filter given, and compiles it so it can
+// be used to match any number of tags.
+func ParseTagFilter(s string) (TagMatcher, error) {
+	f := TagFilter(s)
+	if f == TagFilterAll {
+		return TagMatcher{}, nil
+	}
+	kind, pattern := f.parts()
+	var match func(string) bool
+	var err error
+	switch kind {
+	case tagFilterRegexp:
+		// It's compiled on its own first, so it can't close the
+		// group it's anchored in; if it compiles, so will that.
+		if _, err = regexp.Compile(pattern); err == nil {
+			re := regexp.MustCompile("^(?:" + pattern + ")$")
+			match = re.MatchString
+		}
+	case tagFilterSemver:
+		var r semver.Range
+		if r, err = semver.ParseRange(pattern); err == nil {
+			match = func(tag string) bool {
+				v, err := semver.Parse(tag)
+				return err == nil && r.Contains(v)
+			}
+		}
+	default:
+		if err = validateGlob(pattern); err == nil {
+			match = func(tag string) bool {
+				ok, err := path.Match(pattern, tag)
+				return err == nil && ok
+			}
+		}
+	}
+	if err != nil {
+		return TagMatcher{}, errors.Wrapf(ErrInvalidTagFilter, "%q: %s", s, err)
+	}
+	return TagMatcher{TagFilter: f, match: match}, nil
+}
+
+// Matches says whether the tag given gets through the filter.
+func (m TagMatcher) Matches(tag string) bool {
+	return m.match == nil || m.match(tag)
+}
+
+// validateGlob checks the whole of a glob pattern is well-formed.
+// path.Match stops reading the pattern as soon as the name doesn't
+// match, so it only reports a bad pattern (e.g., master-[) given a
+// name that gets as far as the bad part. Here, each character class
+// is matched against a sample on its own, which path.Match reads in
+// full.
+func validateGlob(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i++; i == len(pattern) {
+				return path.ErrBadPattern
+			}
+		case '[':
+			end := i + 1
+			for ; end < len(pattern) && pattern[end] != ']'; end++ {
+				if pattern[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(pattern) {
+				return path.ErrBadPattern
+			}
+			if _, err := path.Match(pattern[i:end+1], "a"); err != nil {
+				return err
+			}
+			i = end
+		}
+	}
+	return nil
+}
+
+// parts gives the kind of filter and the pattern.
+func (f TagFilter) parts() (kind, pattern string) {
+	s := string(f)
+	for _, prefix := range []string{tagFilterGlob, tagFilterRegexp, tagFilterSemver} {
+		if strings.HasPrefix(s, prefix) {
+			return prefix, strings.TrimPrefix(s, prefix)
+		}
+	}
+	if s != "" && strings.ContainsAny(s[:1], "~^<>=") {
+		return tagFilterSemver, s
+	}
+	return tagFilterGlob, s
+}
@@ -0,0 +1,54 @@
+package flux
+
+import (
+	"testing"
+)
+
+func TestTagFilter_Matches(t *testing.T) {
+	for _, x := range []struct {
+		filter  TagFilter
+		in, out []string
+	}{
+		{"", []string{"master-a000001", "v1.0.0", "pr-123"}, nil},
+		{"master-*", []string{"master-a000001"}, []string{"pr-123", "debug", "v1.0.0"}},
+		{"glob:v1.*", []string{"v1.0", "v1.4.3"}, []string{"v2.0.0", "1.4.3"}},
+		{"glob:v[0-9]-\\[rc\\]", []string{"v1-[rc]"}, []string{"v1-r", "vx-[rc]"}},
+		{"regexp:v[0-9]+", []string{"v1", "v42"}, []string{"v1.0", "av1"}},
+		{"~1.4", []string{"1.4.0", "v1.4.9"}, []string{"1.5.0", "master-a000001", "1.4.1-rc.1"}},
+		{"semver:1.x", []string{"1.0.0", "v1.99.0"}, []string{"2.0.0"}},
+		{">=2", []string{"2.0.0", "v3.1.0"}, []string{"1.9.9"}},
+	} {
+		matcher, err := ParseTagFilter(string(x.filter))
+		if err != nil {
+			t.Fatalf("Error parsing filter %q: %s", x.filter, err)
+		}
+		for _, tag := range x.in {
+			if !matcher.Matches(tag) {
+				t.Errorf("Expected filter %q to match tag %q", x.filter, tag)
+			}
+		}
+		for _, tag := range x.out {
+			if matcher.Matches(tag) {
+				t.Errorf("Expected filter %q not to match tag %q", x.filter, tag)
+			}
+		}
+	}
+}
+
+func TestParseTagFilterErrorCases(t *testing.T) {
+	for _, test := range []string{
+		"master-[",
+		"master-[a-",
+		"glob:v[]",
+		"glob:v1\\",
+		"glob:master-*-[^",
+		"regexp:v(1",
+		"regexp:a)|(b",
+		"semver:master-*",
+		"~",
+	} {
+		if _, err := ParseTagFilter(test); err == nil {
+			t.Errorf("Expected parse failure for %q", test)
+		}
+	}
+}